@@ -4,14 +4,19 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/Zelvalna/go_final_project/config"
+	"github.com/Zelvalna/go_final_project/internal/attachments"
 	"github.com/Zelvalna/go_final_project/internal/handlers"
+	"github.com/Zelvalna/go_final_project/internal/jobs"
 	"github.com/Zelvalna/go_final_project/internal/middleware"
 	"github.com/Zelvalna/go_final_project/internal/storage"
 	"github.com/Zelvalna/go_final_project/model"
 
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
 )
 
@@ -27,12 +32,26 @@ func main() {
 
 	// Проверяем, установлен ли пароль в переменной окружения TODO_PASSWORD
 	cfg := config.Config{
-		TodoPassword: os.Getenv("TODO_PASSWORD"),
-		Port:         model.DefPort,
+		TodoPassword:     os.Getenv("TODO_PASSWORD"),
+		Port:             model.DefPort,
+		Location:         time.Local,
+		WeekStart:        time.Monday,
+		TrashRetention:   model.DefTrashRetentionDays * 24 * time.Hour,
+		BackupDir:        model.DefBackupDir,
+		BackupKeepDaily:  model.DefBackupKeepDaily,
+		BackupKeepWeekly: model.DefBackupKeepWeekly,
+		AttachDir:        model.DefAttachDir,
+		AttachMaxSize:    model.DefAttachMaxSizeMB << 20,
+		JWTSecret:        os.Getenv("TODO_JWT_SECRET"),
+		TokenTTL:         model.DefTokenTTLHours * time.Hour,
 	}
 	if cfg.TodoPassword == "" {
 		log.Fatal("TODO_PASSWORD environment variable is required")
 	}
+	// Пароль администратора берётся из TODO_PASSWORD при каждом запуске
+	if err := storage.SetAdminPassword(cfg.TodoPassword); err != nil {
+		log.Fatalf("Error setting admin password: %v", err)
+	}
 
 	// Проверка переменной окружения TODO_PORT для переопределения порта
 	envPort := os.Getenv("TODO_PORT")
@@ -40,21 +59,185 @@ func main() {
 		cfg.Port = envPort
 	}
 
+	// Часовой пояс TODO_TZ используется для вычисления текущей даты
+	if envTZ := os.Getenv("TODO_TZ"); len(envTZ) != 0 {
+		loc, err := time.LoadLocation(envTZ)
+		if err != nil {
+			log.Fatalf("Invalid TODO_TZ: %v", err)
+		}
+		cfg.Location = loc
+		time.Local = loc
+	}
+
+	// Первый день недели TODO_WEEK_START: 1 - понедельник, ..., 7 - воскресенье
+	if envWeekStart := os.Getenv("TODO_WEEK_START"); len(envWeekStart) != 0 {
+		day, err := strconv.Atoi(envWeekStart)
+		if err != nil || day < 1 || day > 7 {
+			log.Fatalf("Invalid TODO_WEEK_START: %q", envWeekStart)
+		}
+		cfg.WeekStart = time.Weekday(day % 7)
+	}
+
+	// Срок хранения задач в корзине в днях TODO_TRASH_RETENTION
+	if envRetention := os.Getenv("TODO_TRASH_RETENTION"); len(envRetention) != 0 {
+		days, err := strconv.Atoi(envRetention)
+		if err != nil || days < 0 {
+			log.Fatalf("Invalid TODO_TRASH_RETENTION: %q", envRetention)
+		}
+		cfg.TrashRetention = time.Duration(days) * 24 * time.Hour
+	}
+	// Каталог для резервных копий TODO_BACKUP_DIR
+	if envBackupDir := os.Getenv("TODO_BACKUP_DIR"); len(envBackupDir) != 0 {
+		cfg.BackupDir = envBackupDir
+	}
+
+	// Период автоматического резервного копирования TODO_BACKUP_INTERVAL, например "24h"
+	if envInterval := os.Getenv("TODO_BACKUP_INTERVAL"); len(envInterval) != 0 {
+		interval, err := time.ParseDuration(envInterval)
+		if err != nil || interval < 0 {
+			log.Fatalf("Invalid TODO_BACKUP_INTERVAL: %q", envInterval)
+		}
+		cfg.BackupInterval = interval
+	}
+
+	// Количество хранимых ежедневных и еженедельных копий
+	if envKeep := os.Getenv("TODO_BACKUP_KEEP_DAILY"); len(envKeep) != 0 {
+		keep, err := strconv.Atoi(envKeep)
+		if err != nil || keep < 0 {
+			log.Fatalf("Invalid TODO_BACKUP_KEEP_DAILY: %q", envKeep)
+		}
+		cfg.BackupKeepDaily = keep
+	}
+	if envKeep := os.Getenv("TODO_BACKUP_KEEP_WEEKLY"); len(envKeep) != 0 {
+		keep, err := strconv.Atoi(envKeep)
+		if err != nil || keep < 0 {
+			log.Fatalf("Invalid TODO_BACKUP_KEEP_WEEKLY: %q", envKeep)
+		}
+		cfg.BackupKeepWeekly = keep
+	}
+
+	// Ключ подписи токенов TODO_JWT_SECRET. Без него ключ создаётся при запуске,
+	// и выданные токены перестают действовать после перезапуска
+	if len(cfg.JWTSecret) == 0 {
+		secret, err := middleware.NewSecret()
+		if err != nil {
+			log.Fatalf("Error generating token secret: %v", err)
+		}
+		cfg.JWTSecret = secret
+		log.Println("TODO_JWT_SECRET is not set, tokens will be invalidated on restart")
+	}
+	if cfg.JWTSecret == cfg.TodoPassword {
+		log.Fatal("TODO_JWT_SECRET must differ from TODO_PASSWORD")
+	}
+
+	// Срок действия токена TODO_TOKEN_TTL, например "8h"
+	if envTTL := os.Getenv("TODO_TOKEN_TTL"); len(envTTL) != 0 {
+		ttl, err := time.ParseDuration(envTTL)
+		if err != nil || ttl <= 0 {
+			log.Fatalf("Invalid TODO_TOKEN_TTL: %q", envTTL)
+		}
+		cfg.TokenTTL = ttl
+	}
+
+	// Токен подписки на календарь TODO_ICS_TOKEN
+	cfg.ICSToken = os.Getenv("TODO_ICS_TOKEN")
+
+	// Каталог и максимальный размер вложений в мегабайтах TODO_ATTACH_DIR и TODO_ATTACH_MAX_SIZE
+	if envAttachDir := os.Getenv("TODO_ATTACH_DIR"); len(envAttachDir) != 0 {
+		cfg.AttachDir = envAttachDir
+	}
+	if envMaxSize := os.Getenv("TODO_ATTACH_MAX_SIZE"); len(envMaxSize) != 0 {
+		size, err := strconv.Atoi(envMaxSize)
+		if err != nil || size < 1 {
+			log.Fatalf("Invalid TODO_ATTACH_MAX_SIZE: %q", envMaxSize)
+		}
+		cfg.AttachMaxSize = int64(size) << 20
+	}
+
+	if cfg.TrashRetention > 0 {
+		go jobs.PurgeTrash(cfg.TrashRetention, time.Hour, attachments.Store{Dir: cfg.AttachDir})
+	}
+	if cfg.BackupInterval > 0 {
+		go jobs.Backup(jobs.BackupPolicy{
+			Dir:        cfg.BackupDir,
+			Interval:   cfg.BackupInterval,
+			KeepDaily:  cfg.BackupKeepDaily,
+			KeepWeekly: cfg.BackupKeepWeekly,
+		})
+	}
+
 	// Путь к директории с веб-файлами
 	webDir := model.WebDir
 	fs := http.FileServer(http.Dir(webDir))
 
+	// Клиентам CalDAV нужны методы WebDAV, которых нет в chi по умолчанию
+	chi.RegisterMethod("PROPFIND")
+	chi.RegisterMethod("REPORT")
+
 	r := chi.NewRouter()
+	r.Use(chimiddleware.RequestID)
 
 	r.Mount("/", fs)
 	r.Get("/api/nextdate", handlers.NextDateHandler)
 	r.Post("/api/task", middleware.Auth(handlers.TaskHandler, cfg))
 	r.Get("/api/tasks", middleware.Auth(handlers.TaskHandler, cfg))
 	r.Get("/api/task", middleware.Auth(handlers.TaskByIdGet, cfg))
+	r.Get("/api/calendar", middleware.Auth(handlers.CalendarGet, cfg))
+	r.Get("/api/calendar.ics", middleware.FeedAuth(handlers.CalendarICSGet, cfg))
+	r.Post("/api/calendar/token", middleware.Auth(handlers.FeedTokenPost, cfg))
+	r.Get("/api/agenda", middleware.Auth(func(w http.ResponseWriter, r *http.Request) { handlers.AgendaGet(w, r, cfg) }, cfg))
 	r.Put("/api/task", middleware.Auth(handlers.TaskHandler, cfg))
 	r.Post("/api/task/done", middleware.Auth(handlers.TaskDonePost, cfg))
+	r.Get("/api/task/checklist", middleware.Auth(handlers.ChecklistHandler, cfg))
+	r.Post("/api/task/checklist", middleware.Auth(handlers.ChecklistHandler, cfg))
+	r.Delete("/api/task/checklist", middleware.Auth(handlers.ChecklistHandler, cfg))
+	r.Post("/api/task/checklist/check", middleware.Auth(handlers.ChecklistItemCheckPost, cfg))
+	r.Post("/api/task/checklist/reorder", middleware.Auth(handlers.ChecklistReorderPost, cfg))
+	r.Post("/api/task/attachment", middleware.Auth(func(w http.ResponseWriter, r *http.Request) { handlers.AttachmentPost(w, r, cfg) }, cfg))
+	r.Get("/api/task/attachment", middleware.Auth(func(w http.ResponseWriter, r *http.Request) { handlers.AttachmentGet(w, r, cfg) }, cfg))
+	r.Delete("/api/task/attachment", middleware.Auth(func(w http.ResponseWriter, r *http.Request) { handlers.AttachmentDelete(w, r, cfg) }, cfg))
+	r.Get("/api/task/attachments", middleware.Auth(handlers.AttachmentsGet, cfg))
+	r.Post("/api/task/assign", middleware.Auth(handlers.TaskAssignPost, cfg))
+	r.Post("/api/task/accept", middleware.Auth(handlers.TaskAcceptPost, cfg))
+	r.Post("/api/task/decline", middleware.Auth(handlers.TaskDeclinePost, cfg))
+	r.Get("/api/notifications", middleware.Auth(handlers.NotificationsGet, cfg))
+	r.Get("/api/task/graph", middleware.Auth(handlers.TaskGraphGet, cfg))
+	r.Get("/api/task/history", middleware.Auth(handlers.TaskHistoryGet, cfg))
+	r.Get("/api/completed", middleware.Auth(handlers.CompletedGet, cfg))
+	r.Get("/api/audit", middleware.Auth(handlers.AuditGet, cfg))
+	r.Get("/api/admin/backup", middleware.Admin(handlers.BackupGet, cfg))
+	r.Post("/api/admin/restore", middleware.Admin(func(w http.ResponseWriter, r *http.Request) { handlers.RestorePost(w, r, cfg) }, cfg))
+	r.Get("/api/users", middleware.Admin(handlers.UsersGet, cfg))
+	r.Post("/api/user", middleware.Admin(handlers.UserAddPost, cfg))
+	r.Get("/api/export", middleware.Auth(handlers.ExportGet, cfg))
+	r.Post("/api/import", middleware.Auth(handlers.ImportPost, cfg))
+	r.Post("/api/import/ics", middleware.Auth(func(w http.ResponseWriter, r *http.Request) { handlers.ICSImportPost(w, r, cfg) }, cfg))
+	r.Get("/api/sync", middleware.Auth(handlers.SyncGet, cfg))
+	r.Post("/api/sync", middleware.Auth(handlers.SyncPost, cfg))
 	r.Delete("/api/task", middleware.Auth(handlers.TaskHandler, cfg))
+	r.Get("/api/tags", middleware.Auth(handlers.TagsGet, cfg))
+	r.Post("/api/tag", middleware.Auth(handlers.TagHandler, cfg))
+	r.Put("/api/tag", middleware.Auth(handlers.TagHandler, cfg))
+	r.Delete("/api/tag", middleware.Auth(handlers.TagHandler, cfg))
+	r.Get("/api/projects", middleware.Auth(handlers.ProjectsGet, cfg))
+	r.Get("/api/project", middleware.Auth(handlers.ProjectHandler, cfg))
+	r.Post("/api/project", middleware.Auth(handlers.ProjectHandler, cfg))
+	r.Put("/api/project", middleware.Auth(handlers.ProjectHandler, cfg))
+	r.Delete("/api/project", middleware.Auth(handlers.ProjectHandler, cfg))
+	r.Post("/api/project/archive", middleware.Auth(handlers.ProjectArchivePost, cfg))
+	r.Post("/api/share", middleware.Auth(handlers.ShareHandler, cfg))
+	r.Delete("/api/share", middleware.Auth(handlers.ShareHandler, cfg))
+	r.Get("/api/shares", middleware.Auth(handlers.SharesGet, cfg))
+	r.Get("/api/shared", middleware.Auth(handlers.SharedGet, cfg))
+	r.Get("/api/trash", middleware.Auth(handlers.TrashGet, cfg))
+	r.Delete("/api/trash", middleware.Auth(func(w http.ResponseWriter, r *http.Request) { handlers.TrashDelete(w, r, cfg) }, cfg))
+	r.Post("/api/task/restore", middleware.Auth(handlers.TaskRestorePost, cfg))
+	caldav := middleware.BasicAuth(func(w http.ResponseWriter, r *http.Request) { handlers.CalDAVHandler(w, r, cfg) }, cfg)
+	r.Handle("/caldav", caldav)
+	r.Handle("/caldav/*", caldav)
+	r.Handle("/.well-known/caldav", http.RedirectHandler("/caldav/", http.StatusMovedPermanently))
 	r.Post("/api/signin", func(w http.ResponseWriter, r *http.Request) { handlers.SingInHandler(w, r, cfg) })
+	r.Post("/api/signout", middleware.Auth(func(w http.ResponseWriter, r *http.Request) { handlers.SignOutPost(w, r, cfg) }, cfg))
 
 	// Запуск сервера
 	log.Printf("Сервер запущен на порту %v", cfg.Port)
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// runAudit выполняет подкоманды для работы с журналом аудита
+func runAudit(args []string) error {
+	if len(args) == 0 || args[0] != "export" {
+		return errors.New("ожидается подкоманда: audit export")
+	}
+
+	flags := flag.NewFlagSet("audit export", flag.ExitOnError)
+	format := flags.String("format", "json", "формат выгрузки: json или csv")
+	output := flags.String("o", "", "файл для выгрузки, по умолчанию стандартный вывод")
+	taskID := flags.String("task", "", "ID задачи")
+	actor := flags.String("actor", "", "автор изменений")
+	action := flags.String("action", "", "действие: insert, update, done, delete, restore")
+	from := flags.String("from", "", "начальная дата в формате YYYYMMDD")
+	to := flags.String("to", "", "конечная дата в формате YYYYMMDD")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	filter := storage.AuditFilter{TaskID: *taskID, Actor: *actor, Action: *action}
+	if len(*from) > 0 {
+		date, err := time.ParseInLocation(model.DatePat, *from, time.Local)
+		if err != nil {
+			return fmt.Errorf("bad from date: %w", err)
+		}
+		filter.From = date
+	}
+	if len(*to) > 0 {
+		date, err := time.ParseInLocation(model.DatePat, *to, time.Local)
+		if err != nil {
+			return fmt.Errorf("bad to date: %w", err)
+		}
+		filter.To = date.AddDate(0, 0, 1)
+	}
+
+	db, err := storage.OpenDB(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	entries, err := storage.ReadAudit(filter)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if len(*output) > 0 {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(model.AuditEntries{Entries: entries})
+	case "csv":
+		return writeAuditCSV(out, entries)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+// writeAuditCSV записывает записи журнала аудита в формате CSV
+func writeAuditCSV(out io.Writer, entries []model.AuditEntry) error {
+	writer := csv.NewWriter(out)
+	err := writer.Write([]string{"id", "task_id", "action", "actor", "created_at", "request_id", "old", "new"})
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		err := writer.Write([]string{e.ID, e.TaskID, e.Action, e.Actor, e.CreatedAt, e.RequestID,
+			string(e.Old), string(e.New)})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditExport(t *testing.T) {
+	dir := t.TempDir()
+	// База сервера создаётся и обновляется через InitDB, утилита только открывает её
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db, err := storage.InitDB()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	for _, entry := range []model.AuditEntry{
+		{TaskID: "1", Action: model.AuditInsert, Actor: "admin", New: []byte(`{"id":"1","title":"Новая"}`)},
+		{TaskID: "1", Action: model.AuditUpdate, Actor: "caldav",
+			Old: []byte(`{"id":"1","title":"Новая"}`), New: []byte(`{"id":"1","title":"Изменённая"}`)},
+		{TaskID: "2", Action: model.AuditDelete, Actor: "admin", Old: []byte(`{"id":"2","title":"Другая"}`)},
+	} {
+		assert.NoError(t, storage.InsertAudit(entry))
+	}
+
+	// export выгружает записи по фильтру в файл и возвращает его содержимое
+	export := func(args ...string) []byte {
+		output := filepath.Join(dir, "audit.out")
+		assert.NoError(t, runAudit(append([]string{"export", "-o", output}, args...)))
+		data, err := os.ReadFile(output)
+		assert.NoError(t, err)
+		return data
+	}
+
+	var ret model.AuditEntries
+	assert.NoError(t, json.Unmarshal(export("-task", "1"), &ret))
+	if assert.Len(t, ret.Entries, 2) {
+		update := ret.Entries[0]
+		assert.Equal(t, model.AuditUpdate, update.Action)
+		assert.Equal(t, "caldav", update.Actor)
+		assert.JSONEq(t, `{"id":"1","title":"Новая"}`, string(update.Old))
+		assert.JSONEq(t, `{"id":"1","title":"Изменённая"}`, string(update.New))
+		assert.Equal(t, model.AuditInsert, ret.Entries[1].Action)
+		assert.Empty(t, ret.Entries[1].Old)
+	}
+
+	assert.NoError(t, json.Unmarshal(export("-actor", "admin", "-action", "delete"), &ret))
+	if assert.Len(t, ret.Entries, 1) {
+		assert.Equal(t, "2", ret.Entries[0].TaskID)
+	}
+	assert.NoError(t, json.Unmarshal(export("-from", "20000101", "-to", "20000101"), &ret))
+	assert.Empty(t, ret.Entries)
+
+	records, err := csv.NewReader(bytes.NewReader(export("-format", "csv", "-task", "1"))).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, []string{"id", "task_id", "action", "actor", "created_at", "request_id", "old", "new"}, records[0])
+		assert.Equal(t, []string{"1", "update", "caldav"}, records[1][1:4])
+		assert.Equal(t, `{"id":"1","title":"Изменённая"}`, records[1][7])
+		assert.Empty(t, records[2][6])
+	}
+
+	assert.Error(t, runAudit([]string{"export", "-format", "xml"}))
+	assert.Error(t, runAudit([]string{"export", "-from", "2000-01-01"}))
+	assert.Error(t, runAudit([]string{"import"}))
+
+	// Утилита не создаёт базу по неверному пути
+	missing := filepath.Join(dir, "missing.db")
+	t.Setenv("TODO_DBFILE", missing)
+	assert.Error(t, runAudit([]string{"export", "-o", filepath.Join(dir, "missing.out")}))
+	assert.NoFileExists(t, missing)
+}
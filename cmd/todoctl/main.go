@@ -0,0 +1,49 @@
+// Утилита командной строки для обслуживания базы задач.
+//
+// Использование:
+//
+//	todoctl audit export [-format json|csv] [-task ID] [-actor NAME] [-action ACTION] [-from YYYYMMDD] [-to YYYYMMDD] [-o FILE]
+//	todoctl todotxt export [-user NAME] [-o FILE]
+//	todoctl todotxt import [-user NAME] [-dry-run] [-mode skip|overwrite] [FILE]
+//
+// Утилита работает с базой сервера TODO_DBFILE: она не создаёт базу и не меняет её схему,
+// а выгрузки и пробная загрузка открывают базу только для чтения
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `Использование:
+  todoctl audit export [флаги]     выгрузить журнал аудита
+  todoctl todotxt export [флаги]   выгрузить задачи в формате todo.txt
+  todoctl todotxt import [флаги] [файл]   загрузить задачи из файла todo.txt
+`
+
+func main() {
+	// Переменные окружения из .env не перекрывают уже заданные
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "audit":
+		err = runAudit(os.Args[2:])
+	case "todotxt":
+		err = runTodoTxt(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/internal/transfer"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// runTodoTxt выполняет подкоманды для выгрузки и загрузки задач в формате todo.txt
+func runTodoTxt(args []string) error {
+	if len(args) == 0 {
+		return errors.New("ожидается подкоманда: todotxt export или todotxt import")
+	}
+	switch args[0] {
+	case "export":
+		return exportTodoTxt(args[1:])
+	case "import":
+		return importTodoTxt(args[1:])
+	}
+	return fmt.Errorf("неизвестная подкоманда todotxt %q", args[0])
+}
+
+func exportTodoTxt(args []string) error {
+	flags := flag.NewFlagSet("todotxt export", flag.ExitOnError)
+	output := flags.String("o", "", "файл для выгрузки, по умолчанию стандартный вывод")
+	userName := flags.String("user", model.AdminName, "пользователь, задачи которого выгружаются")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	db, err := storage.OpenDB(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	user, err := findUser(*userName)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if len(*output) > 0 {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	return transfer.Export(out, user.ID, transfer.FormatTodoTxt)
+}
+
+func importTodoTxt(args []string) error {
+	flags := flag.NewFlagSet("todotxt import", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "только проверить файл, ничего не сохраняя")
+	mode := flags.String("mode", transfer.ModeSkip, "обработка дубликатов: skip или overwrite")
+	userName := flags.String("user", model.AdminName, "пользователь, которому добавляются задачи")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// Пробная загрузка ничего не сохраняет, поэтому ей достаточно чтения
+	db, err := storage.OpenDB(*dryRun)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	user, err := findUser(*userName)
+	if err != nil {
+		return err
+	}
+	if *mode != transfer.ModeSkip && *mode != transfer.ModeOverwrite {
+		return fmt.Errorf("unknown mode %q", *mode)
+	}
+
+	var in io.Reader = os.Stdin
+	if flags.NArg() > 0 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	rows, err := transfer.Decode(in, transfer.FormatTodoTxt)
+	if err != nil {
+		return err
+	}
+	report := transfer.ImportRows(rows, transfer.Options{
+		DryRun: *dryRun,
+		Mode:   *mode,
+		UserID: user.ID,
+		OnChange: func(action string, oldTask *model.Task, newTask model.Task) {
+			recordAudit(user.ID, action, oldTask, newTask)
+		},
+	})
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// findUser ищет пользователя по имени, указанному в параметре -user
+func findUser(name string) (model.User, error) {
+	user, err := storage.FindUserByName(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.User{}, fmt.Errorf("пользователь %q не найден", name)
+	}
+	return user, err
+}
+
+// recordAudit записывает изменение задачи, сделанное утилитой, в журнал аудита
+func recordAudit(userID string, action string, oldTask *model.Task, newTask model.Task) {
+	entry := model.AuditEntry{TaskID: newTask.ID, Action: action, Actor: "todoctl", UserID: userID}
+	if oldTask != nil {
+		entry.Old, _ = json.Marshal(oldTask)
+	}
+	entry.New, _ = json.Marshal(newTask)
+	if err := storage.InsertAudit(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write audit entry for task with id=%s: %v\n", newTask.ID, err)
+	}
+}
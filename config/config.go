@@ -1,6 +1,32 @@
 package config
 
+import "time"
+
 type Config struct {
 	TodoPassword string
 	Port         string
+	// Location часовой пояс, в котором вычисляются "сегодня" и границы недели
+	Location *time.Location
+	// WeekStart первый день недели
+	WeekStart time.Weekday
+	// TrashRetention срок хранения задач в корзине, 0 - хранить бессрочно
+	TrashRetention time.Duration
+	// BackupDir каталог для резервных копий базы данных
+	BackupDir string
+	// BackupInterval период автоматического резервного копирования, 0 - копирование отключено
+	BackupInterval time.Duration
+	// BackupKeepDaily количество хранимых ежедневных копий
+	BackupKeepDaily int
+	// BackupKeepWeekly количество хранимых еженедельных копий
+	BackupKeepWeekly int
+	// ICSToken токен подписки на календарь задач администратора, пустой - токен не действует
+	ICSToken string
+	// AttachDir каталог для файлов вложений задач
+	AttachDir string
+	// AttachMaxSize максимальный размер вложения в байтах
+	AttachMaxSize int64
+	// JWTSecret ключ подписи токенов, отдельный от пароля администратора
+	JWTSecret string
+	// TokenTTL срок действия токена
+	TokenTTL time.Duration
 }
@@ -0,0 +1,127 @@
+// Package attachments хранит файлы вложений задач в локальном каталоге.
+// В базе данных хранится только имя файла, которое выдаёт Save
+package attachments
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// ErrTooLarge возвращается, если файл больше допустимого размера
+var ErrTooLarge = errors.New("attachment is too large")
+
+// sniffLen количество байт, по которым http.DetectContentType определяет тип содержимого
+const sniffLen = 512
+
+// maxNameLength максимальная длина имени вложения в символах
+const maxNameLength = 255
+
+// Store каталог с файлами вложений
+type Store struct {
+	// Dir каталог, в котором хранятся файлы
+	Dir string
+	// MaxSize максимальный размер файла в байтах
+	MaxSize int64
+}
+
+// Save сохраняет содержимое r в новый файл и возвращает его имя, размер и тип содержимого,
+// определённый по первым байтам файла. Тип, указанный клиентом, не учитывается
+func (s Store) Save(r io.Reader) (string, int64, string, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", 0, "", err
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", 0, "", err
+	}
+	head = head[:n]
+
+	size, err := io.Copy(tmp, io.LimitReader(io.MultiReader(bytes.NewReader(head), r), s.MaxSize+1))
+	if err != nil {
+		return "", 0, "", err
+	}
+	if size > s.MaxSize {
+		return "", 0, "", fmt.Errorf("%w: limit is %d bytes", ErrTooLarge, s.MaxSize)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, "", err
+	}
+
+	file, err := newFileName()
+	if err != nil {
+		return "", 0, "", err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.Dir, file)); err != nil {
+		return "", 0, "", err
+	}
+	return file, size, http.DetectContentType(head), nil
+}
+
+// Open открывает файл вложения
+func (s Store) Open(file string) (*os.File, error) {
+	return os.Open(s.path(file))
+}
+
+// Remove удаляет файлы вложений. Ошибки записываются в журнал, так как к этому моменту
+// записи о вложениях уже удалены из базы
+func (s Store) Remove(files ...string) {
+	for _, file := range files {
+		if err := os.Remove(s.path(file)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("failed to remove attachment file %s: %v", file, err)
+		}
+	}
+}
+
+// path возвращает путь к файлу вложения. Имя файла не может выйти за пределы каталога
+func (s Store) path(file string) string {
+	return filepath.Join(s.Dir, filepath.Base(file))
+}
+
+// newFileName возвращает случайное имя файла
+func newFileName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CleanName оставляет от имени файла, переданного клиентом, только последний элемент пути
+// без управляющих символов
+func CleanName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" {
+		name = ""
+	}
+	if runes := []rune(name); len(runes) > maxNameLength {
+		name = string(runes[:maxNameLength])
+	}
+	if len(strings.TrimSpace(name)) == 0 {
+		return "attachment"
+	}
+	return name
+}
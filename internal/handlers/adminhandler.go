@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/config"
+	"github.com/Zelvalna/go_final_project/internal/jobs"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// maxRestoreSize ограничивает размер загружаемой для восстановления базы данных
+const maxRestoreSize = 512 << 20
+
+// BackupGet отдаёт согласованную копию базы данных, снятую во время работы сервера
+func BackupGet(w http.ResponseWriter, r *http.Request) {
+	dir, err := os.MkdirTemp("", "scheduler-backup-")
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to create backup", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "scheduler.db")
+	if err := storage.Snapshot(path); err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to create backup", err)
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to open backup", err)
+		return
+	}
+	defer file.Close()
+
+	filename := fmt.Sprintf("scheduler-%s.db", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("writing backup error: %v", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Sent backup %s", filename))
+}
+
+// RestorePost проверяет загруженную копию базы данных и заменяет ею текущую базу.
+// Старая копия сначала обновляется до текущей схемы. Перед заменой текущая база сохраняется
+// в каталог резервных копий, и если заменить базу не удалось, она возвращается из этой копии
+func RestorePost(w http.ResponseWriter, r *http.Request, cfg config.Config) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRestoreSize)
+	upload, err := openUpload(r, "file")
+	if err != nil {
+		setErrorResponse(w, "failed to read upload", err)
+		return
+	}
+	defer upload.Close()
+
+	dir, err := os.MkdirTemp("", "scheduler-restore-")
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to save upload", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "scheduler.db")
+	if err := saveFile(path, upload); err != nil {
+		setErrorResponse(w, "failed to save upload", err)
+		return
+	}
+
+	version, err := storage.VerifySnapshot(path)
+	if err != nil {
+		setErrorResponse(w, "invalid database", err)
+		return
+	}
+	// Старая копия обновляется до текущей схемы во временном файле, до замены текущей базы
+	if err := storage.MigrateSnapshot(path); err != nil {
+		setErrorResponse(w, "failed to migrate database", err)
+		return
+	}
+
+	if err := os.MkdirAll(cfg.BackupDir, 0o755); err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to create backup directory", err)
+		return
+	}
+	snapshot := jobs.PreRestorePath(cfg.BackupDir, time.Now())
+	if err := storage.Snapshot(snapshot); err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to create pre-restore snapshot", err)
+		return
+	}
+
+	if err := storage.Restore(path); err != nil {
+		// Если замена не удалась, возвращаем базу из только что снятой копии
+		if rollbackErr := storage.Restore(snapshot); rollbackErr != nil {
+			log.Printf("rollback from %s failed: %v", snapshot, rollbackErr)
+		}
+		setErrorStatus(w, http.StatusInternalServerError, "failed to restore database", err)
+		return
+	}
+	// В восстановленной базе вход администратора остаётся по паролю TODO_PASSWORD
+	if err := storage.SetAdminPassword(cfg.TodoPassword); err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to set admin password", err)
+		return
+	}
+	// Копии перед восстановлением хранятся по той же политике, что и плановые копии
+	err = jobs.Rotate(jobs.BackupPolicy{Dir: cfg.BackupDir, KeepDaily: cfg.BackupKeepDaily, KeepWeekly: cfg.BackupKeepWeekly})
+	if err != nil {
+		log.Printf("failed to rotate backups: %v", err)
+	}
+
+	jsonResponse(w, http.StatusOK)
+	response := model.RestoreResponse{SchemaVersion: version, Snapshot: snapshot}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Restored database with schema version %d, previous database saved to %s", version, snapshot))
+}
+
+// saveFile записывает содержимое src в новый файл path
+func saveFile(path string, src io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, src); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/config"
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// AgendaGet возвращает задачи, разложенные по срокам: просроченные, сегодня, завтра,
+// до конца недели и позже. Повторяющиеся задачи разворачиваются в повторения
+func AgendaGet(w http.ResponseWriter, r *http.Request, cfg config.Config) {
+	userID := middleware.UserID(r.Context())
+	tasks, err := storage.ReadTasks(userID, storage.TaskFilter{})
+	if err != nil {
+		setErrorResponse(w, "failed to get tasks", err)
+		return
+	}
+
+	agenda := buildAgenda(tasks, time.Now().In(cfg.Location), cfg.WeekStart)
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(agenda); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Read agenda for %s", agenda.Date))
+}
+
+// buildAgenda раскладывает задачи по корзинам относительно текущего момента now
+func buildAgenda(tasks []model.Task, now time.Time, weekStart time.Weekday) model.Agenda {
+	// Даты задач хранятся без часового пояса, поэтому сравниваем их с датой now в UTC
+	today, _ := time.Parse(model.DatePat, now.Format(model.DatePat))
+	tomorrow := today.AddDate(0, 0, 1)
+	weekEnd := today.AddDate(0, 0, 6-(int(today.Weekday())-int(weekStart)+7)%7)
+	horizon := weekEnd
+	if horizon.Before(tomorrow) {
+		horizon = tomorrow
+	}
+
+	todayStr := today.Format(model.DatePat)
+	tomorrowStr := tomorrow.Format(model.DatePat)
+	horizonStr := horizon.Format(model.DatePat)
+
+	agenda := model.Agenda{
+		Date:     todayStr,
+		Overdue:  model.AgendaBucket{Tasks: []model.Task{}},
+		Today:    model.AgendaBucket{Tasks: []model.Task{}},
+		Tomorrow: model.AgendaBucket{Tasks: []model.Task{}},
+		Week:     model.AgendaBucket{Tasks: []model.Task{}},
+		Later:    model.AgendaBucket{Tasks: []model.Task{}},
+	}
+
+	for _, task := range tasks {
+		// Просроченная задача, в том числе повторяющаяся, показывается только среди просроченных,
+		// пока её не выполнят
+		if task.Date < todayStr {
+			agenda.Overdue.Tasks = append(agenda.Overdue.Tasks, task)
+			continue
+		}
+
+		occurrences, err := dates.Occurrences(task.Date, task.Repeat, today, horizon)
+		if err != nil {
+			log.Printf("failed to expand task with id=%s: %v", task.ID, err)
+			continue
+		}
+		for _, date := range occurrences {
+			occurrence := task
+			occurrence.Date = date
+			switch date {
+			case todayStr:
+				agenda.Today.Tasks = append(agenda.Today.Tasks, occurrence)
+			case tomorrowStr:
+				agenda.Tomorrow.Tasks = append(agenda.Tomorrow.Tasks, occurrence)
+			default:
+				agenda.Week.Tasks = append(agenda.Week.Tasks, occurrence)
+			}
+		}
+
+		// В "позже" попадают задачи, срок которых за пределами недели
+		if task.Date > horizonStr {
+			agenda.Later.Tasks = append(agenda.Later.Tasks, task)
+		}
+	}
+
+	for _, bucket := range []*model.AgendaBucket{&agenda.Overdue, &agenda.Today, &agenda.Tomorrow, &agenda.Week, &agenda.Later} {
+		sortTasksByDate(bucket.Tasks)
+		bucket.Count = len(bucket.Tasks)
+	}
+	return agenda
+}
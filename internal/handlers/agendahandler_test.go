@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// bucketDates возвращает пары "id дата" задач корзины
+func bucketDates(bucket model.AgendaBucket) []string {
+	result := []string{}
+	for _, task := range bucket.Tasks {
+		result = append(result, task.ID+" "+task.Date)
+	}
+	return result
+}
+
+func TestBuildAgenda(t *testing.T) {
+	// 19 октября 2026 года - понедельник
+	monday := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		weekStart time.Weekday
+		tasks     []model.Task
+		date      string
+		overdue   []string
+		today     []string
+		tomorrow  []string
+		week      []string
+		later     []string
+	}{
+		{
+			name:      "просроченная повторяющаяся задача только среди просроченных",
+			now:       monday,
+			weekStart: time.Monday,
+			tasks:     []model.Task{{ID: "1", Date: "20261015", Repeat: "d 1"}},
+			date:      "20261019",
+			overdue:   []string{"1 20261015"},
+		},
+		{
+			name:      "неделя с понедельника",
+			now:       monday,
+			weekStart: time.Monday,
+			tasks: []model.Task{
+				{ID: "1", Date: "20261019"},
+				{ID: "2", Date: "20261020"},
+				{ID: "3", Date: "20261025"},
+				{ID: "4", Date: "20261026"},
+				{ID: "5", Date: "20261021", Repeat: "w 3,7"},
+			},
+			date:     "20261019",
+			today:    []string{"1 20261019"},
+			tomorrow: []string{"2 20261020"},
+			week:     []string{"5 20261021", "3 20261025", "5 20261025"},
+			later:    []string{"4 20261026"},
+		},
+		{
+			name:      "неделя с воскресенья",
+			now:       monday,
+			weekStart: time.Sunday,
+			tasks: []model.Task{
+				{ID: "1", Date: "20261024"},
+				{ID: "2", Date: "20261025"},
+			},
+			date:  "20261019",
+			week:  []string{"1 20261024"},
+			later: []string{"2 20261025"},
+		},
+		{
+			name:      "последний день недели",
+			now:       monday.AddDate(0, 0, -1),
+			weekStart: time.Monday,
+			tasks: []model.Task{
+				{ID: "1", Date: "20261018", Repeat: "d 1"},
+				{ID: "2", Date: "20261020"},
+			},
+			date:     "20261018",
+			today:    []string{"1 20261018"},
+			tomorrow: []string{"1 20261019"},
+			later:    []string{"2 20261020"},
+		},
+		{
+			name:      "сегодня считается в часовом поясе пользователя",
+			now:       time.Date(2026, 10, 18, 22, 30, 0, 0, time.UTC).In(moscow),
+			weekStart: time.Monday,
+			tasks: []model.Task{
+				{ID: "1", Date: "20261018"},
+				{ID: "2", Date: "20261019"},
+			},
+			date:    "20261019",
+			overdue: []string{"1 20261018"},
+			today:   []string{"2 20261019"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agenda := buildAgenda(tt.tasks, tt.now, tt.weekStart)
+
+			assert.Equal(t, tt.date, agenda.Date)
+			for _, bucket := range []struct {
+				name string
+				got  model.AgendaBucket
+				want []string
+			}{
+				{"overdue", agenda.Overdue, tt.overdue},
+				{"today", agenda.Today, tt.today},
+				{"tomorrow", agenda.Tomorrow, tt.tomorrow},
+				{"week", agenda.Week, tt.week},
+				{"later", agenda.Later, tt.later},
+			} {
+				want := bucket.want
+				if want == nil {
+					want = []string{}
+				}
+				assert.Equal(t, want, bucketDates(bucket.got), bucket.name)
+				assert.Equal(t, len(want), bucket.got.Count, bucket.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+const (
+	defNotificationsLimit = 100
+	maxNotificationsLimit = 500
+)
+
+// setAssignmentError отправляет ответ об ошибке назначения задачи
+func setAssignmentError(w http.ResponseWriter, s string, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		setErrorStatus(w, http.StatusNotFound, s, errTaskNotActive)
+	case errors.Is(err, storage.ErrForbidden):
+		setErrorStatus(w, http.StatusForbidden, s, err)
+	case errors.Is(err, storage.ErrAssignmentState):
+		setErrorStatus(w, http.StatusConflict, s, err)
+	default:
+		setErrorResponse(w, s, err)
+	}
+}
+
+// writeAssignedTask отправляет задачу после изменения назначения и записывает изменение в журнал аудита
+func writeAssignedTask(w http.ResponseWriter, r *http.Request, action string, oldTask, task model.Task) {
+	if task.Version != oldTask.Version {
+		recordAudit(r, action, task.ID, &oldTask, &task)
+	}
+
+	w.Header().Set("ETag", etag(task.Version))
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(task); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Task with id=%s: %s, assignee=%q status=%q", task.ID, action, task.Assignee, task.AssigneeStatus))
+}
+
+// TaskAssignPost назначает задачу исполнителю user, а без user снимает назначение
+func TaskAssignPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+	assignee := strings.TrimSpace(r.URL.Query().Get("user"))
+
+	oldTask, task, err := storage.AssignTask(userID, id, assignee, time.Now())
+	if err != nil {
+		setAssignmentError(w, "failed to assign task", err)
+		return
+	}
+	writeAssignedTask(w, r, model.AuditAssign, oldTask, task)
+}
+
+// TaskAcceptPost принимает назначенную пользователю задачу
+func TaskAcceptPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+
+	oldTask, task, err := storage.AnswerAssignment(userID, id, true, time.Now())
+	if err != nil {
+		setAssignmentError(w, "failed to accept task", err)
+		return
+	}
+	writeAssignedTask(w, r, model.AuditAccept, oldTask, task)
+}
+
+// TaskDeclinePost отказывается от назначенной пользователю задачи
+func TaskDeclinePost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+
+	oldTask, task, err := storage.AnswerAssignment(userID, id, false, time.Now())
+	if err != nil {
+		setAssignmentError(w, "failed to decline task", err)
+		return
+	}
+	writeAssignedTask(w, r, model.AuditDecline, oldTask, task)
+}
+
+// NotificationsGet возвращает уведомления пользователя об изменениях назначений с ID больше since
+func NotificationsGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var since int64
+	if value := r.URL.Query().Get("since"); len(value) > 0 {
+		var err error
+		if since, err = strconv.ParseInt(value, 10, 64); err != nil || since < 0 {
+			setErrorResponse(w, "invalid since", fmt.Errorf("bad id %q", value))
+			return
+		}
+	}
+	limit := defNotificationsLimit
+	if value := r.URL.Query().Get("limit"); len(value) > 0 {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxNotificationsLimit {
+			setErrorResponse(w, "invalid limit", fmt.Errorf("limit must be between 1 and %d", maxNotificationsLimit))
+			return
+		}
+		limit = n
+	}
+
+	notifications, err := storage.ReadNotifications(userID, since, limit)
+	if err != nil {
+		setErrorResponse(w, "failed to get notifications", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.Notifications{Notifications: notifications}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Read %d notifications since %d", len(notifications), since))
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/config"
+	"github.com/Zelvalna/go_final_project/internal/attachments"
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// multipartOverhead запас на заголовки multipart сверх максимального размера файла
+const multipartOverhead = 1 << 20
+
+// attachmentStore возвращает хранилище вложений из настроек
+func attachmentStore(cfg config.Config) attachments.Store {
+	return attachments.Store{Dir: cfg.AttachDir, MaxSize: cfg.AttachMaxSize}
+}
+
+// AttachmentPost прикрепляет к задаче файл из поля file формы multipart/form-data
+func AttachmentPost(w http.ResponseWriter, r *http.Request, cfg config.Config) {
+	userID := middleware.UserID(r.Context())
+	taskID := r.URL.Query().Get("id")
+	if _, err := storage.GetTaskById(userID, taskID); err != nil {
+		setErrorStatus(w, http.StatusNotFound, "failed to attach file", errTaskNotActive)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.AttachMaxSize+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		setErrorResponse(w, "failed to read upload", err)
+		return
+	}
+	var part io.Reader
+	var name string
+	for {
+		p, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			setErrorResponse(w, "failed to read upload", errors.New("file field is missing"))
+			return
+		}
+		if err != nil {
+			setUploadError(w, err)
+			return
+		}
+		if p.FormName() == "file" {
+			part, name = p, p.FileName()
+			break
+		}
+	}
+
+	store := attachmentStore(cfg)
+	file, size, contentType, err := store.Save(part)
+	if err != nil {
+		setUploadError(w, err)
+		return
+	}
+	attachment, err := storage.InsertAttachment(userID, model.Attachment{
+		TaskID:      taskID,
+		Name:        attachments.CleanName(name),
+		ContentType: contentType,
+		Size:        size,
+		File:        file,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		store.Remove(file)
+		if errors.Is(err, storage.ErrForbidden) {
+			setErrorStatus(w, http.StatusForbidden, "failed to attach file", err)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			setErrorStatus(w, http.StatusNotFound, "failed to attach file", errTaskNotActive)
+			return
+		}
+		setErrorStatus(w, http.StatusInternalServerError, "failed to attach file", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(attachment); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Attached %s (%d bytes, %s) to task with id=%s",
+		attachment.Name, attachment.Size, attachment.ContentType, taskID))
+}
+
+// setUploadError отправляет ответ об ошибке загрузки файла, слишком большой файл - 413
+func setUploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, attachments.ErrTooLarge) || errors.As(err, &maxBytesErr) {
+		setErrorStatus(w, http.StatusRequestEntityTooLarge, "failed to attach file", attachments.ErrTooLarge)
+		return
+	}
+	setErrorResponse(w, "failed to read upload", err)
+}
+
+// AttachmentsGet возвращает вложения задачи
+func AttachmentsGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	taskID := r.URL.Query().Get("id")
+	if _, err := storage.FindTask(userID, taskID); err != nil {
+		setErrorStatus(w, http.StatusNotFound, "failed to get attachments", errors.New("task not found"))
+		return
+	}
+
+	list, err := storage.ReadAttachments(userID, taskID)
+	if err != nil {
+		setErrorResponse(w, "failed to get attachments", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.Attachments{Attachments: list}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Read %d attachments of task with id=%s", len(list), taskID))
+}
+
+// AttachmentGet отдаёт файл вложения. Тип содержимого берётся из определённого при
+// загрузке, а браузеру запрещено определять его заново и открывать файл на странице
+func AttachmentGet(w http.ResponseWriter, r *http.Request, cfg config.Config) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("attachment")
+
+	attachment, err := storage.FindAttachment(userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to get attachment", errors.New("attachment not found"))
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to get attachment", err)
+		return
+	}
+	file, err := attachmentStore(cfg).Open(attachment.File)
+	if err != nil {
+		setErrorStatus(w, http.StatusNotFound, "failed to get attachment", err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to get attachment", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	http.ServeContent(w, r, attachment.Name, info.ModTime(), file)
+}
+
+// AttachmentDelete удаляет вложение вместе с файлом
+func AttachmentDelete(w http.ResponseWriter, r *http.Request, cfg config.Config) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("attachment")
+
+	attachment, err := storage.DeleteAttachment(userID, id)
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to delete attachment", err)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to delete attachment", errors.New("attachment not found"))
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to delete attachment", err)
+		return
+	}
+	attachmentStore(cfg).Remove(attachment.File)
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Deleted attachment with id=%s", id))
+}
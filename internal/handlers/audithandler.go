@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/model"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	defAuditLimit = 100
+	maxAuditLimit = 1000
+)
+
+// recordAudit записывает изменение задачи в журнал аудита. Изменение к этому моменту
+// уже сохранено, поэтому ошибка записи в журнал только логируется
+func recordAudit(r *http.Request, action string, taskID string, oldTask, newTask *model.Task) {
+	entry := model.AuditEntry{
+		TaskID:    taskID,
+		Action:    action,
+		Actor:     middleware.Actor(r.Context()),
+		UserID:    middleware.UserID(r.Context()),
+		RequestID: chimiddleware.GetReqID(r.Context()),
+	}
+	if oldTask != nil {
+		entry.Old, _ = json.Marshal(oldTask)
+	}
+	if newTask != nil {
+		entry.New, _ = json.Marshal(newTask)
+	}
+
+	if err := storage.InsertAudit(entry); err != nil {
+		log.Printf("failed to write audit entry for task with id=%s: %v", taskID, err)
+	}
+}
+
+// AuditGet возвращает записи журнала аудита пользователя с фильтрацией по задаче, автору,
+// действию и диапазону дат
+func AuditGet(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := storage.AuditFilter{
+		TaskID: query.Get("task_id"),
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		UserID: middleware.UserID(r.Context()),
+		Limit:  defAuditLimit,
+	}
+
+	if len(query.Get("limit")) > 0 {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			setErrorResponse(w, "invalid limit", fmt.Errorf("limit must be between 1 and %d", maxAuditLimit))
+			return
+		}
+		filter.Limit = limit
+	}
+	if len(query.Get("from")) > 0 {
+		from, err := time.ParseInLocation(model.DatePat, query.Get("from"), time.Local)
+		if err != nil {
+			setErrorResponse(w, "bad from date", err)
+			return
+		}
+		filter.From = from
+	}
+	if len(query.Get("to")) > 0 {
+		to, err := time.ParseInLocation(model.DatePat, query.Get("to"), time.Local)
+		if err != nil {
+			setErrorResponse(w, "bad to date", err)
+			return
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	entries, err := storage.ReadAudit(filter)
+	if err != nil {
+		setErrorResponse(w, "failed to get audit log", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.AuditEntries{Entries: entries}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Read %d audit entries", len(entries)))
+}
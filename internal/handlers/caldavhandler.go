@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/config"
+	"github.com/Zelvalna/go_final_project/internal/ical"
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+const (
+	// caldavRoot адрес принципала и домашнего каталога календарей
+	caldavRoot = "/caldav/"
+	// caldavCollection адрес единственной коллекции задач
+	caldavCollection = "/caldav/tasks/"
+	// maxResourceSize ограничивает размер ресурса, загружаемого клиентом CalDAV
+	maxResourceSize = 1 << 20
+)
+
+// caldavResource задача коллекции CalDAV вместе с именем её ресурса и UID
+type caldavResource struct {
+	model.CalDAVResource
+	Task model.Task
+}
+
+// href возвращает адрес ресурса задачи
+func (res caldavResource) href() string {
+	return caldavCollection + res.Name
+}
+
+// CalDAVHandler обслуживает минимальный сервер CalDAV с одной коллекцией задач VTODO.
+// Ресурс задачи называется "<id>.ics", если его не создал клиент под своим именем
+func CalDAVHandler(w http.ResponseWriter, r *http.Request, cfg config.Config) {
+	w.Header().Set("DAV", "1, 3, calendar-access")
+
+	path := r.URL.Path
+	switch {
+	case path == "/caldav" || path == caldavRoot:
+		switch r.Method {
+		case http.MethodOptions:
+			caldavOptions(w, "OPTIONS, PROPFIND")
+		case "PROPFIND":
+			propfindRoot(w, r)
+		default:
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		}
+	case path == strings.TrimSuffix(caldavCollection, "/") || path == caldavCollection:
+		switch r.Method {
+		case http.MethodOptions:
+			caldavOptions(w, "OPTIONS, PROPFIND, REPORT")
+		case "PROPFIND":
+			propfindCollection(w, r)
+		case "REPORT":
+			reportCollection(w, r)
+		default:
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		}
+	case strings.HasPrefix(path, caldavCollection) && !strings.Contains(path[len(caldavCollection):], "/"):
+		name := path[len(caldavCollection):]
+		switch r.Method {
+		case http.MethodOptions:
+			caldavOptions(w, "OPTIONS, PROPFIND, GET, HEAD, PUT, DELETE")
+		case "PROPFIND":
+			propfindResource(w, r, name)
+		case http.MethodGet, http.MethodHead:
+			caldavGet(w, r, name)
+		case http.MethodPut:
+			caldavPut(w, r, name, cfg)
+		case http.MethodDelete:
+			caldavDelete(w, r, name)
+		default:
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func caldavOptions(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	w.WriteHeader(http.StatusOK)
+}
+
+// readCalDAVResources возвращает все задачи, кроме находящихся в корзине, как ресурсы коллекции
+func readCalDAVResources(userID string) ([]caldavResource, error) {
+	tasks, err := storage.ReadAllTasks(userID)
+	if err != nil {
+		return nil, err
+	}
+	named, err := storage.ReadCalDAVResources(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]caldavResource, 0, len(tasks))
+	for _, task := range tasks {
+		resource, ok := named[task.ID]
+		if !ok {
+			resource = model.CalDAVResource{TaskID: task.ID, Name: task.ID + ".ics", UID: ical.UID(task.ID)}
+		}
+		resources = append(resources, caldavResource{CalDAVResource: resource, Task: task})
+	}
+	return resources, nil
+}
+
+// findCalDAVResource ищет ресурс по имени. Для отсутствующего ресурса возвращается sql.ErrNoRows
+func findCalDAVResource(userID string, name string) (caldavResource, error) {
+	resource, err := storage.FindCalDAVResource(userID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		id, ok := strings.CutSuffix(name, ".ics")
+		if _, convErr := strconv.Atoi(id); !ok || convErr != nil {
+			return caldavResource{}, sql.ErrNoRows
+		}
+		resource = model.CalDAVResource{TaskID: id, Name: name, UID: ical.UID(id)}
+	} else if err != nil {
+		return caldavResource{}, err
+	}
+
+	task, err := storage.FindTask(userID, resource.TaskID)
+	if err != nil {
+		return caldavResource{}, err
+	}
+	if len(task.DeletedAt) > 0 {
+		return caldavResource{}, sql.ErrNoRows
+	}
+	return caldavResource{CalDAVResource: resource, Task: task}, nil
+}
+
+// calendarData возвращает ресурс задачи в формате iCalendar
+func calendarData(res caldavResource) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ical.WriteResource(&buf, res.Task, res.UID, time.Now()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkCalDAVPrecondition проверяет заголовки If-Match и If-None-Match для существующего ресурса
+func checkCalDAVPrecondition(w http.ResponseWriter, r *http.Request, task model.Task) bool {
+	version, _ := expectedVersion(r, "")
+	if strings.TrimSpace(r.Header.Get("If-None-Match")) == anyVersion ||
+		(len(version) > 0 && version != anyVersion && version != task.Version) {
+		w.Header().Set("ETag", etag(task.Version))
+		setErrorStatus(w, http.StatusPreconditionFailed, "precondition failed",
+			fmt.Errorf("current version is %s", task.Version))
+		return false
+	}
+	return true
+}
+
+func caldavGet(w http.ResponseWriter, r *http.Request, name string) {
+	userID := middleware.UserID(r.Context())
+	res, err := findCalDAVResource(userID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to get task", err)
+		return
+	}
+
+	data, err := calendarData(res)
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to encode task", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("ETag", etag(res.Task.Version))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(data); err != nil {
+		log.Printf("writing calendar resource error: %v", err)
+	}
+}
+
+// caldavPut создаёт или изменяет задачу по ресурсу VTODO. Если клиент отметил задачу
+// (или одно из её повторений) выполненной, задача выполняется так же, как через /api/task/done
+func caldavPut(w http.ResponseWriter, r *http.Request, name string, cfg config.Config) {
+	userID := middleware.UserID(r.Context())
+	r.Body = http.MaxBytesReader(w, r.Body, maxResourceSize)
+	components, err := ical.ReadComponents(r.Body)
+	if err != nil {
+		setErrorResponse(w, "failed to parse calendar", err)
+		return
+	}
+
+	var master *ical.Component
+	completed := false
+	for i, component := range components {
+		if component.Name != ical.ComponentTodo {
+			setErrorStatus(w, http.StatusForbidden, "unsupported calendar component",
+				fmt.Errorf("only VTODO is supported, got %s", component.Name))
+			return
+		}
+		if _, ok := component.Props["RECURRENCE-ID"]; ok {
+			completed = completed || component.Completed()
+			continue
+		}
+		if master == nil {
+			master = &components[i]
+		}
+	}
+	if master == nil {
+		setErrorResponse(w, "failed to parse calendar", errors.New("VTODO is missing"))
+		return
+	}
+
+	item := ical.Items([]ical.Component{*master}, cfg.Location)[0]
+	if item.Err != nil {
+		setErrorStatus(w, http.StatusForbidden, "unsupported task", item.Err)
+		return
+	}
+	task := item.Task
+	completed = completed || task.Done
+	task.Done = false
+
+	res, err := findCalDAVResource(userID, name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		caldavCreate(w, r, name, master.Props["UID"].Value, task, completed)
+	case err != nil:
+		setErrorStatus(w, http.StatusInternalServerError, "failed to get task", err)
+	default:
+		caldavUpdate(w, r, res, task, completed)
+	}
+}
+
+func caldavCreate(w http.ResponseWriter, r *http.Request, name string, uid string, task model.Task, completed bool) {
+	userID := middleware.UserID(r.Context())
+	if len(r.Header.Get("If-Match")) > 0 {
+		setErrorStatus(w, http.StatusPreconditionFailed, "precondition failed", errors.New("resource does not exist"))
+		return
+	}
+	if len(uid) == 0 {
+		uid = strings.TrimSuffix(name, ".ics")
+	}
+	if err := dates.CheckTask(&task, time.Now()); err != nil {
+		setErrorStatus(w, http.StatusForbidden, "invalid task", err)
+		return
+	}
+
+	taskID, err := storage.InsertTask(userID, task)
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to create task", err)
+		return
+	}
+	task.ID = strconv.Itoa(taskID)
+	recordAudit(r, model.AuditInsert, task.ID, nil, &task)
+
+	err = storage.InsertCalDAVResource(model.CalDAVResource{TaskID: task.ID, Name: name, UID: uid})
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to save resource", err)
+		return
+	}
+
+	current, err := storage.FindTask(userID, task.ID)
+	if err == nil && completed {
+		current, err = completeCalDAVTask(r, current)
+	}
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to complete task", err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(current.Version))
+	w.WriteHeader(http.StatusCreated)
+	log.Println(fmt.Sprintf("Added task with id=%s via CalDAV", task.ID))
+}
+
+func caldavUpdate(w http.ResponseWriter, r *http.Request, res caldavResource, task model.Task, completed bool) {
+	userID := middleware.UserID(r.Context())
+	current := res.Task
+	if !checkCalDAVPrecondition(w, r, current) {
+		return
+	}
+
+	// Выполненную задачу можно только оставить выполненной
+	if current.Done {
+		if !completed {
+			setErrorStatus(w, http.StatusForbidden, "failed to update task", errors.New("completed task cannot be reopened"))
+			return
+		}
+		w.Header().Set("ETag", etag(current.Version))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Дата просроченной задачи при проверке переносится на сегодня, поэтому задача
+	// изменяется, только если клиент действительно поменял её поля
+	if task.Title != current.Title || task.Comment != current.Comment || task.Repeat != current.Repeat ||
+		task.Priority != current.Priority || (len(task.Date) > 0 && task.Date != current.Date) {
+		if err := dates.CheckTask(&task, time.Now()); err != nil {
+			setErrorStatus(w, http.StatusForbidden, "invalid task", err)
+			return
+		}
+		task.ID = current.ID
+		task.Version = current.Version
+		// В VTODO нет проекта, поэтому задача остаётся в своём проекте
+		task.ProjectID = current.ProjectID
+		updated, err := storage.UpdateTask(userID, task)
+		if errors.Is(err, storage.ErrVersionConflict) {
+			setErrorStatus(w, http.StatusPreconditionFailed, "precondition failed", err)
+			return
+		}
+		if errors.Is(err, storage.ErrRepeatingBlocker) {
+			setErrorStatus(w, http.StatusConflict, "failed to update task", err)
+			return
+		}
+		if err != nil {
+			setErrorStatus(w, http.StatusInternalServerError, "failed to update task", err)
+			return
+		}
+		recordAudit(r, model.AuditUpdate, current.ID, &current, &updated)
+		current = updated
+	}
+
+	if completed {
+		var err error
+		current, err = completeCalDAVTask(r, current)
+		if errors.Is(err, storage.ErrVersionConflict) {
+			setErrorStatus(w, http.StatusPreconditionFailed, "precondition failed", err)
+			return
+		}
+		if err != nil {
+			setErrorStatus(w, http.StatusInternalServerError, "failed to complete task", err)
+			return
+		}
+	}
+
+	w.Header().Set("ETag", etag(current.Version))
+	w.WriteHeader(http.StatusNoContent)
+	log.Println(fmt.Sprintf("Updated task with id=%s via CalDAV", current.ID))
+}
+
+// completeCalDAVTask выполняет задачу той версии, которую видел клиент
+func completeCalDAVTask(r *http.Request, task model.Task) (model.Task, error) {
+	userID := middleware.UserID(r.Context())
+	// Клиент CalDAV не знает о зависимостях и уже отметил задачу выполненной у себя
+	oldTask, done, err := storage.CompleteTask(userID, task.ID, task.Version, true, time.Now())
+	if err != nil {
+		return model.Task{}, err
+	}
+	recordAudit(r, model.AuditDone, done.ID, &oldTask, &done)
+	return done, nil
+}
+
+func caldavDelete(w http.ResponseWriter, r *http.Request, name string) {
+	userID := middleware.UserID(r.Context())
+	res, err := findCalDAVResource(userID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to get task", err)
+		return
+	}
+	if !checkCalDAVPrecondition(w, r, res.Task) {
+		return
+	}
+
+	if err := storage.DeleteTask(userID, res.Task.ID); err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to delete task", err)
+		return
+	}
+	recordAudit(r, model.AuditDelete, res.Task.ID, &res.Task, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+	log.Println(fmt.Sprintf("Deleted task with id=%s via CalDAV", res.Task.ID))
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+)
+
+// Пространства имён свойств WebDAV и CalDAV
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+)
+
+var (
+	propResourceType   = xml.Name{Space: nsDAV, Local: "resourcetype"}
+	propDisplayName    = xml.Name{Space: nsDAV, Local: "displayname"}
+	propPrincipal      = xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	propPrincipalURL   = xml.Name{Space: nsDAV, Local: "principal-URL"}
+	propPrivileges     = xml.Name{Space: nsDAV, Local: "current-user-privilege-set"}
+	propReports        = xml.Name{Space: nsDAV, Local: "supported-report-set"}
+	propETag           = xml.Name{Space: nsDAV, Local: "getetag"}
+	propContentType    = xml.Name{Space: nsDAV, Local: "getcontenttype"}
+	propHomeSet        = xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}
+	propComponents     = xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}
+	propCalendarData   = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+	propCTag           = xml.Name{Space: nsCS, Local: "getctag"}
+	reportQuery        = xml.Name{Space: nsCalDAV, Local: "calendar-query"}
+	reportMultiget     = xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}
+	principalHref      = "<d:href>" + caldavRoot + "</d:href>"
+	collectionPrivsXML = "<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege>" +
+		"<d:privilege><d:write-content/></d:privilege><d:privilege><d:bind/></d:privilege>" +
+		"<d:privilege><d:unbind/></d:privilege>"
+	reportsXML = "<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+		"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>"
+)
+
+// davElement элемент XML, из которого важно только имя
+type davElement struct {
+	XMLName xml.Name
+}
+
+// davPropRequest список запрошенных свойств. Пустой список означает все свойства
+type davPropRequest struct {
+	Props []davElement `xml:",any"`
+}
+
+type propfindRequest struct {
+	XMLName xml.Name       `xml:"DAV: propfind"`
+	Prop    davPropRequest `xml:"DAV: prop"`
+}
+
+type compFilter struct {
+	Name    string       `xml:"name,attr"`
+	Filters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type reportRequest struct {
+	XMLName xml.Name
+	Prop    davPropRequest `xml:"DAV: prop"`
+	Hrefs   []string       `xml:"DAV: href"`
+	Filter  struct {
+		Filters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// davResponse ответ multistatus для одного ресурса: значения найденных свойств
+// в виде готового XML и имена свойств, которых у ресурса нет
+type davResponse struct {
+	href    string
+	found   map[xml.Name]string
+	order   []xml.Name
+	missing []xml.Name
+}
+
+// newDAVResponse выбирает из свойств ресурса запрошенные. Без списка свойств
+// возвращаются все свойства, кроме calendar-data
+func newDAVResponse(href string, props map[xml.Name]string, order []xml.Name, request []davElement) davResponse {
+	response := davResponse{href: href, found: map[xml.Name]string{}}
+	if len(request) == 0 {
+		for _, name := range order {
+			if name != propCalendarData {
+				response.found[name] = props[name]
+				response.order = append(response.order, name)
+			}
+		}
+		return response
+	}
+	for _, element := range request {
+		if value, ok := props[element.XMLName]; ok {
+			response.found[element.XMLName] = value
+			response.order = append(response.order, element.XMLName)
+		} else {
+			response.missing = append(response.missing, element.XMLName)
+		}
+	}
+	return response
+}
+
+// writeMultistatus отправляет ответ 207 Multi-Status
+func writeMultistatus(w http.ResponseWriter, responses []davResponse) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<d:multistatus xmlns:d="%s" xmlns:c="%s" xmlns:cs="%s">`, nsDAV, nsCalDAV, nsCS)
+	for _, response := range responses {
+		b.WriteString("<d:response><d:href>" + escapeXML((&url.URL{Path: response.href}).EscapedPath()) + "</d:href>")
+		if len(response.order) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, name := range response.order {
+				writeProp(&b, name, response.found[name])
+			}
+			b.WriteString("</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+		}
+		if len(response.missing) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, name := range response.missing {
+				writeProp(&b, name, "")
+			}
+			b.WriteString("</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+		}
+		b.WriteString("</d:response>")
+	}
+	b.WriteString("</d:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		log.Printf("writing multistatus error: %v", err)
+	}
+}
+
+// writeProp записывает свойство в его пространстве имён. Вложенные элементы
+// используют префиксы d:, c: и cs:, объявленные в корне ответа
+func writeProp(b *strings.Builder, name xml.Name, value string) {
+	fmt.Fprintf(b, `<%s xmlns="%s">%s</%s>`, name.Local, escapeXML(name.Space), value, name.Local)
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// decodeDAVBody разбирает тело запроса WebDAV. Пустое тело не считается ошибкой
+func decodeDAVBody(r *http.Request, v any) error {
+	err := xml.NewDecoder(http.MaxBytesReader(nil, r.Body, maxResourceSize)).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// depthOne сообщает, запрошены ли вместе с коллекцией её элементы
+func depthOne(r *http.Request) bool {
+	return r.Header.Get("Depth") != "0"
+}
+
+// rootProps свойства принципала, который одновременно служит домашним каталогом календарей
+func rootProps() (map[xml.Name]string, []xml.Name) {
+	order := []xml.Name{propResourceType, propDisplayName, propPrincipal, propPrincipalURL, propHomeSet}
+	return map[xml.Name]string{
+		propResourceType: "<d:collection/><d:principal/>",
+		propDisplayName:  escapeXML("Планировщик задач"),
+		propPrincipal:    principalHref,
+		propPrincipalURL: principalHref,
+		propHomeSet:      principalHref,
+	}, order
+}
+
+// collectionProps свойства коллекции задач. CTag меняется при любом изменении задач коллекции
+func collectionProps(resources []caldavResource) (map[xml.Name]string, []xml.Name) {
+	hash := sha1.New()
+	for _, res := range resources {
+		fmt.Fprintf(hash, "%s:%s:%s;", res.Task.ID, res.Name, res.Task.Version)
+	}
+	ctag := hex.EncodeToString(hash.Sum(nil))[:16]
+
+	order := []xml.Name{propResourceType, propDisplayName, propPrincipal, propComponents, propReports,
+		propPrivileges, propCTag, propETag}
+	return map[xml.Name]string{
+		propResourceType: "<d:collection/><c:calendar/>",
+		propDisplayName:  escapeXML("Задачи"),
+		propPrincipal:    principalHref,
+		propComponents:   `<c:comp name="VTODO"/>`,
+		propReports:      reportsXML,
+		propPrivileges:   collectionPrivsXML,
+		propCTag:         ctag,
+		propETag:         escapeXML(etag(ctag)),
+	}, order
+}
+
+// resourceProps свойства ресурса задачи
+func resourceProps(res caldavResource) (map[xml.Name]string, []xml.Name, error) {
+	data, err := calendarData(res)
+	if err != nil {
+		return nil, nil, err
+	}
+	order := []xml.Name{propResourceType, propETag, propContentType, propCalendarData}
+	return map[xml.Name]string{
+		propResourceType: "",
+		propETag:         escapeXML(etag(res.Task.Version)),
+		propContentType:  "text/calendar; charset=utf-8; component=VTODO",
+		propCalendarData: escapeXML(string(data)),
+	}, order, nil
+}
+
+func propfindRoot(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var request propfindRequest
+	if err := decodeDAVBody(r, &request); err != nil {
+		setErrorResponse(w, "invalid PROPFIND body", err)
+		return
+	}
+
+	props, order := rootProps()
+	responses := []davResponse{newDAVResponse(caldavRoot, props, order, request.Prop.Props)}
+	if depthOne(r) {
+		resources, err := readCalDAVResources(userID)
+		if err != nil {
+			setErrorStatus(w, http.StatusInternalServerError, "failed to get tasks", err)
+			return
+		}
+		props, order := collectionProps(resources)
+		responses = append(responses, newDAVResponse(caldavCollection, props, order, request.Prop.Props))
+	}
+	writeMultistatus(w, responses)
+}
+
+func propfindCollection(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var request propfindRequest
+	if err := decodeDAVBody(r, &request); err != nil {
+		setErrorResponse(w, "invalid PROPFIND body", err)
+		return
+	}
+
+	resources, err := readCalDAVResources(userID)
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to get tasks", err)
+		return
+	}
+	props, order := collectionProps(resources)
+	responses := []davResponse{newDAVResponse(caldavCollection, props, order, request.Prop.Props)}
+	if depthOne(r) {
+		for _, res := range resources {
+			props, order, err := resourceProps(res)
+			if err != nil {
+				setErrorStatus(w, http.StatusInternalServerError, "failed to encode task", err)
+				return
+			}
+			responses = append(responses, newDAVResponse(res.href(), props, order, request.Prop.Props))
+		}
+	}
+	writeMultistatus(w, responses)
+}
+
+func propfindResource(w http.ResponseWriter, r *http.Request, name string) {
+	userID := middleware.UserID(r.Context())
+	var request propfindRequest
+	if err := decodeDAVBody(r, &request); err != nil {
+		setErrorResponse(w, "invalid PROPFIND body", err)
+		return
+	}
+
+	res, err := findCalDAVResource(userID, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	props, order, err := resourceProps(res)
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to encode task", err)
+		return
+	}
+	writeMultistatus(w, []davResponse{newDAVResponse(res.href(), props, order, request.Prop.Props)})
+}
+
+// reportCollection выполняет отчёты calendar-query и calendar-multiget. Из фильтров
+// calendar-query учитывается только тип компонента, остальные условия не проверяются
+func reportCollection(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var request reportRequest
+	if err := decodeDAVBody(r, &request); err != nil {
+		setErrorResponse(w, "invalid REPORT body", err)
+		return
+	}
+	if request.XMLName != reportQuery && request.XMLName != reportMultiget {
+		setErrorStatus(w, http.StatusForbidden, "unsupported report", fmt.Errorf("%s", request.XMLName.Local))
+		return
+	}
+
+	resources, err := readCalDAVResources(userID)
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to get tasks", err)
+		return
+	}
+
+	responses := []davResponse{}
+	if request.XMLName == reportMultiget {
+		byHref := make(map[string]caldavResource, len(resources))
+		for _, res := range resources {
+			byHref[res.href()] = res
+		}
+		for _, href := range request.Hrefs {
+			path := strings.TrimSpace(href)
+			if u, err := url.Parse(path); err == nil {
+				path = u.Path
+			}
+			res, ok := byHref[path]
+			if !ok {
+				responses = append(responses, davResponse{href: path, missing: []xml.Name{propETag}})
+				continue
+			}
+			props, order, err := resourceProps(res)
+			if err != nil {
+				setErrorStatus(w, http.StatusInternalServerError, "failed to encode task", err)
+				return
+			}
+			responses = append(responses, newDAVResponse(res.href(), props, order, request.Prop.Props))
+		}
+		writeMultistatus(w, responses)
+		return
+	}
+
+	if !queriesTodos(request.Filter.Filters) {
+		writeMultistatus(w, responses)
+		return
+	}
+	for _, res := range resources {
+		props, order, err := resourceProps(res)
+		if err != nil {
+			setErrorStatus(w, http.StatusInternalServerError, "failed to encode task", err)
+			return
+		}
+		responses = append(responses, newDAVResponse(res.href(), props, order, request.Prop.Props))
+	}
+	writeMultistatus(w, responses)
+}
+
+// queriesTodos сообщает, могут ли задачи VTODO подойти под фильтр VCALENDAR
+func queriesTodos(filters []compFilter) bool {
+	for _, calendar := range filters {
+		if len(calendar.Filters) == 0 {
+			return true
+		}
+		for _, component := range calendar.Filters {
+			if strings.EqualFold(component.Name, "VTODO") {
+				return true
+			}
+		}
+	}
+	return len(filters) == 0
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// maxRangeDays ограничивает длину запрашиваемого диапазона дат
+const maxRangeDays = 366
+
+// CalendarGet возвращает задачи месяца, сгруппированные по дням
+func CalendarGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	month, err := time.Parse("2006-01", r.URL.Query().Get("month"))
+	if err != nil {
+		setErrorResponse(w, "invalid month format", err)
+		return
+	}
+	from := month
+	to := month.AddDate(0, 1, -1)
+
+	tasks, err := fetchTasksInRange(userID, from, to)
+	if err != nil {
+		setErrorResponse(w, "failed to get tasks", err)
+		return
+	}
+
+	// Раскладываем задачи по дням месяца, включая дни без задач
+	days := make([]model.CalendarDay, 0, to.Day())
+	index := make(map[string]int, to.Day())
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(model.DatePat)
+		index[date] = len(days)
+		days = append(days, model.CalendarDay{Date: date, Tasks: []model.Task{}})
+	}
+	for _, task := range tasks {
+		i := index[task.Date]
+		days[i].Tasks = append(days[i].Tasks, task)
+	}
+
+	jsonResponse(w, http.StatusOK)
+	calendar := model.Calendar{Month: month.Format("2006-01"), Days: days}
+	if err := json.NewEncoder(w).Encode(calendar); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Read calendar for %s with %d tasks", calendar.Month, len(tasks)))
+}
+
+// parseRange разбирает границы диапазона дат from и to
+func parseRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	from, err := time.Parse(model.DatePat, fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("bad from date: %w", err)
+	}
+	to, err := time.Parse(model.DatePat, toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("bad to date: %w", err)
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("to date is before from date")
+	}
+	if to.Sub(from) > maxRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("range is longer than %d days", maxRangeDays)
+	}
+	return from, to, nil
+}
+
+// fetchTasksInRange читает задачи диапазона и разворачивает повторяющиеся задачи
+func fetchTasksInRange(userID string, from, to time.Time) ([]model.Task, error) {
+	tasks, err := storage.ReadTasksInRange(userID, from.Format(model.DatePat), to.Format(model.DatePat))
+	if err != nil {
+		return nil, err
+	}
+	return expandTasks(tasks, from, to), nil
+}
+
+// expandTasks заменяет каждую задачу её повторениями внутри диапазона [from, to]
+func expandTasks(tasks []model.Task, from, to time.Time) []model.Task {
+	result := make([]model.Task, 0, len(tasks))
+	for _, task := range tasks {
+		occurrences, err := dates.Occurrences(task.Date, task.Repeat, from, to)
+		if err != nil {
+			log.Printf("failed to expand task with id=%s: %v", task.ID, err)
+			continue
+		}
+		for _, date := range occurrences {
+			occurrence := task
+			occurrence.Date = date
+			result = append(result, occurrence)
+		}
+	}
+
+	sortTasksByDate(result)
+	return result
+}
+
+// sortTasksByDate упорядочивает задачи по дате, сохраняя порядок задач с одной датой
+func sortTasksByDate(tasks []model.Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].Date < tasks[j].Date
+	})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// errTaskNotActive ответ на изменение чек-листа задачи, которой нет, которая выполнена или находится в корзине
+var errTaskNotActive = errors.New("task not found, done or deleted")
+
+func ChecklistHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		ChecklistGet(w, r)
+	case http.MethodPost:
+		ChecklistItemAddPost(w, r)
+	case http.MethodDelete:
+		ChecklistItemDelete(w, r)
+	default:
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeChecklist отправляет пункты чек-листа
+func writeChecklist(w http.ResponseWriter, items []model.ChecklistItem) {
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.Checklist{Items: items}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+	}
+}
+
+// ChecklistGet возвращает чек-лист задачи по порядку пунктов
+func ChecklistGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+
+	if _, err := storage.FindTask(userID, id); err != nil {
+		setErrorStatus(w, http.StatusNotFound, "failed to get checklist", errors.New("task not found"))
+		return
+	}
+	items, err := storage.ReadChecklist(userID, id)
+	if err != nil {
+		setErrorResponse(w, "failed to get checklist", err)
+		return
+	}
+
+	writeChecklist(w, items)
+	log.Println(fmt.Sprintf("Read %d checklist items of task with id=%s", len(items), id))
+}
+
+// ChecklistItemAddPost добавляет пункт в конец чек-листа задачи
+func ChecklistItemAddPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var item model.ChecklistItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		setErrorResponse(w, "JSON deserialization error", err)
+		return
+	}
+	item.TaskID = r.URL.Query().Get("id")
+	if err := dates.CheckChecklistItem(&item); err != nil {
+		setErrorResponse(w, "invalid checklist item", err)
+		return
+	}
+
+	added, err := storage.InsertChecklistItem(userID, item)
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to add checklist item", err)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to add checklist item", errTaskNotActive)
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to add checklist item", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(added); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Added checklist item with id=%s to task with id=%s", added.ID, added.TaskID))
+}
+
+// ChecklistItemCheckPost отмечает пункт чек-листа, а с параметром checked=false снимает отметку
+func ChecklistItemCheckPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("item")
+	checked := true
+	if value := r.URL.Query().Get("checked"); len(value) > 0 {
+		var err error
+		if checked, err = strconv.ParseBool(value); err != nil {
+			setErrorResponse(w, "invalid checked", err)
+			return
+		}
+	}
+
+	item, err := storage.CheckChecklistItem(userID, id, checked)
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to check checklist item", err)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to check checklist item", errors.New("checklist item not found"))
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to check checklist item", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Set checked=%t for checklist item with id=%s", checked, id))
+}
+
+// ChecklistReorderPost расставляет пункты чек-листа задачи в переданном порядке
+func ChecklistReorderPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+
+	var order model.ChecklistOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		setErrorResponse(w, "JSON deserialization error", err)
+		return
+	}
+
+	items, err := storage.ReorderChecklist(userID, id, order.Items)
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to reorder checklist", err)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to reorder checklist", errTaskNotActive)
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to reorder checklist", err)
+		return
+	}
+
+	writeChecklist(w, items)
+	log.Println(fmt.Sprintf("Reordered checklist of task with id=%s", id))
+}
+
+// ChecklistItemDelete удаляет пункт чек-листа
+func ChecklistItemDelete(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("item")
+
+	err := storage.DeleteChecklistItem(userID, id)
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to delete checklist item", err)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to delete checklist item", errors.New("checklist item not found"))
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to delete checklist item", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Deleted checklist item with id=%s", id))
+}
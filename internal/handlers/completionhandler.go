@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// TaskHistoryGet возвращает историю выполнения задачи
+func TaskHistoryGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		setErrorResponse(w, "invalid id", err)
+		return
+	}
+
+	completions, err := storage.ReadCompletions(userID, id)
+	if err != nil {
+		setErrorResponse(w, "failed to get task history", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.Completions{Completions: completions}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Read %d completions of task with id=%s", len(completions), id))
+}
+
+// CompletedGet возвращает задачи, выполненные в диапазоне дат [from, to]
+func CompletedGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	from, to, err := parseRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		setErrorResponse(w, "invalid date range", err)
+		return
+	}
+
+	// Границы дней отсчитываются в часовом поясе сервера
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, time.Local)
+
+	completions, err := storage.ReadCompletionsInRange(userID, start, end)
+	if err != nil {
+		setErrorResponse(w, "failed to get completed tasks", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.Completions{Completions: completions}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Read %d completions", len(completions)))
+}
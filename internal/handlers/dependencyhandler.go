@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+)
+
+// isDependencyError проверяет, что зависимости задачи не прошли проверку
+func isDependencyError(err error) bool {
+	return errors.Is(err, storage.ErrBlockerNotFound) || errors.Is(err, storage.ErrDependencyCycle) ||
+		errors.Is(err, storage.ErrRepeatingBlocker)
+}
+
+// TaskGraphGet возвращает граф зависимостей задач, которые не находятся в корзине
+func TaskGraphGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	graph, err := storage.ReadTaskGraph(userID)
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to get task graph", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(graph); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Read task graph with %d tasks", len(graph.Nodes)))
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/config"
+	"github.com/Zelvalna/go_final_project/internal/ical"
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/internal/transfer"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// CalendarICSGet отдаёт активные задачи в формате iCalendar для подписки из календарей.
+// Параметр type=vtodo выгружает задачи как VTODO, по умолчанию - как события VEVENT
+func CalendarICSGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	component := ical.ComponentEvent
+	switch strings.ToLower(r.URL.Query().Get("type")) {
+	case "", "vevent":
+	case "vtodo":
+		component = ical.ComponentTodo
+	default:
+		setErrorResponse(w, "invalid type", fmt.Errorf("unknown component %q", r.URL.Query().Get("type")))
+		return
+	}
+
+	tasks, err := storage.ReadTasks(userID, storage.TaskFilter{})
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to get tasks", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=UTF-8")
+	w.Header().Set("Content-Disposition", `inline; filename="tasks.ics"`)
+	w.WriteHeader(http.StatusOK)
+	if err := ical.WriteCalendar(w, tasks, component, time.Now()); err != nil {
+		log.Printf("writing calendar error: %v", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Sent calendar feed with %d tasks", len(tasks)))
+}
+
+// FeedTokenPost создаёт пользователю новый токен подписки на календарь. Прежний токен
+// перестаёт действовать, а новый возвращается только в этом ответе
+func FeedTokenPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	token, err := middleware.NewFeedToken()
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to create feed token", err)
+		return
+	}
+	if err := storage.SetFeedToken(userID, token, time.Now()); err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to save feed token", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated)
+	feed := model.FeedToken{Token: token, URL: "/api/calendar.ics?token=" + token}
+	if err := json.NewEncoder(w).Encode(feed); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Created feed token for user with id=%s", userID))
+}
+
+// ICSImportPost создаёт задачи из компонентов VTODO и VEVENT загруженного файла .ics.
+// Компоненты с неподдерживаемыми правилами повтора попадают в отчёт и не мешают загрузке остальных
+func ICSImportPost(w http.ResponseWriter, r *http.Request, cfg config.Config) {
+	opts, err := importOptions(r)
+	if err != nil {
+		setErrorResponse(w, "invalid import options", err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	upload, err := openUpload(r, "file")
+	if err != nil {
+		setErrorResponse(w, "failed to read upload", err)
+		return
+	}
+	defer upload.Close()
+
+	components, err := ical.ReadComponents(upload)
+	if err != nil {
+		setErrorResponse(w, "failed to parse calendar", err)
+		return
+	}
+
+	items := ical.Items(components, cfg.Location)
+	rows := make([]transfer.Row, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, transfer.Row{Ref: item.Ref, Task: item.Task, Err: item.Err})
+	}
+	writeImportReport(w, transfer.ImportRows(rows, opts))
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// ProjectsGet возвращает проекты с количеством активных задач. С параметром
+// archived=true возвращаются также архивные проекты
+func ProjectsGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	archived, _ := strconv.ParseBool(r.URL.Query().Get("archived"))
+
+	projects, err := storage.ReadProjects(userID, archived)
+	if err != nil {
+		setErrorResponse(w, "failed to get projects", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.Projects{Projects: projects}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Read %d projects", len(projects)))
+}
+
+func ProjectHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		ProjectAddPost(w, r)
+	case http.MethodGet:
+		ProjectGet(w, r)
+	case http.MethodPut:
+		ProjectUpdatePut(w, r)
+	case http.MethodDelete:
+		ProjectDelete(w, r)
+	default:
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+	}
+}
+
+// ProjectAddPost создаёт проект
+func ProjectAddPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var project model.Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		setErrorResponse(w, "JSON deserialization error", err)
+		return
+	}
+	if err := dates.CheckProject(&project); err != nil {
+		setErrorResponse(w, "invalid project", err)
+		return
+	}
+
+	projectId, err := storage.InsertProject(userID, project)
+	if err != nil {
+		setErrorResponse(w, "failed to create project", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(model.TaskIdResponse{Id: projectId}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Added project with id=%d", projectId))
+}
+
+// ProjectGet возвращает проект по ID
+func ProjectGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+
+	project, err := storage.FindProject(userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to get project", storage.ErrProjectNotFound)
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to get project", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(project); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+}
+
+// ProjectUpdatePut переименовывает проект
+func ProjectUpdatePut(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var project model.Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		setErrorResponse(w, "JSON deserialization error", err)
+		return
+	}
+	if _, err := strconv.Atoi(project.ID); err != nil {
+		setErrorResponse(w, "invalid id", err)
+		return
+	}
+	if err := dates.CheckProject(&project); err != nil {
+		setErrorResponse(w, "invalid project", err)
+		return
+	}
+
+	updated, err := storage.UpdateProject(userID, project)
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to update project", storage.ErrProjectNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to update project", err)
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to update project", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(updated); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Updated project with id=%s", project.ID))
+}
+
+// ProjectArchivePost архивирует проект, а с параметром archived=false возвращает его из архива
+func ProjectArchivePost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+	archived := true
+	if value := r.URL.Query().Get("archived"); len(value) > 0 {
+		var err error
+		if archived, err = strconv.ParseBool(value); err != nil {
+			setErrorResponse(w, "invalid archived", err)
+			return
+		}
+	}
+
+	project, err := storage.ArchiveProject(userID, id, archived, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to archive project", storage.ErrProjectNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to archive project", err)
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to archive project", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(project); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Set archived=%t for project with id=%s", archived, id))
+}
+
+// ProjectDelete удаляет проект. Параметр tasks задаёт, что сделать с задачами проекта:
+// move (по умолчанию) переносит их в проект to или оставляет без проекта,
+// а cascade перемещает их в корзину
+func ProjectDelete(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	query := r.URL.Query()
+	id := query.Get("id")
+	mode := query.Get("tasks")
+	if len(mode) == 0 {
+		mode = model.ProjectDeleteMove
+	}
+	if mode != model.ProjectDeleteMove && mode != model.ProjectDeleteCascade {
+		setErrorResponse(w, "invalid tasks", fmt.Errorf("expected %s or %s, got %q",
+			model.ProjectDeleteMove, model.ProjectDeleteCascade, mode))
+		return
+	}
+
+	oldTasks, newTasks, err := storage.DeleteProject(userID, id, mode, query.Get("to"), time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to delete project", storage.ErrProjectNotFound)
+		return
+	}
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to delete project", err)
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to delete project", err)
+		return
+	}
+	for i := range oldTasks {
+		if mode == model.ProjectDeleteCascade {
+			recordAudit(r, model.AuditDelete, oldTasks[i].ID, &oldTasks[i], nil)
+		} else {
+			recordAudit(r, model.AuditUpdate, oldTasks[i].ID, &oldTasks[i], &newTasks[i])
+		}
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Deleted project with id=%s, %d tasks: %s", id, len(oldTasks), mode))
+}
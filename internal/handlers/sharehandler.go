@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// errShareTargetNotFound возвращается, если задачи или проекта доступа нет
+var errShareTargetNotFound = errors.New("task or project not found")
+
+// setShareError отправляет ответ об ошибке доступа: 404, если задачи или проекта нет,
+// и 403, если пользователь не их владелец
+func setShareError(w http.ResponseWriter, s string, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		setErrorStatus(w, http.StatusNotFound, s, errShareTargetNotFound)
+	case errors.Is(err, storage.ErrForbidden):
+		setErrorStatus(w, http.StatusForbidden, s, err)
+	default:
+		setErrorResponse(w, s, err)
+	}
+}
+
+func ShareHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		ShareAddPost(w, r)
+	case http.MethodDelete:
+		ShareDelete(w, r)
+	default:
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+	}
+}
+
+// ShareAddPost открывает задачу или проект другому пользователю для просмотра или изменения
+func ShareAddPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var share model.Share
+	if err := json.NewDecoder(r.Body).Decode(&share); err != nil {
+		setErrorResponse(w, "JSON deserialization error", err)
+		return
+	}
+	if err := dates.CheckShare(&share); err != nil {
+		setErrorResponse(w, "invalid share", err)
+		return
+	}
+
+	shareId, err := storage.InsertShare(userID, share, time.Now())
+	if err != nil {
+		setShareError(w, "failed to share", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(model.TaskIdResponse{Id: shareId}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Added share with id=%d: %s for %s", shareId, share.Permission, share.User))
+}
+
+// SharesGet возвращает доступы к задаче task_id или проекту project_id
+func SharesGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	query := r.URL.Query()
+	share := model.Share{TaskID: query.Get("task_id"), ProjectID: query.Get("project_id")}
+	if err := dates.CheckShareTarget(share); err != nil {
+		setErrorResponse(w, "invalid share", err)
+		return
+	}
+
+	shares, err := storage.ReadShares(userID, share)
+	if err != nil {
+		setShareError(w, "failed to get shares", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.Shares{Shares: shares}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Read %d shares", len(shares)))
+}
+
+// ShareDelete закрывает доступ по ID
+func ShareDelete(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+
+	err := storage.DeleteShare(userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to delete share", errors.New("share not found"))
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to delete share", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Deleted share with id=%s", id))
+}
+
+// SharedGet возвращает задачи и проекты других пользователей, открытые текущему пользователю
+func SharedGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	shared, err := storage.ReadShared(userID)
+	if err != nil {
+		setErrorResponse(w, "failed to get shared", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(shared); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Read %d shared tasks and %d shared projects", len(shared.Tasks), len(shared.Projects)))
+}
@@ -2,34 +2,41 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"time"
 
 	"github.com/Zelvalna/go_final_project/config"
-
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
 	"github.com/Zelvalna/go_final_project/model"
-
-	"github.com/golang-jwt/jwt"
 )
 
-// SingInHandler проверка пароля и возврат JWT токена
+// SingInHandler проверка имени и пароля пользователя и возврат JWT токена.
+// Без имени проверяется пароль администратора
 func SingInHandler(w http.ResponseWriter, r *http.Request, cfg config.Config) {
 	var signData model.SignInRequest
-	// Декодируем запрос с паролем
+	// Декодируем запрос с именем и паролем
 	if err := json.NewDecoder(r.Body).Decode(&signData); err != nil {
 		setErrorResponse(w, "invalid request", err)
+		return
+	}
+	if len(signData.Name) == 0 {
+		signData.Name = model.AdminName
 	}
-	// Раз в auth изменили и тут изменим
 
-	if cfg.TodoPassword != signData.Password || cfg.TodoPassword == "" {
+	user, err := storage.Authenticate(signData.Name, signData.Password)
+	if errors.Is(err, storage.ErrBadCredentials) {
 		http.Error(w, `{"error": "Неверный пароль"}`, http.StatusUnauthorized)
 		return
 	}
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to check password", err)
+		return
+	}
 
-	token := jwt.New(jwt.SigningMethodHS256)
-	tokenString, err := token.SignedString([]byte(cfg.TodoPassword))
-	log.Println(tokenString, err)
+	tokenString, err := middleware.NewToken(user, cfg, time.Now())
 	if err != nil {
 		http.Error(w, "Ошибка при создании токена", http.StatusInternalServerError)
 		return
@@ -39,3 +46,23 @@ func SingInHandler(w http.ResponseWriter, r *http.Request, cfg config.Config) {
 		http.Error(w, fmt.Errorf("error: %w", err).Error(), http.StatusUnauthorized)
 	}
 }
+
+// SignOutPost отзывает токен, с которым выполнен запрос. После выхода токен отклоняется,
+// даже если срок его действия не истёк
+func SignOutPost(w http.ResponseWriter, r *http.Request, cfg config.Config) {
+	cookie, err := r.Cookie("token")
+	if err != nil {
+		setErrorStatus(w, http.StatusUnauthorized, "failed to sign out", err)
+		return
+	}
+	if err := middleware.RevokeToken(cookie.Value, cfg, time.Now()); err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to sign out", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+const (
+	defSyncLimit = 500
+	maxSyncLimit = 1000
+	// maxSyncPush ограничивает количество изменений в одном запросе POST /api/sync
+	maxSyncPush = 500
+)
+
+// SyncGet возвращает задачи, созданные, изменённые и удалённые после токена since.
+// Без since возвращаются все задачи. Если изменений больше limit, has_more равен true,
+// и следующую часть нужно запросить с полученным токеном
+func SyncGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var since int64
+	if value := r.URL.Query().Get("since"); len(value) > 0 {
+		var err error
+		if since, err = strconv.ParseInt(value, 10, 64); err != nil || since < 0 {
+			setErrorResponse(w, "invalid since token", fmt.Errorf("bad token %q", value))
+			return
+		}
+	}
+	limit := defSyncLimit
+	if value := r.URL.Query().Get("limit"); len(value) > 0 {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxSyncLimit {
+			setErrorResponse(w, "invalid limit", fmt.Errorf("limit must be between 1 and %d", maxSyncLimit))
+			return
+		}
+		limit = n
+	}
+
+	// Лишнее изменение показывает, что за этой частью есть ещё
+	changes, err := storage.ReadChanges(userID, since, limit+1)
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to read changes", err)
+		return
+	}
+	response := model.SyncResponse{
+		Created: []model.Task{},
+		Updated: []model.Task{},
+		Deleted: []model.SyncTombstone{},
+	}
+	if len(changes) > limit {
+		response.HasMore = true
+		changes = changes[:limit]
+	}
+
+	token := since
+	for _, change := range changes {
+		token = change.Seq
+		switch {
+		case !change.Exists:
+			// Задача окончательно удалена из корзины
+			response.Deleted = append(response.Deleted, model.SyncTombstone{ID: change.Task.ID, DeletedAt: change.ChangedAt})
+		case len(change.Task.DeletedAt) > 0:
+			response.Deleted = append(response.Deleted, model.SyncTombstone{ID: change.Task.ID, DeletedAt: change.Task.DeletedAt})
+		case change.Created:
+			response.Created = append(response.Created, change.Task)
+		default:
+			response.Updated = append(response.Updated, change.Task)
+		}
+	}
+	if len(changes) == 0 {
+		// Без изменений отдаём текущий токен, чтобы клиент не запрашивал их повторно
+		if token, err = storage.ChangeToken(userID); err != nil {
+			setErrorStatus(w, http.StatusInternalServerError, "failed to read changes", err)
+			return
+		}
+		token = max(token, since)
+	}
+	response.Token = strconv.FormatInt(token, 10)
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Sync since %d: created=%d updated=%d deleted=%d",
+		since, len(response.Created), len(response.Updated), len(response.Deleted)))
+}
+
+// SyncPost применяет изменения, накопленные клиентом, по порядку. Каждое изменение
+// проверяется отдельно: при несовпадении версии его результат - conflict с актуальной
+// копией задачи, а остальные изменения всё равно применяются
+func SyncPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var request model.SyncPushRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		setErrorResponse(w, "failed to read request", err)
+		return
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		setErrorResponse(w, "JSON deserialization error", err)
+		return
+	}
+	// Поля, переданные в задачах изменений: без priority и project_id они не меняются, как в PUT /api/task
+	var fields struct {
+		Changes []struct {
+			Task map[string]json.RawMessage `json:"task"`
+		} `json:"changes"`
+	}
+	_ = json.Unmarshal(body, &fields)
+	if len(request.Changes) > maxSyncPush {
+		setErrorResponse(w, "too many changes", fmt.Errorf("at most %d changes per request", maxSyncPush))
+		return
+	}
+
+	response := model.SyncPushResponse{Results: make([]model.SyncPushResult, 0, len(request.Changes))}
+	for i, item := range request.Changes {
+		var taskFields map[string]json.RawMessage
+		if i < len(fields.Changes) {
+			taskFields = fields.Changes[i].Task
+		}
+		result := applySyncItem(r, item, taskFields)
+		result.ClientID = item.ClientID
+		response.Results = append(response.Results, result)
+	}
+
+	token, err := storage.ChangeToken(userID)
+	if err != nil {
+		setErrorStatus(w, http.StatusInternalServerError, "failed to read changes", err)
+		return
+	}
+	response.Token = strconv.FormatInt(token, 10)
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Applied %d sync changes", len(response.Results)))
+}
+
+// applySyncItem применяет одно изменение клиента с теми же проверками, что и API задач.
+// fields - поля, переданные в задаче изменения
+func applySyncItem(r *http.Request, item model.SyncPushItem, fields map[string]json.RawMessage) model.SyncPushResult {
+	userID := middleware.UserID(r.Context())
+	task := item.Task
+	result := model.SyncPushResult{ID: task.ID, Status: model.SyncStatusOK}
+	fail := func(err error) model.SyncPushResult {
+		result.Status = model.SyncStatusError
+		result.Error = err.Error()
+		return result
+	}
+	conflict := func(current model.Task) model.SyncPushResult {
+		result.Status = model.SyncStatusConflict
+		result.Error = fmt.Sprintf("task was modified, current version is %s", current.Version)
+		result.Task = &current
+		return result
+	}
+	now := time.Now()
+
+	// Для всех операций, кроме создания, клиент должен видеть актуальную версию задачи
+	var current model.Task
+	if item.Op != model.SyncCreate {
+		if len(task.ID) == 0 || len(task.Version) == 0 {
+			return fail(errors.New("task id and version are required"))
+		}
+		var err error
+		current, err = storage.FindTask(userID, task.ID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && len(current.DeletedAt) > 0) {
+			return fail(errors.New("task not found"))
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if current.Version != task.Version {
+			return conflict(current)
+		}
+	}
+
+	switch item.Op {
+	case model.SyncCreate:
+		task.Done = false
+		if err := dates.CheckTask(&task, now); err != nil {
+			return fail(fmt.Errorf("invalid task: %w", err))
+		}
+		id, err := storage.InsertTask(userID, task)
+		if err != nil {
+			return fail(err)
+		}
+		task.ID = strconv.Itoa(id)
+		recordAudit(r, model.AuditInsert, task.ID, nil, &task)
+		created, err := storage.FindTask(userID, task.ID)
+		if err != nil {
+			return fail(err)
+		}
+		result.ID = created.ID
+		result.Task = &created
+	case model.SyncUpdate:
+		if err := dates.CheckTask(&task, now); err != nil {
+			return fail(fmt.Errorf("invalid task: %w", err))
+		}
+		if _, ok := fields["priority"]; !ok {
+			task.Priority = current.Priority
+		}
+		if _, ok := fields["project_id"]; !ok {
+			task.ProjectID = current.ProjectID
+		}
+		updated, err := storage.UpdateTask(userID, task)
+		if errors.Is(err, storage.ErrVersionConflict) {
+			current, _ = storage.FindTask(userID, task.ID)
+			return conflict(current)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		recordAudit(r, model.AuditUpdate, task.ID, &current, &updated)
+		result.Task = &updated
+	case model.SyncDone:
+		oldTask, done, err := storage.CompleteTask(userID, task.ID, task.Version, item.Force, now)
+		if errors.Is(err, storage.ErrVersionConflict) {
+			return conflict(oldTask)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		recordAudit(r, model.AuditDone, task.ID, &oldTask, &done)
+		result.Task = &done
+	case model.SyncDelete:
+		if err := storage.DeleteTask(userID, task.ID); err != nil {
+			return fail(err)
+		}
+		recordAudit(r, model.AuditDelete, task.ID, &current, nil)
+	default:
+		return fail(fmt.Errorf("unknown op %q", item.Op))
+	}
+	return result
+}
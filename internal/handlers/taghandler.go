@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// TagsGet возвращает все метки с количеством активных задач
+func TagsGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	tags, err := storage.ReadTags(userID)
+	if err != nil {
+		setErrorResponse(w, "failed to get tags", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.Tags{Tags: tags}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Read %d tags", len(tags)))
+}
+
+func TagHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		TagAddPost(w, r)
+	case http.MethodPut:
+		TagUpdatePut(w, r)
+	case http.MethodDelete:
+		TagDelete(w, r)
+	default:
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+	}
+}
+
+// TagAddPost создаёт метку с названием и цветом
+func TagAddPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var tag model.Tag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		setErrorResponse(w, "JSON deserialization error", err)
+		return
+	}
+	if err := dates.CheckTag(&tag); err != nil {
+		setErrorResponse(w, "invalid tag", err)
+		return
+	}
+
+	tagId, err := storage.InsertTag(userID, tag)
+	if errors.Is(err, storage.ErrTagExists) {
+		setErrorStatus(w, http.StatusConflict, "failed to create tag", err)
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to create tag", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(model.TaskIdResponse{Id: tagId}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Added tag with id=%d", tagId))
+}
+
+// TagUpdatePut переименовывает метку или меняет её цвет
+func TagUpdatePut(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	var tag model.Tag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		setErrorResponse(w, "JSON deserialization error", err)
+		return
+	}
+	if _, err := strconv.Atoi(tag.ID); err != nil {
+		setErrorResponse(w, "invalid id", err)
+		return
+	}
+	if err := dates.CheckTag(&tag); err != nil {
+		setErrorResponse(w, "invalid tag", err)
+		return
+	}
+
+	updated, err := storage.UpdateTag(userID, tag)
+	switch {
+	case errors.Is(err, storage.ErrTagExists):
+		setErrorStatus(w, http.StatusConflict, "failed to update tag", err)
+		return
+	case errors.Is(err, sql.ErrNoRows):
+		setErrorStatus(w, http.StatusNotFound, "failed to update tag", errors.New("tag not found"))
+		return
+	case err != nil:
+		setErrorResponse(w, "failed to update tag", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(updated); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Updated tag with id=%s", tag.ID))
+}
+
+// TagDelete удаляет метку и снимает её со всех задач
+func TagDelete(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+
+	err := storage.DeleteTag(userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to delete tag", errors.New("tag not found"))
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to delete tag", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Deleted tag with id=%s", id))
+}
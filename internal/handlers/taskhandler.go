@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Zelvalna/go_final_project/internal/middleware"
 	"github.com/Zelvalna/go_final_project/internal/storage"
 	dates "github.com/Zelvalna/go_final_project/internal/utils"
 	"github.com/Zelvalna/go_final_project/model"
@@ -16,16 +19,21 @@ import (
 
 // setErrorResponse Функция для создания и отправки ответа об ошибке
 func setErrorResponse(w http.ResponseWriter, s string, err error) {
+	setErrorStatus(w, http.StatusBadRequest, s, err)
+}
+
+// setErrorStatus отправляет ответ об ошибке с указанным кодом статуса
+func setErrorStatus(w http.ResponseWriter, status int, s string, err error) {
 	errorResponse := model.ErrorResponse{
 		Error: fmt.Errorf("%s: %w", s, err).Error()}
 
 	// Сериализация ответа об ошибке
 	errorData, _ := json.Marshal(errorResponse)
-	w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(status)
 	// Пишем ответ
 	_, writeErr := w.Write(errorData)
 	if writeErr != nil {
-		http.Error(w, fmt.Errorf("error: %w", writeErr).Error(), http.StatusBadRequest)
+		http.Error(w, fmt.Errorf("error: %w", writeErr).Error(), status)
 	}
 }
 
@@ -44,6 +52,7 @@ func TaskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 func TaskAddPost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
 	var taskData model.Task
 
 	// Декодирование JSON тела запроса
@@ -51,38 +60,25 @@ func TaskAddPost(w http.ResponseWriter, r *http.Request) {
 		setErrorResponse(w, "JSON deserialization error", err)
 		return
 	}
-	// Установка даты по умолчанию или проверка формата даты
-	if len(taskData.Date) == 0 {
-		taskData.Date = time.Now().Format(model.DatePat)
-	} else {
-		date, err := time.Parse(model.DatePat, taskData.Date)
-		if err != nil {
-			setErrorResponse(w, "bad data format", err)
-			return
-		}
-
-		if date.Before(time.Now()) {
-			taskData.Date = time.Now().Format(model.DatePat)
-		}
-	}
-	// Проверка заголовка задачи
-	if len(taskData.Title) == 0 {
-		setErrorResponse(w, "invalid title", errors.New("title is empty"))
+	// Проверка даты, заголовка и правила повтора
+	if err := dates.CheckTask(&taskData, time.Now()); err != nil {
+		setErrorResponse(w, "invalid task", err)
 		return
 	}
-	// Проверка формата повтора
-	if len(taskData.Repeat) > 0 {
-		if _, err := dates.GetNextDate(time.Now(), taskData.Date, taskData.Repeat); err != nil {
-			setErrorResponse(w, "invalid repeat format", errors.New("no such format"))
-			return
-		}
-	}
 	// Добавление задачи в базу данных
-	taskId, err := storage.InsertTask(taskData)
+	taskId, err := storage.InsertTask(userID, taskData)
 	if err != nil {
 		setErrorResponse(w, "failed to create task", err)
 		return
 	}
+	taskData.ID = strconv.Itoa(taskId)
+	// В журнал попадает задача в том виде, в каком она сохранена, вместе с версией
+	if task, err := storage.FindTask(userID, taskData.ID); err == nil {
+		taskData = task
+	} else {
+		log.Printf("failed to read created task with id=%s: %v", taskData.ID, err)
+	}
+	recordAudit(r, model.AuditInsert, taskData.ID, nil, &taskData)
 	// Возвращение ID созданной задачи
 	jsonResponse(w, http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(model.TaskIdResponse{Id: taskId}); err != nil {
@@ -98,9 +94,46 @@ func jsonResponse(w http.ResponseWriter, status int) {
 }
 
 func TasksReadGet(w http.ResponseWriter, r *http.Request) {
-	search := r.URL.Query().Get("search")
+	userID := middleware.UserID(r.Context())
+	query := r.URL.Query()
+	search := query.Get("search")
 
-	tasks, err := fetchTasks(search)
+	var (
+		tasks []model.Task
+		err   error
+	)
+	// Если задан диапазон дат, возвращаем задачи вместе с повторениями внутри него
+	if query.Has("from") || query.Has("to") {
+		from, to, rangeErr := parseRange(query.Get("from"), query.Get("to"))
+		if rangeErr != nil {
+			setErrorResponse(w, "invalid date range", rangeErr)
+			return
+		}
+		tasks, err = fetchTasksInRange(userID, from, to)
+	} else {
+		filter := storage.TaskFilter{
+			Tag:       strings.TrimSpace(query.Get("tag")),
+			ProjectID: query.Get("project_id"),
+			Order:     query.Get("order"),
+			Today:     time.Now().Format(model.DatePat),
+		}
+		if assigned := query.Get("assigned"); len(assigned) > 0 {
+			if assigned != model.AssignedToMe {
+				setErrorResponse(w, "invalid assigned", fmt.Errorf("expected %s, got %q", model.AssignedToMe, assigned))
+				return
+			}
+			filter.Assigned = true
+		}
+		if _, err := strconv.Atoi(filter.ProjectID); len(filter.ProjectID) > 0 && err != nil {
+			setErrorResponse(w, "invalid project_id", err)
+			return
+		}
+		if len(filter.Order) > 0 && filter.Order != model.OrderDate && filter.Order != model.OrderSmart {
+			setErrorResponse(w, "invalid order", fmt.Errorf("unknown order %q", filter.Order))
+			return
+		}
+		tasks, err = fetchTasks(userID, search, filter)
+	}
 	if err != nil {
 		setErrorResponse(w, "failed to get tasks", err)
 		return
@@ -115,24 +148,26 @@ func TasksReadGet(w http.ResponseWriter, r *http.Request) {
 	log.Println(fmt.Sprintf("Read %d tasks", len(tasks)))
 }
 
-func fetchTasks(search string) ([]model.Task, error) {
+func fetchTasks(userID string, search string, filter storage.TaskFilter) ([]model.Task, error) {
 	if len(search) > 0 {
 		if date, err := time.Parse("02.01.2006", search); err == nil {
-			return storage.SearchTasksByDate(date.Format(model.DatePat))
+			return storage.SearchTasksByDate(userID, date.Format(model.DatePat), filter)
 		}
-		return storage.SearchTasks(search)
+		return storage.SearchTasks(userID, search, filter)
 	}
-	return storage.ReadTasks()
+	return storage.ReadTasks(userID, filter)
 }
 
 func TaskByIdGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
 	id := r.URL.Query().Get("id")
 
-	task, err := storage.GetTaskById(id)
+	task, err := storage.GetTaskById(userID, id)
 	if err != nil {
 		setErrorResponse(w, "failed to get task by id", err)
 		return
 	}
+	w.Header().Set("ETag", etag(task.Version))
 	jsonResponse(w, http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(task); err != nil {
 		setErrorResponse(w, "failed to encode response", err)
@@ -142,12 +177,23 @@ func TaskByIdGet(w http.ResponseWriter, r *http.Request) {
 	log.Println(fmt.Sprintf("Read task with id=%s", id))
 }
 func TaskUpdatePut(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
 	var task model.Task
 
-	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		setErrorResponse(w, "failed to read request", err)
+		return
+	}
+	if err := json.Unmarshal(body, &task); err != nil {
 		setErrorResponse(w, "JSON deserialization error", err)
 		return
 	}
+	// Веб-интерфейс не передаёт приоритет и проект, поэтому без полей priority и project_id они не меняются
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(body, &fields)
+	_, hasPriority := fields["priority"]
+	_, hasProject := fields["project_id"]
 	if len(task.ID) == 0 {
 		setErrorResponse(w, "invalid id", errors.New("id is empty"))
 		return
@@ -156,88 +202,144 @@ func TaskUpdatePut(w http.ResponseWriter, r *http.Request) {
 		setErrorResponse(w, "invalid id", err)
 		return
 	}
-	parseDate, err := time.Parse(model.DatePat, task.Date)
-	if err != nil {
+	// В отличие от создания задачи дата обязательна
+	if _, err := time.Parse(model.DatePat, task.Date); err != nil {
 		setErrorResponse(w, "invalid date format", err)
 		return
 	}
-	if parseDate.Before(time.Now()) {
-		// как в создании задачи
-		task.Date = time.Now().Format(model.DatePat)
-		// либо второй вариант
-		// setErrorResponse(w, "invalid date format", errors.New("date can't be in the past"))
-		// return
+	if err := dates.CheckTask(&task, time.Now()); err != nil {
+		setErrorResponse(w, "invalid task", err)
+		return
 	}
-	if len(task.Title) == 0 {
-		setErrorResponse(w, "invalid title", errors.New("title is empty"))
+
+	// Изменение требует версию задачи в заголовке If-Match или в поле version
+	version, conflictStatus := expectedVersion(r, task.Version)
+	if len(version) == 0 {
+		setErrorStatus(w, http.StatusPreconditionRequired, "failed to update task",
+			errors.New("If-Match header or version field is required"))
 		return
 	}
-	if len(task.Repeat) > 0 {
-		if _, err := dates.GetNextDate(time.Now(), task.Date, task.Repeat); err != nil {
-			setErrorResponse(w, "invalid repeat format", errors.New("no such format"))
+
+	oldTask, err := storage.GetTaskById(userID, task.ID)
+	if err != nil {
+		setErrorResponse(w, "failed to update task", errors.New("failed to update task"))
+		return
+	}
+	if version == anyVersion {
+		version = oldTask.Version
+	}
+	if version != oldTask.Version {
+		setConflictResponse(w, conflictStatus, oldTask)
+		return
+	}
+	task.Version = version
+	if !hasPriority {
+		task.Priority = oldTask.Priority
+	}
+	if !hasProject {
+		task.ProjectID = oldTask.ProjectID
+	}
+
+	updated, err := storage.UpdateTask(userID, task)
+	if errors.Is(err, storage.ErrVersionConflict) {
+		if current, err := storage.GetTaskById(userID, task.ID); err == nil {
+			setConflictResponse(w, conflictStatus, current)
 			return
 		}
 	}
-
-	_, err = storage.UpdateTask(task)
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to update task", err)
+		return
+	}
+	if errors.Is(err, storage.ErrProjectNotFound) || errors.Is(err, storage.ErrProjectArchived) || isDependencyError(err) {
+		setErrorResponse(w, "failed to update task", err)
+		return
+	}
 	if err != nil {
 		setErrorResponse(w, "failed to update task", errors.New("failed to update task"))
 		return
 	}
+	recordAudit(r, model.AuditUpdate, task.ID, &oldTask, &updated)
 
+	w.Header().Set("ETag", etag(updated.Version))
 	jsonResponse(w, http.StatusOK)
-	if err := json.NewEncoder(w).Encode(task); err != nil {
+	if err := json.NewEncoder(w).Encode(updated); err != nil {
 		setErrorResponse(w, "failed to encode response", err)
 		return
 	}
 
 }
 func TaskDonePost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
 	id := r.URL.Query().Get("id")
-	task, err := storage.GetTaskById(id)
-	if err != nil {
-		setErrorResponse(w, "failed to get task by id", err)
-		return
-	}
 
-	if task.Repeat == "" {
-		err = storage.DeleteTask(task.ID)
-		if err != nil {
-			setErrorResponse(w, "failed to delete task", err)
-			return
-		}
-		log.Println(fmt.Sprintf("task with id=%s was deleted", task.ID))
-	} else {
-		task.Date, err = dates.GetNextDate(time.Now(), task.Date, task.Repeat)
-		if err != nil {
-			setErrorResponse(w, "failed to get next date", err)
-			return
-		}
-		// Обновляем задачу с новой датой
-		_, err = storage.UpdateTask(task)
-		if err != nil {
-			setErrorResponse(w, "failed to update task", err)
+	// Версию можно передать в заголовке If-Match или в параметре version. Кнопка
+	// выполнения в веб-интерфейсе версию не передаёт, поэтому здесь она необязательна
+	version, conflictStatus := expectedVersion(r, r.URL.Query().Get("version"))
+	if version == anyVersion {
+		version = ""
+	}
+	// Задачу, которую блокируют невыполненные задачи, можно выполнить только с параметром force=true
+	var force bool
+	if value := r.URL.Query().Get("force"); len(value) > 0 {
+		var err error
+		if force, err = strconv.ParseBool(value); err != nil {
+			setErrorResponse(w, "invalid force", err)
 			return
 		}
 	}
 
+	oldTask, task, err := storage.CompleteTask(userID, id, version, force, time.Now())
+	if errors.Is(err, storage.ErrVersionConflict) {
+		setConflictResponse(w, conflictStatus, oldTask)
+		return
+	}
+	if errors.Is(err, storage.ErrTaskBlocked) {
+		setErrorStatus(w, http.StatusConflict, "failed to complete task", err)
+		return
+	}
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to complete task", err)
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to complete task", err)
+		return
+	}
+	recordAudit(r, model.AuditDone, task.ID, &oldTask, &task)
+
 	jsonResponse(w, http.StatusOK)
 	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
 		setErrorResponse(w, "failed to encode response", err)
 		return
 	}
 
-	log.Println(fmt.Sprintf("Updated task with id=%s", task.ID))
-
+	if task.Done {
+		log.Println(fmt.Sprintf("task with id=%s was marked as done", task.ID))
+	} else {
+		log.Println(fmt.Sprintf("Updated task with id=%s", task.ID))
+	}
 }
 func TaskDelete(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
 	id := r.URL.Query().Get("id")
 
-	err := storage.DeleteTask(id)
+	oldTask, err := storage.FindTask(userID, id)
+	if err != nil {
+		setErrorResponse(w, "failed to delete task", err)
+		return
+	}
+
+	err = storage.DeleteTask(userID, id)
+	if errors.Is(err, storage.ErrForbidden) {
+		setErrorStatus(w, http.StatusForbidden, "failed to delete task", err)
+		return
+	}
 	if err != nil {
 		setErrorResponse(w, "failed to delete task", err)
 		return
 	}
+	recordAudit(r, model.AuditDelete, id, &oldTask, nil)
 
 	jsonResponse(w, http.StatusOK)
 	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
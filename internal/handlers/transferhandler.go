@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/transfer"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// maxImportSize ограничивает размер загружаемого файла с задачами
+const maxImportSize = 10 << 20
+
+// ExportGet выгружает все задачи с историей выполнения в формате json, csv или todotxt
+func ExportGet(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if len(format) == 0 {
+		format = transfer.FormatJSON
+	}
+	contentType, err := transfer.ContentType(format)
+	if err != nil {
+		setErrorResponse(w, "invalid format", err)
+		return
+	}
+
+	ext := format
+	if format == transfer.FormatTodoTxt {
+		ext = "txt"
+	}
+	filename := fmt.Sprintf("tasks-%s.%s", time.Now().Format("20060102-150405"), ext)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+	// Заголовки уже отправлены, поэтому ошибку можно только залогировать
+	if err := transfer.Export(w, middleware.UserID(r.Context()), format); err != nil {
+		log.Printf("writing export error: %v", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Sent export %s", filename))
+}
+
+// ImportPost загружает задачи из файла json, csv или todotxt и возвращает отчёт о загрузке.
+// Параметр dry_run только проверяет файл, mode задаёт обработку дубликатов: skip или overwrite
+func ImportPost(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if len(format) == 0 {
+		format = transfer.FormatJSON
+	}
+	if _, err := transfer.ContentType(format); err != nil {
+		setErrorResponse(w, "invalid format", err)
+		return
+	}
+	opts, err := importOptions(r)
+	if err != nil {
+		setErrorResponse(w, "invalid import options", err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	upload, err := openUpload(r, "file")
+	if err != nil {
+		setErrorResponse(w, "failed to read upload", err)
+		return
+	}
+	defer upload.Close()
+
+	rows, err := transfer.Decode(upload, format)
+	if err != nil {
+		setErrorResponse(w, "failed to parse file", err)
+		return
+	}
+
+	writeImportReport(w, transfer.ImportRows(rows, opts))
+}
+
+// importOptions разбирает параметры загрузки dry_run и mode. Сохранённые задачи записываются в журнал аудита
+func importOptions(r *http.Request) (transfer.Options, error) {
+	query := r.URL.Query()
+	opts := transfer.Options{
+		Mode:   query.Get("mode"),
+		UserID: middleware.UserID(r.Context()),
+		OnChange: func(action string, oldTask *model.Task, newTask model.Task) {
+			recordAudit(r, action, newTask.ID, oldTask, &newTask)
+		},
+	}
+	if len(opts.Mode) == 0 {
+		opts.Mode = transfer.ModeSkip
+	}
+	if opts.Mode != transfer.ModeSkip && opts.Mode != transfer.ModeOverwrite {
+		return opts, fmt.Errorf("unknown mode %q", opts.Mode)
+	}
+	if value := query.Get("dry_run"); len(value) > 0 {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("bad dry_run: %w", err)
+		}
+		opts.DryRun = dryRun
+	}
+	return opts, nil
+}
+
+// writeImportReport отправляет отчёт о загрузке задач
+func writeImportReport(w http.ResponseWriter, report model.ImportReport) {
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Imported tasks: created=%d updated=%d skipped=%d failed=%d dry_run=%t",
+		report.Created, report.Updated, report.Skipped, report.Failed, report.DryRun))
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Zelvalna/go_final_project/config"
+	"github.com/Zelvalna/go_final_project/internal/middleware"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// TrashGet возвращает задачи, находящиеся в корзине
+func TrashGet(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	tasks, err := storage.ReadTrash(userID)
+	if err != nil {
+		setErrorResponse(w, "failed to get trash", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.Tasks{Tasks: tasks}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Read %d tasks from trash", len(tasks)))
+}
+
+// TaskRestorePost возвращает задачу из корзины
+func TaskRestorePost(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+
+	oldTask, err := storage.FindTask(userID, id)
+	if err != nil {
+		setErrorResponse(w, "failed to restore task", err)
+		return
+	}
+
+	err = storage.RestoreTask(userID, id)
+	if err != nil {
+		setErrorResponse(w, "failed to restore task", err)
+		return
+	}
+	task, err := storage.FindTask(userID, id)
+	if err != nil {
+		log.Printf("failed to read restored task with id=%s: %v", id, err)
+		task = oldTask
+		task.DeletedAt = ""
+	}
+	recordAudit(r, model.AuditRestore, id, &oldTask, &task)
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Restored task with id=%s", id))
+}
+
+// TrashDelete окончательно удаляет задачу из корзины вместе с файлами её вложений
+func TrashDelete(w http.ResponseWriter, r *http.Request, cfg config.Config) {
+	userID := middleware.UserID(r.Context())
+	id := r.URL.Query().Get("id")
+
+	oldTask, err := storage.FindTask(userID, id)
+	if err != nil {
+		setErrorResponse(w, "failed to purge task", err)
+		return
+	}
+
+	files, err := storage.PurgeTask(userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		setErrorStatus(w, http.StatusNotFound, "failed to purge task", errors.New("task is not in trash"))
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to purge task", err)
+		return
+	}
+	attachmentStore(cfg).Remove(files...)
+	recordAudit(r, model.AuditPurge, id, &oldTask, nil)
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(struct{}{}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Purged task with id=%s and %d attachments", id, len(files)))
+}
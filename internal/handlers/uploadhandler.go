@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"io"
+	"mime"
+	"net/http"
+)
+
+// openUpload возвращает содержимое загруженного файла: поле field формы multipart/form-data
+// или всё тело запроса для остальных типов содержимого
+func openUpload(r *http.Request, field string) (io.ReadCloser, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	file, _, err := r.FormFile(field)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// UsersGet возвращает всех пользователей
+func UsersGet(w http.ResponseWriter, r *http.Request) {
+	users, err := storage.ReadUsers()
+	if err != nil {
+		setErrorResponse(w, "failed to get users", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK)
+	if err := json.NewEncoder(w).Encode(model.Users{Users: users}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+
+	log.Println(fmt.Sprintf("Read %d users", len(users)))
+}
+
+// UserAddPost регистрирует пользователя с именем и паролем. Новый пользователь не администратор
+func UserAddPost(w http.ResponseWriter, r *http.Request) {
+	var user model.User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		setErrorResponse(w, "JSON deserialization error", err)
+		return
+	}
+	if err := dates.CheckUser(&user); err != nil {
+		setErrorResponse(w, "invalid user", err)
+		return
+	}
+	user.Admin = false
+
+	userId, err := storage.InsertUser(user, time.Now())
+	if errors.Is(err, storage.ErrUserExists) {
+		setErrorStatus(w, http.StatusConflict, "failed to create user", err)
+		return
+	}
+	if err != nil {
+		setErrorResponse(w, "failed to create user", err)
+		return
+	}
+
+	jsonResponse(w, http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(model.TaskIdResponse{Id: userId}); err != nil {
+		setErrorResponse(w, "failed to encode response", err)
+		return
+	}
+	log.Println(fmt.Sprintf("Added user with id=%d", userId))
+}
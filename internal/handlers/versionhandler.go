@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// anyVersion означает, что клиент согласен изменить задачу любой версии (If-Match: *)
+const anyVersion = "*"
+
+// etag форматирует версию задачи для заголовка ETag
+func etag(version string) string {
+	return `"` + version + `"`
+}
+
+// expectedVersion возвращает версию задачи, которую клиент рассчитывает изменить, и код ответа
+// на случай её несовпадения: 412 для заголовка If-Match и 409 для поля version
+func expectedVersion(r *http.Request, fieldVersion string) (string, int) {
+	if ifMatch := strings.TrimSpace(r.Header.Get("If-Match")); len(ifMatch) > 0 {
+		if ifMatch == anyVersion {
+			return anyVersion, http.StatusPreconditionFailed
+		}
+		return strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`), http.StatusPreconditionFailed
+	}
+	return fieldVersion, http.StatusConflict
+}
+
+// setConflictResponse отправляет ответ о конфликте версий вместе с актуальной копией задачи
+func setConflictResponse(w http.ResponseWriter, status int, current model.Task) {
+	w.Header().Set("ETag", etag(current.Version))
+	jsonResponse(w, status)
+	conflict := model.ConflictResponse{
+		Error: fmt.Sprintf("task was modified, current version is %s", current.Version),
+		Task:  current,
+	}
+	if err := json.NewEncoder(w).Encode(conflict); err != nil {
+		http.Error(w, fmt.Errorf("error: %w", err).Error(), status)
+	}
+}
@@ -0,0 +1,339 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// ErrUnsupportedRule возвращается для правил RRULE, которые нельзя выразить правилом повтора задачи
+var ErrUnsupportedRule = errors.New("unsupported rule")
+
+// Property свойство компонента календаря
+type Property struct {
+	Params map[string]string
+	Value  string
+}
+
+// Component компонент календаря VTODO или VEVENT со свойствами верхнего уровня
+type Component struct {
+	Name  string
+	Props map[string]Property
+}
+
+// Item задача, полученная из компонента, или ошибка её преобразования
+type Item struct {
+	// Ref UID компонента или, если его нет, заголовок
+	Ref  string
+	Task model.Task
+	Err  error
+}
+
+// textUnescaper восстанавливает специальные символы в значениях типа TEXT
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// ReadComponents разбирает календарь и возвращает его компоненты VTODO и VEVENT.
+// Вложенные компоненты, например VALARM, пропускаются
+func ReadComponents(r io.Reader) ([]Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	components := []Component{}
+	var current *Component
+	depth := 0
+	calendar := false
+	for _, line := range lines {
+		name, params, value, err := parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "BEGIN":
+			value = strings.ToUpper(value)
+			switch {
+			case value == "VCALENDAR" && !calendar:
+				calendar = true
+			case current == nil && (value == ComponentEvent || value == ComponentTodo):
+				current = &Component{Name: value, Props: map[string]Property{}}
+			case current != nil:
+				depth++
+			}
+		case "END":
+			switch {
+			case current != nil && depth > 0:
+				depth--
+			case current != nil:
+				components = append(components, *current)
+				current = nil
+			}
+		default:
+			if current == nil || depth > 0 {
+				continue
+			}
+			// Сохраняем только первое вхождение свойства
+			if _, ok := current.Props[name]; !ok {
+				current.Props[name] = Property{Params: params, Value: value}
+			}
+		}
+	}
+	if !calendar {
+		return nil, errors.New("VCALENDAR is missing")
+	}
+	return components, nil
+}
+
+// Completed сообщает, отмечена ли задача VTODO выполненной
+func (c Component) Completed() bool {
+	_, completed := c.Props["COMPLETED"]
+	return completed || strings.EqualFold(c.Props["STATUS"].Value, "COMPLETED")
+}
+
+// unfold читает строки содержимого, склеивая перенесённые строки
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	lines := []string{}
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine разбирает строку вида NAME;PARAM=VALUE:VALUE
+func parseLine(line string) (string, map[string]string, string, error) {
+	// Двоеточие внутри кавычек относится к значению параметра
+	colon := -1
+	quoted := false
+	for i, ch := range line {
+		if ch == '"' {
+			quoted = !quoted
+		}
+		if ch == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", fmt.Errorf("invalid content line %q", line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		key, value, _ := strings.Cut(part, "=")
+		params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:], nil
+}
+
+// Items преобразует компоненты в задачи. SUMMARY становится заголовком, DESCRIPTION - комментарием,
+// DUE (для VTODO) или DTSTART - датой, а RRULE - правилом повтора. Ошибка в одном компоненте
+// не мешает преобразованию остальных
+func Items(components []Component, loc *time.Location) []Item {
+	items := make([]Item, 0, len(components))
+	for _, c := range components {
+		item := Item{Ref: c.Props["UID"].Value}
+		item.Task, item.Err = toTask(c, loc)
+		if len(item.Ref) == 0 {
+			item.Ref = item.Task.Title
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func toTask(c Component, loc *time.Location) (model.Task, error) {
+	task := model.Task{
+		Title:   unescapeText(c.Props["SUMMARY"].Value),
+		Comment: unescapeText(c.Props["DESCRIPTION"].Value),
+	}
+	// Задачи, выгруженные этим приложением, сохраняют свой ID
+	if id, ok := strings.CutPrefix(c.Props["UID"].Value, "task-"); ok {
+		if id, ok := strings.CutSuffix(id, "@go_final_project"); ok {
+			task.ID = id
+		}
+	}
+
+	prop, ok := c.Props["DTSTART"]
+	if due, hasDue := c.Props["DUE"]; c.Name == ComponentTodo && hasDue {
+		prop, ok = due, true
+	}
+	if ok {
+		date, err := parseDate(prop, loc)
+		if err != nil {
+			return task, err
+		}
+		task.Date = date.Format(model.DatePat)
+	}
+	if c.Name == ComponentTodo && c.Completed() {
+		task.Done = true
+	}
+	// PRIORITY 1-9 сводится к приоритетам 1-4, 0 и некорректные значения означают отсутствие приоритета
+	if priority, err := strconv.Atoi(strings.TrimSpace(c.Props["PRIORITY"].Value)); err == nil && priority >= 1 && priority <= 9 {
+		task.Priority = min((priority+1)/2, model.MaxPriority)
+	}
+
+	if rule, ok := c.Props["RRULE"]; ok {
+		if len(task.Date) == 0 {
+			return task, errors.New("RRULE without DTSTART")
+		}
+		start, _ := time.Parse(model.DatePat, task.Date)
+		repeat, err := FromRRule(rule.Value, start)
+		if err != nil {
+			return task, err
+		}
+		task.Repeat = repeat
+	}
+	return task, nil
+}
+
+// parseDate разбирает значение DATE или DATE-TIME. Время в UTC переводится в часовой пояс loc,
+// а время с TZID или без пояса берётся как есть
+func parseDate(prop Property, loc *time.Location) (time.Time, error) {
+	value := prop.Value
+	switch {
+	case len(value) == len(model.DatePat):
+		return time.Parse(model.DatePat, value)
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse(stampPat, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q: %w", value, err)
+		}
+		return t.In(loc), nil
+	default:
+		t, err := time.Parse("20060102T150405", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q: %w", value, err)
+		}
+		return t, nil
+	}
+}
+
+// FromRRule переводит RRULE с первым повторением start в правило повтора задачи.
+// Правила с ограничением COUNT или UNTIL, интервалом у недельных и месячных правил
+// и прочими частями, которых нет в правилах задач, не поддерживаются
+func FromRRule(rule string, start time.Time) (string, error) {
+	parts := map[string]string{}
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return "", fmt.Errorf("%w: invalid part %q", ErrUnsupportedRule, part)
+		}
+		parts[strings.ToUpper(key)] = strings.ToUpper(value)
+	}
+	unsupported := func(reason string) (string, error) {
+		return "", fmt.Errorf("%w: %s in %q", ErrUnsupportedRule, reason, rule)
+	}
+
+	interval := 1
+	if value, ok := parts["INTERVAL"]; ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return unsupported("invalid INTERVAL")
+		}
+		interval = n
+	}
+	freq := parts["FREQ"]
+	delete(parts, "FREQ")
+	delete(parts, "INTERVAL")
+	delete(parts, "WKST")
+	for _, key := range []string{"COUNT", "UNTIL"} {
+		if _, ok := parts[key]; ok {
+			return unsupported(key)
+		}
+	}
+	byDay, hasByDay := parts["BYDAY"]
+	byMonthDay, hasByMonthDay := parts["BYMONTHDAY"]
+	byMonth, hasByMonth := parts["BYMONTH"]
+	delete(parts, "BYDAY")
+	delete(parts, "BYMONTHDAY")
+	delete(parts, "BYMONTH")
+	for key := range parts {
+		return unsupported(key)
+	}
+
+	switch freq {
+	case "DAILY":
+		if hasByDay || hasByMonthDay || hasByMonth {
+			return unsupported("BY parts in DAILY rule")
+		}
+		if interval > 400 {
+			return unsupported("INTERVAL over 400 days")
+		}
+		return fmt.Sprintf("d %d", interval), nil
+	case "WEEKLY":
+		if hasByMonthDay || hasByMonth {
+			return unsupported("BYMONTHDAY or BYMONTH in WEEKLY rule")
+		}
+		if !hasByDay {
+			if interval*7 > 400 {
+				return unsupported("INTERVAL over 400 days")
+			}
+			return fmt.Sprintf("d %d", interval*7), nil
+		}
+		if interval != 1 {
+			return unsupported("INTERVAL with BYDAY")
+		}
+		days := []string{}
+		for _, day := range strings.Split(byDay, ",") {
+			n := slices.Index(weekdays, day)
+			if n < 1 {
+				return unsupported("BYDAY " + day)
+			}
+			days = append(days, strconv.Itoa(n))
+		}
+		return "w " + strings.Join(days, ","), nil
+	case "MONTHLY", "YEARLY":
+		if interval != 1 {
+			return unsupported("INTERVAL")
+		}
+		if hasByDay {
+			return unsupported("BYDAY")
+		}
+		if freq == "YEARLY" && !hasByMonthDay && !hasByMonth {
+			return "y", nil
+		}
+		if !hasByMonthDay {
+			byMonthDay = strconv.Itoa(start.Day())
+		}
+		days, ok := parseNumbers(byMonthDay, -2, 31)
+		if !ok || slices.Contains(days, 0) {
+			return unsupported("BYMONTHDAY " + byMonthDay)
+		}
+		if freq == "YEARLY" && !hasByMonth {
+			byMonth = strconv.Itoa(int(start.Month()))
+			hasByMonth = true
+		}
+		repeat := "m " + joinNumbers(days)
+		if hasByMonth {
+			months, ok := parseNumbers(byMonth, 1, 12)
+			if !ok {
+				return unsupported("BYMONTH " + byMonth)
+			}
+			repeat += " " + joinNumbers(months)
+		}
+		return repeat, nil
+	}
+	return unsupported("FREQ " + freq)
+}
+
+// unescapeText восстанавливает значение типа TEXT
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
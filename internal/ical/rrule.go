@@ -0,0 +1,92 @@
+package ical
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// weekdays названия дней недели в RRULE, индекс совпадает с номером дня в правиле "w"
+var weekdays = []string{"", "MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// RRule переводит правило повтора задачи с датой dateStr в RRULE. Первое повторение
+// задачи - её собственная дата, поэтому она используется как DTSTART.
+// Если правило нельзя выразить через RRULE, возвращается false
+func RRule(dateStr, repeat string) (string, bool) {
+	date, err := time.Parse(model.DatePat, dateStr)
+	if err != nil {
+		return "", false
+	}
+	fields := strings.Fields(repeat)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	switch {
+	case fields[0] == "y" && len(fields) == 1:
+		// Повтор 29 февраля сдвигается на 1 марта, а в RRULE остаётся только в високосных годах
+		if date.Month() == time.February && date.Day() == 29 {
+			return "", false
+		}
+		return "FREQ=YEARLY", true
+	case fields[0] == "d" && len(fields) == 2:
+		days, err := strconv.Atoi(fields[1])
+		if err != nil || days < 1 || days > 400 {
+			return "", false
+		}
+		return fmt.Sprintf("FREQ=DAILY;INTERVAL=%d", days), true
+	case fields[0] == "w" && len(fields) == 2:
+		days, ok := parseNumbers(fields[1], 1, 7)
+		if !ok {
+			return "", false
+		}
+		byDay := make([]string, 0, len(days))
+		for _, day := range days {
+			byDay = append(byDay, weekdays[day])
+		}
+		return "FREQ=WEEKLY;BYDAY=" + strings.Join(byDay, ","), true
+	case fields[0] == "m" && (len(fields) == 2 || len(fields) == 3):
+		// Отрицательные дни -1 и -2 означают последний и предпоследний день месяца, как в BYMONTHDAY
+		days, ok := parseNumbers(fields[1], -2, 31)
+		if !ok || slices.Contains(days, 0) {
+			return "", false
+		}
+		rule := "FREQ=MONTHLY"
+		if len(fields) == 3 {
+			months, ok := parseNumbers(fields[2], 1, 12)
+			if !ok {
+				return "", false
+			}
+			rule += ";BYMONTH=" + joinNumbers(months)
+		}
+		return rule + ";BYMONTHDAY=" + joinNumbers(days), true
+	}
+	return "", false
+}
+
+// parseNumbers разбирает список чисел через запятую из диапазона [min, max]
+func parseNumbers(list string, min, max int) ([]int, bool) {
+	parts := strings.Split(list, ",")
+	result := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < min || n > max {
+			return nil, false
+		}
+		result = append(result, n)
+	}
+	return result, true
+}
+
+// joinNumbers записывает числа через запятую
+func joinNumbers(numbers []int) string {
+	parts := make([]string, 0, len(numbers))
+	for _, n := range numbers {
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,164 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// Типы компонентов, в которые выгружаются задачи
+const (
+	ComponentEvent = "VEVENT"
+	ComponentTodo  = "VTODO"
+)
+
+const (
+	// stampPat формат времени DTSTAMP в UTC
+	stampPat = "20060102T150405Z"
+	// maxLineLen наибольшая длина строки в октетах, после которой строка переносится
+	maxLineLen = 75
+	// expandYears на сколько лет вперёд разворачиваются правила, не выразимые через RRULE
+	expandYears = 1
+)
+
+// textEscaper экранирует специальные символы в значениях типа TEXT
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// UID возвращает постоянный идентификатор задачи в календаре
+func UID(id string) string {
+	return "task-" + id + "@go_final_project"
+}
+
+// WriteCalendar записывает задачи в w в формате iCalendar (RFC 5545). Каждая задача
+// выгружается компонентом component на целый день. Повтор переводится в RRULE,
+// а если это невозможно, повторения на год вперёд перечисляются в RDATE
+func WriteCalendar(w io.Writer, tasks []model.Task, component string, now time.Time) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.begin()
+	e.line("X-WR-CALNAME:" + escapeText("Планировщик задач"))
+	for _, task := range tasks {
+		e.task(task, UID(task.ID), component, now)
+	}
+	return e.end()
+}
+
+// WriteResource записывает календарь из одной задачи VTODO с указанным UID,
+// как его хранят клиенты CalDAV
+func WriteResource(w io.Writer, task model.Task, uid string, now time.Time) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.begin()
+	e.task(task, uid, ComponentTodo, now)
+	return e.end()
+}
+
+// encoder пишет строки календаря и запоминает первую ошибку записи
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) begin() {
+	e.line("BEGIN:VCALENDAR")
+	e.line("VERSION:2.0")
+	e.line("PRODID:-//Zelvalna//go_final_project//RU")
+	e.line("CALSCALE:GREGORIAN")
+}
+
+func (e *encoder) end() error {
+	e.line("END:VCALENDAR")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+func (e *encoder) task(task model.Task, uid string, component string, now time.Time) {
+	e.line("BEGIN:" + component)
+	e.line("UID:" + uid)
+	e.line("DTSTAMP:" + now.UTC().Format(stampPat))
+	if version, err := strconv.Atoi(task.Version); err == nil && version > 0 {
+		e.line("SEQUENCE:" + strconv.Itoa(version-1))
+	}
+	e.line("SUMMARY:" + escapeText(task.Title))
+	if len(task.Comment) > 0 {
+		e.line("DESCRIPTION:" + escapeText(task.Comment))
+	}
+	if task.Priority > 0 {
+		e.line("PRIORITY:" + strconv.Itoa(toICalPriority(task.Priority)))
+	}
+	e.line("DTSTART;VALUE=DATE:" + task.Date)
+	if component == ComponentTodo {
+		e.line("DUE;VALUE=DATE:" + task.Date)
+		if task.Done {
+			e.line("STATUS:COMPLETED")
+		} else {
+			e.line("STATUS:NEEDS-ACTION")
+		}
+	}
+	if len(task.Repeat) > 0 {
+		e.repeat(task, now)
+	}
+	e.line("END:" + component)
+}
+
+// toICalPriority переводит приоритет задачи 1-4 в PRIORITY iCalendar 1-9: 1, 3, 5 и 7
+func toICalPriority(priority int) int {
+	return priority*2 - 1
+}
+
+// repeat записывает правило повтора задачи
+func (e *encoder) repeat(task model.Task, now time.Time) {
+	if rule, ok := RRule(task.Date, task.Repeat); ok {
+		e.line("RRULE:" + rule)
+		return
+	}
+
+	from, err := time.Parse(model.DatePat, task.Date)
+	if err != nil {
+		return
+	}
+	horizon := now
+	if from.After(horizon) {
+		horizon = from
+	}
+	occurrences, err := dates.Occurrences(task.Date, task.Repeat, from, horizon.AddDate(expandYears, 0, 0))
+	if err != nil || len(occurrences) < 2 {
+		return
+	}
+	// Первое повторение совпадает с DTSTART
+	e.line("RDATE;VALUE=DATE:" + strings.Join(occurrences[1:], ","))
+}
+
+// line записывает строку содержимого, перенося её по RFC 5545 каждые 75 октетов
+func (e *encoder) line(s string) {
+	if e.err != nil {
+		return
+	}
+	// Строка продолжения начинается с пробела, который тоже занимает октет
+	limit := maxLineLen
+	for len(s) > limit {
+		// Не разрываем многобайтовые символы UTF-8
+		cut := limit
+		for !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, e.err = fmt.Fprintf(e.w, "%s\r\n ", s[:cut]); e.err != nil {
+			return
+		}
+		s = s[cut:]
+		limit = maxLineLen - 1
+	}
+	_, e.err = fmt.Fprintf(e.w, "%s\r\n", s)
+}
+
+// escapeText экранирует значение типа TEXT
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/storage"
+)
+
+const (
+	backupPrefix     = "scheduler-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102-150405"
+
+	preRestorePrefix     = "pre-restore-"
+	preRestoreTimeFormat = "20060102-150405.000"
+)
+
+// snapshotKind вид копий базы данных в каталоге резервных копий. Политика хранения
+// применяется к каждому виду отдельно
+type snapshotKind struct {
+	prefix     string
+	timeFormat string
+}
+
+var snapshotKinds = []snapshotKind{
+	{prefix: backupPrefix, timeFormat: backupTimeFormat},
+	{prefix: preRestorePrefix, timeFormat: preRestoreTimeFormat},
+}
+
+// PreRestorePath возвращает путь для копии базы, которая сохраняется перед восстановлением
+func PreRestorePath(dir string, now time.Time) string {
+	return filepath.Join(dir, preRestorePrefix+now.Format(preRestoreTimeFormat)+backupSuffix)
+}
+
+// BackupPolicy задаёт параметры автоматического резервного копирования
+type BackupPolicy struct {
+	// Dir каталог для резервных копий
+	Dir string
+	// Interval период между копиями
+	Interval time.Duration
+	// KeepDaily количество последних дней, для которых хранится по одной копии
+	KeepDaily int
+	// KeepWeekly количество последних недель, для которых хранится по одной копии
+	KeepWeekly int
+}
+
+// Backup сохраняет копию базы данных при запуске и далее периодически, проверяет её и удаляет
+// лишние копии. Функция блокируется, поэтому её следует запускать в отдельной горутине
+func Backup(policy BackupPolicy) {
+	// Копия при запуске нужна серверу, который перезапускается чаще, чем раз в Interval
+	if err := backup(policy, time.Now()); err != nil {
+		log.Printf("backup failed: %v", err)
+	}
+
+	ticker := time.NewTicker(policy.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := backup(policy, time.Now()); err != nil {
+			log.Printf("backup failed: %v", err)
+		}
+	}
+}
+
+// backup сохраняет и проверяет одну копию базы данных, после чего применяет политику хранения
+func backup(policy BackupPolicy, now time.Time) error {
+	if err := os.MkdirAll(policy.Dir, 0o755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(policy.Dir, backupPrefix+now.Format(backupTimeFormat)+backupSuffix)
+	if err := storage.Snapshot(path); err != nil {
+		return err
+	}
+
+	// Копию, не прошедшую PRAGMA integrity_check, не храним
+	if _, err := storage.VerifySnapshot(path); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("snapshot %s failed verification: %w", path, err)
+	}
+	log.Printf("Backup %s created, integrity check passed", path)
+
+	return Rotate(policy)
+}
+
+// Rotate удаляет лишние копии базы данных: плановые копии scheduler-<время>.db и копии
+// pre-restore-<время>.db, сохранённые перед восстановлением, хранятся по политике отдельно друг от друга
+func Rotate(policy BackupPolicy) error {
+	entries, err := os.ReadDir(policy.Dir)
+	if err != nil {
+		return err
+	}
+	for _, kind := range snapshotKinds {
+		rotate(policy, kind, entries)
+	}
+	return nil
+}
+
+// rotate оставляет самую свежую копию вида kind за каждый из KeepDaily последних дней
+// и за каждую из KeepWeekly последних недель, остальные копии удаляет. Самая свежая копия
+// хранится всегда
+func rotate(policy BackupPolicy, kind snapshotKind, entries []os.DirEntry) {
+	type snapshot struct {
+		name string
+		at   time.Time
+	}
+	snapshots := []snapshot{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, kind.prefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		at, err := time.ParseInLocation(kind.timeFormat,
+			strings.TrimSuffix(strings.TrimPrefix(name, kind.prefix), backupSuffix), time.Local)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot{name: name, at: at})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].at.After(snapshots[j].at)
+	})
+
+	keep := make(map[string]bool, len(snapshots))
+	if len(snapshots) > 0 {
+		keep[snapshots[0].name] = true
+	}
+	days := make(map[string]bool, policy.KeepDaily)
+	weeks := make(map[string]bool, policy.KeepWeekly)
+	for _, s := range snapshots {
+		day := s.at.Format("20060102")
+		if !days[day] && len(days) < policy.KeepDaily {
+			days[day] = true
+			keep[s.name] = true
+		}
+		year, week := s.at.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%02d", year, week)
+		if !weeks[weekKey] && len(weeks) < policy.KeepWeekly {
+			weeks[weekKey] = true
+			keep[s.name] = true
+		}
+	}
+
+	for _, s := range snapshots {
+		if keep[s.name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(policy.Dir, s.name)); err != nil {
+			log.Printf("failed to remove old backup %s: %v", s.name, err)
+			continue
+		}
+		log.Printf("Removed old backup %s", s.name)
+	}
+}
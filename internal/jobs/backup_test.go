@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// backupName возвращает имя плановой копии, снятой в момент at
+func backupName(at time.Time) string {
+	return backupPrefix + at.Format(backupTimeFormat) + backupSuffix
+}
+
+// preRestoreName возвращает имя копии перед восстановлением, снятой в момент at
+func preRestoreName(at time.Time) string {
+	return filepath.Base(PreRestorePath("", at))
+}
+
+func TestRotate(t *testing.T) {
+	// 18 октября 2026 года - воскресенье, последний день 42-й недели по ISO 8601
+	sunday := time.Date(2026, 10, 18, 18, 0, 0, 0, time.Local)
+	day := func(days int, hour int) time.Time {
+		return time.Date(2026, 10, 18+days, hour, 0, 0, 0, time.Local)
+	}
+	daily := func(n int) []string {
+		names := []string{}
+		for i := 0; i < n; i++ {
+			names = append(names, backupName(sunday.AddDate(0, 0, -i)))
+		}
+		return names
+	}
+
+	tests := []struct {
+		name       string
+		keepDaily  int
+		keepWeekly int
+		files      []string
+		want       []string
+	}{
+		{
+			name:      "самая свежая копия за день",
+			keepDaily: 7,
+			files:     []string{backupName(day(0, 10)), backupName(day(0, 12)), backupName(day(0, 18)), backupName(day(-1, 9))},
+			want:      []string{backupName(day(0, 18)), backupName(day(-1, 9))},
+		},
+		{
+			name:      "количество дней",
+			keepDaily: 2,
+			files:     daily(5),
+			want:      daily(2),
+		},
+		{
+			name:       "по одной копии за неделю",
+			keepDaily:  1,
+			keepWeekly: 2,
+			files:      daily(21),
+			want:       []string{backupName(sunday), backupName(sunday.AddDate(0, 0, -7))},
+		},
+		{
+			name:       "недели не ограничивают дни",
+			keepDaily:  3,
+			keepWeekly: 1,
+			files:      daily(10),
+			want:       daily(3),
+		},
+		{
+			name:  "самая свежая копия хранится всегда",
+			files: daily(3),
+			want:  daily(1),
+		},
+		{
+			name:      "копии перед восстановлением хранятся отдельно",
+			keepDaily: 1,
+			files: []string{
+				backupName(day(0, 10)), backupName(day(-1, 10)),
+				preRestoreName(day(0, 9)), preRestoreName(day(0, 11)), preRestoreName(day(-1, 11)),
+			},
+			want: []string{backupName(day(0, 10)), preRestoreName(day(0, 11))},
+		},
+		{
+			name:  "посторонние файлы не удаляются",
+			files: []string{"notes.txt", backupPrefix + "copy" + backupSuffix, backupName(day(0, 10)), backupName(day(-1, 10))},
+			want:  []string{"notes.txt", backupPrefix + "copy" + backupSuffix, backupName(day(0, 10))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+			}
+
+			err := Rotate(BackupPolicy{Dir: dir, KeepDaily: tt.keepDaily, KeepWeekly: tt.keepWeekly})
+			assert.NoError(t, err)
+
+			entries, err := os.ReadDir(dir)
+			assert.NoError(t, err)
+			got := []string{}
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			want := append([]string{}, tt.want...)
+			sort.Strings(want)
+			assert.Equal(t, want, got)
+		})
+	}
+}
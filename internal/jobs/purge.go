@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/attachments"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+)
+
+// PurgeTrash периодически удаляет задачи, пролежавшие в корзине дольше retention,
+// вместе с файлами их вложений из store.
+// Функция блокируется, поэтому её следует запускать в отдельной горутине
+func PurgeTrash(retention, interval time.Duration, store attachments.Store) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, files, err := storage.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("failed to purge trash: %v", err)
+		} else if purged > 0 {
+			store.Remove(files...)
+			log.Printf("Purged %d tasks and %d attachments from trash", purged, len(files))
+		}
+		<-ticker.C
+	}
+}
@@ -1,36 +1,113 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/Zelvalna/go_final_project/config"
-
-	"github.com/golang-jwt/jwt"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/model"
 )
 
-// Auth проверяет JWT токен
+// Auth проверяет подпись и срок действия JWT токена и то, что он не отозван, и сохраняет в контексте запроса пользователя из токена
 func Auth(nextHandler http.HandlerFunc, cfg config.Config) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		//получаем переменную окружения
-		if len(cfg.TodoPassword) > 0 {
+		// получаем куку с токеном
+		cookie, err := r.Cookie("token")
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		claims, err := parseToken(cookie.Value, cfg, time.Now())
+		if err != nil {
+			log.Printf("invalid token: %v", err)
+			http.Error(w, "Authentication failed", http.StatusUnauthorized)
+			return
+		}
+		// Токен, отозванный при выходе, больше не действует
+		revoked, err := storage.TokenRevoked(claims.Id)
+		if err != nil {
+			http.Error(w, "Failed to check token", http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			http.Error(w, "Authentication failed", http.StatusUnauthorized)
+			return
+		}
+		// Токен удалённого пользователя больше не действует
+		user, err := storage.FindUser(claims.Subject)
+		if err != nil {
+			http.Error(w, "Authentication failed", http.StatusUnauthorized)
+			return
+		}
+		nextHandler(w, withUser(r, user))
+	})
+}
 
-			// получаем куку с токеном
-			var tokenString string
-			cookie, err := r.Cookie("token")
-			if err != nil {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-			tokenString = cookie.Value
-			jwtInstance := jwt.New(jwt.SigningMethodHS256)
-			token, err := jwtInstance.SignedString([]byte(cfg.TodoPassword))
-			log.Println(token + " token из AUTH")
-			if tokenString != token {
-				http.Error(w, "Authentication failed", http.StatusUnauthorized)
-				return
-			}
+// Admin пропускает только запросы администраторов
+func Admin(nextHandler http.HandlerFunc, cfg config.Config) http.HandlerFunc {
+	return Auth(func(w http.ResponseWriter, r *http.Request) {
+		if user, _ := User(r.Context()); !user.Admin {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
 		}
 		nextHandler(w, r)
+	}, cfg)
+}
+
+// FeedAuth проверяет токен подписки в параметре token. Календари не передают куки,
+// поэтому для подписки у пользователя есть отдельный токен. Токен TODO_ICS_TOKEN, если он задан,
+// открывает подписку на задачи администратора
+func FeedAuth(nextHandler http.HandlerFunc, cfg config.Config) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if len(token) == 0 {
+			http.Error(w, "Authentication failed", http.StatusUnauthorized)
+			return
+		}
+		var user model.User
+		var err error
+		if len(cfg.ICSToken) > 0 && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.ICSToken)) == 1 {
+			user, err = storage.FindUser(model.AdminUserID)
+		} else {
+			user, err = storage.FindFeedUser(token)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Authentication failed", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to check token", http.StatusInternalServerError)
+			return
+		}
+		r = withUser(r, user)
+		nextHandler(w, withActor(r, "feed"))
+	})
+}
+
+// BasicAuth проверяет имя и пароль пользователя, переданные по схеме Basic. Клиенты CalDAV
+// не умеют получать токен через /api/signin. Без имени проверяется пароль администратора
+func BasicAuth(nextHandler http.HandlerFunc, cfg config.Config) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, password, ok := r.BasicAuth()
+		if len(name) == 0 {
+			name = model.AdminName
+		}
+		var user model.User
+		err := storage.ErrBadCredentials
+		if ok {
+			user, err = storage.Authenticate(name, password)
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="scheduler", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r = withUser(r, user)
+		nextHandler(w, withActor(r, "caldav"))
 	})
 }
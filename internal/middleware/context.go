@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+type contextKey string
+
+const (
+	actorKey contextKey = "actor"
+	userKey  contextKey = "user"
+)
+
+// withActor сохраняет в контексте запроса имя того, кто выполняет запрос
+func withActor(r *http.Request, actor string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), actorKey, actor))
+}
+
+// withUser сохраняет в контексте запроса пользователя, от имени которого выполняется запрос,
+// и его имя как автора изменений
+func withUser(r *http.Request, user model.User) *http.Request {
+	return withActor(r.WithContext(context.WithValue(r.Context(), userKey, user)), user.Name)
+}
+
+// Actor возвращает имя того, кто выполняет запрос, или "anonymous", если оно неизвестно
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok {
+		return actor
+	}
+	return "anonymous"
+}
+
+// User возвращает пользователя, от имени которого выполняется запрос
+func User(ctx context.Context) (model.User, bool) {
+	user, ok := ctx.Value(userKey).(model.User)
+	return user, ok
+}
+
+// UserID возвращает ID пользователя, от имени которого выполняется запрос, или пустую строку,
+// если запрос выполняется без входа. Пустой ID не совпадает ни с одним владельцем задач
+func UserID(ctx context.Context) string {
+	user, _ := User(ctx)
+	return user.ID
+}
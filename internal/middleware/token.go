@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/config"
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/model"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// randomHex возвращает n случайных байт в шестнадцатеричном виде
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewFeedToken создаёт случайный токен подписки на календарь
+func NewFeedToken() (string, error) {
+	return randomHex(32)
+}
+
+// NewSecret создаёт случайный ключ подписи токенов
+func NewSecret() (string, error) {
+	return randomHex(32)
+}
+
+// NewToken выпускает токен пользователя со сроком действия cfg.TokenTTL. ID пользователя передаётся
+// в поле sub, время выпуска и окончания действия - в полях iat и exp, уникальный ID токена - в поле jti
+func NewToken(user model.User, cfg config.Config, now time.Time) (string, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject:   user.ID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(cfg.TokenTTL).Unix(),
+		Id:        jti,
+	})
+	return token.SignedString([]byte(cfg.JWTSecret))
+}
+
+// parseToken проверяет подпись и срок действия токена и возвращает его поля. Токен без sub или jti
+// не принимается: без jti его нельзя отозвать
+func parseToken(tokenString string, cfg config.Config, now time.Time) (jwt.StandardClaims, error) {
+	var claims jwt.StandardClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (any, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return []byte(cfg.JWTSecret), nil
+	})
+	if err != nil {
+		return jwt.StandardClaims{}, err
+	}
+	// Библиотека проверяет exp, только если поле задано, а токен без срока действия не принимается
+	if !claims.VerifyExpiresAt(now.Unix(), true) {
+		return jwt.StandardClaims{}, fmt.Errorf("token has no expiration or is expired")
+	}
+	if len(claims.Subject) == 0 {
+		return jwt.StandardClaims{}, fmt.Errorf("token has no subject")
+	}
+	if len(claims.Id) == 0 {
+		return jwt.StandardClaims{}, fmt.Errorf("token has no id")
+	}
+	return claims, nil
+}
+
+// RevokeToken отзывает действующий токен. Отозванный токен отклоняется до окончания срока его действия
+func RevokeToken(tokenString string, cfg config.Config, now time.Time) error {
+	claims, err := parseToken(tokenString, cfg, now)
+	if err != nil {
+		return err
+	}
+	return storage.RevokeToken(claims.Id, time.Unix(claims.ExpiresAt, 0), now)
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrAssignmentState возвращается, если на назначение задачи нельзя ответить в его текущем состоянии
+var ErrAssignmentState = errors.New("task assignment cannot be changed in its current state")
+
+// assigneeColumn возвращает подзапрос с именем исполнителя с ID idColumn
+func assigneeColumn(idColumn string) string {
+	return `COALESCE((SELECT u.name FROM users u WHERE u.id = ` + idColumn + `), '')`
+}
+
+// assignedTasks возвращает подзапрос с ID задач, назначенных пользователю :user_id, от которых он
+// не отказался. Если edit равен true, возвращаются только принятые задачи, которые он может изменять
+func assignedTasks(edit bool) string {
+	if edit {
+		return `SELECT id FROM scheduler WHERE assignee_id = :user_id AND assignee_status = '` + model.AssignAccepted + `'`
+	}
+	return `SELECT id FROM scheduler WHERE assignee_id = :user_id
+		AND assignee_status IN ('` + model.AssignPending + `', '` + model.AssignAccepted + `')`
+}
+
+// assignment состояние назначения активной задачи
+type assignment struct {
+	Owner    string `db:"user_id"`
+	Assignee string `db:"assignee_id"`
+	Status   string `db:"assignee_status"`
+}
+
+// readAssignment читает состояние назначения активной задачи id
+func readAssignment(tx *sqlx.Tx, id string) (assignment, error) {
+	var a assignment
+	err := tx.Get(&a, "SELECT user_id, assignee_id, assignee_status FROM scheduler WHERE id = :id AND deleted_at = '' AND done = 0",
+		sql.Named("id", id))
+	return a, err
+}
+
+// notify добавляет уведомление пользователю userID об изменении назначения задачи id,
+// которое сделал пользователь actorID
+func notify(tx *sqlx.Tx, userID string, id string, kind string, actorID string, now time.Time) error {
+	_, err := tx.Exec(`INSERT INTO notifications (user_id, task_id, title, kind, actor, created_at)
+		SELECT :user_id, id, title, :kind, (SELECT name FROM users WHERE id = :actor_id), :created_at
+		FROM scheduler WHERE id = :id`,
+		sql.Named("user_id", userID),
+		sql.Named("kind", kind),
+		sql.Named("actor_id", actorID),
+		sql.Named("created_at", now.UTC().Format(time.RFC3339)),
+		sql.Named("id", id))
+	return err
+}
+
+// readTask читает задачу по ID в транзакции
+func readTask(tx *sqlx.Tx, id string) (model.Task, error) {
+	return scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id", sql.Named("id", id)))
+}
+
+// AssignTask назначает активную задачу пользователя исполнителю с именем assignee, а с пустым
+// именем снимает назначение. Назначать задачу может только её владелец. Исполнитель получает
+// уведомление NotifyAssigned, а прежний исполнитель, если он не отказался от задачи, - NotifyUnassigned.
+// Повторное назначение тому же исполнителю ничего не меняет, пока он не отказался от задачи.
+// Возвращает задачу до и после изменения
+func AssignTask(userID string, id string, assignee string, now time.Time) (model.Task, model.Task, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	defer tx.Rollback()
+
+	owner, err := taskOwner(tx, userID, id, false)
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	if owner != userID {
+		return model.Task{}, model.Task{}, ErrForbidden
+	}
+	current, err := readAssignment(tx, id)
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	oldTask, err := readTask(tx, id)
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+
+	assigneeID, status := "0", ""
+	if len(assignee) > 0 {
+		err := tx.Get(&assigneeID, "SELECT id FROM users WHERE key = :key", sql.Named("key", userKey(assignee)))
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Task{}, model.Task{}, ErrUserNotFound
+		}
+		if err != nil {
+			return model.Task{}, model.Task{}, err
+		}
+		if assigneeID == userID {
+			return model.Task{}, model.Task{}, errors.New("cannot assign task to its owner")
+		}
+		status = model.AssignPending
+	}
+	active := current.Status == model.AssignPending || current.Status == model.AssignAccepted
+	if assigneeID == current.Assignee && (active || len(status) == 0) {
+		return oldTask, oldTask, nil
+	}
+
+	_, err = tx.Exec("UPDATE scheduler SET assignee_id = :assignee_id, assignee_status = :status, version = version + 1 WHERE id = :id",
+		sql.Named("assignee_id", assigneeID),
+		sql.Named("status", status),
+		sql.Named("id", id))
+	if err != nil {
+		return oldTask, model.Task{}, err
+	}
+	if active && current.Assignee != assigneeID {
+		if err := notify(tx, current.Assignee, id, model.NotifyUnassigned, userID, now); err != nil {
+			return oldTask, model.Task{}, err
+		}
+	}
+	if len(status) > 0 {
+		if err := notify(tx, assigneeID, id, model.NotifyAssigned, userID, now); err != nil {
+			return oldTask, model.Task{}, err
+		}
+	}
+
+	task, err := readTask(tx, id)
+	if err != nil {
+		return oldTask, model.Task{}, err
+	}
+	return oldTask, task, tx.Commit()
+}
+
+// AnswerAssignment принимает назначенную пользователю задачу, если accept равен true, или отказывается
+// от неё. Принять можно только ещё не принятую задачу, а отказаться - и от принятой. Владелец задачи
+// получает уведомление NotifyAccepted или NotifyDeclined. Возвращает задачу до и после изменения
+func AnswerAssignment(userID string, id string, accept bool, now time.Time) (model.Task, model.Task, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := taskOwner(tx, userID, id, false); err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	current, err := readAssignment(tx, id)
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	if current.Assignee != userID {
+		return model.Task{}, model.Task{}, ErrForbidden
+	}
+	status, kind := model.AssignDeclined, model.NotifyDeclined
+	if accept {
+		status, kind = model.AssignAccepted, model.NotifyAccepted
+	}
+	if accept && current.Status != model.AssignPending {
+		return model.Task{}, model.Task{}, ErrAssignmentState
+	}
+	oldTask, err := readTask(tx, id)
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+
+	_, err = tx.Exec("UPDATE scheduler SET assignee_status = :status, version = version + 1 WHERE id = :id",
+		sql.Named("status", status),
+		sql.Named("id", id))
+	if err != nil {
+		return oldTask, model.Task{}, err
+	}
+	if err := notify(tx, current.Owner, id, kind, userID, now); err != nil {
+		return oldTask, model.Task{}, err
+	}
+
+	task, err := readTask(tx, id)
+	if err != nil {
+		return oldTask, model.Task{}, err
+	}
+	return oldTask, task, tx.Commit()
+}
+
+// ReadNotifications читает не более limit уведомлений пользователя с ID больше since в порядке возрастания ID
+func ReadNotifications(userID string, since int64, limit int) ([]model.Notification, error) {
+	notifications := []model.Notification{}
+	err := db.Select(&notifications, `SELECT id, task_id, title, kind, actor, created_at FROM notifications
+		WHERE user_id = :user_id AND id > :since
+		ORDER BY id
+		LIMIT :limit`,
+		sql.Named("user_id", userID),
+		sql.Named("since", since),
+		sql.Named("limit", limit))
+	return notifications, err
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+const attachmentColumns = "id, task_id, name, content_type, size, file, created_at"
+
+// ReadAttachments читает вложения задачи пользователя или открытой ему задачи в порядке загрузки
+func ReadAttachments(userID string, taskID string) ([]model.Attachment, error) {
+	attachments := []model.Attachment{}
+	err := db.Select(&attachments, "SELECT "+attachmentColumns+" FROM attachments WHERE task_id = :task_id AND task_id IN ("+visibleTasks+") ORDER BY id",
+		sql.Named("task_id", taskID),
+		sql.Named("user_id", userID))
+	return attachments, err
+}
+
+// FindAttachment читает вложение задачи пользователя или открытой ему задачи по ID
+func FindAttachment(userID string, id string) (model.Attachment, error) {
+	var attachment model.Attachment
+	err := db.Get(&attachment, "SELECT "+attachmentColumns+" FROM attachments WHERE id = :id AND task_id IN ("+visibleTasks+")",
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	return attachment, err
+}
+
+// InsertAttachment сохраняет вложение активной задачи пользователя или открытой ему для изменения задачи.
+// Если задачи нет, она выполнена или находится в корзине, возвращается sql.ErrNoRows, а если она
+// открыта только для просмотра - ErrForbidden
+func InsertAttachment(userID string, attachment model.Attachment) (model.Attachment, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkActiveTask(tx, userID, attachment.TaskID); err != nil {
+		return model.Attachment{}, err
+	}
+	result, err := tx.Exec(`INSERT INTO attachments (task_id, name, content_type, size, file, created_at) 
+		VALUES (:task_id, :name, :content_type, :size, :file, :created_at)`,
+		sql.Named("task_id", attachment.TaskID),
+		sql.Named("name", attachment.Name),
+		sql.Named("content_type", attachment.ContentType),
+		sql.Named("size", attachment.Size),
+		sql.Named("file", attachment.File),
+		sql.Named("created_at", attachment.CreatedAt))
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.Attachment{}, err
+	}
+
+	var inserted model.Attachment
+	if err := tx.Get(&inserted, "SELECT "+attachmentColumns+" FROM attachments WHERE id = :id", sql.Named("id", id)); err != nil {
+		return model.Attachment{}, err
+	}
+	return inserted, tx.Commit()
+}
+
+// DeleteAttachment удаляет запись о вложении задачи пользователя или открытой ему для изменения задачи
+// и возвращает её, чтобы можно было удалить файл
+func DeleteAttachment(userID string, id string) (model.Attachment, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	defer tx.Rollback()
+
+	var attachment model.Attachment
+	err = tx.Get(&attachment, "SELECT "+attachmentColumns+" FROM attachments WHERE id = :id AND task_id IN ("+visibleTasks+")",
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	if _, err := taskOwner(tx, userID, attachment.TaskID, true); err != nil {
+		return model.Attachment{}, err
+	}
+	if _, err := tx.Exec("DELETE FROM attachments WHERE id = :id", sql.Named("id", id)); err != nil {
+		return model.Attachment{}, err
+	}
+	return attachment, tx.Commit()
+}
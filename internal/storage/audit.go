@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// AuditFilter задаёт условия выборки записей журнала аудита. Пустые поля не ограничивают выборку
+type AuditFilter struct {
+	// UserID владелец задач, пустой - записи всех пользователей
+	UserID string
+	TaskID string
+	Actor  string
+	Action string
+	From   time.Time
+	To     time.Time
+	// Limit ограничивает количество записей, 0 - без ограничения
+	Limit int
+}
+
+// InsertAudit добавляет запись в журнал аудита владельца задачи, в том числе если её изменил
+// пользователь, которому она открыта. Запись об удалённой задаче относится к entry.UserID,
+// а без пользователя - к администратору
+func InsertAudit(entry model.AuditEntry) error {
+	if len(entry.UserID) == 0 {
+		entry.UserID = model.AdminUserID
+	}
+	_, err := db.Exec(`INSERT INTO audit (task_id, action, actor, created_at, old_json, new_json, request_id, user_id) 
+		VALUES (:task_id, :action, :actor, :created_at, :old_json, :new_json, :request_id,
+			COALESCE((SELECT user_id FROM scheduler WHERE id = :task_id), :user_id))`,
+		sql.Named("task_id", entry.TaskID),
+		sql.Named("action", entry.Action),
+		sql.Named("actor", entry.Actor),
+		sql.Named("created_at", time.Now().UTC().Format(time.RFC3339)),
+		sql.Named("old_json", string(entry.Old)),
+		sql.Named("new_json", string(entry.New)),
+		sql.Named("request_id", entry.RequestID),
+		sql.Named("user_id", entry.UserID))
+
+	return err
+}
+
+// ReadAudit читает записи журнала аудита, начиная с последних
+func ReadAudit(filter AuditFilter) ([]model.AuditEntry, error) {
+	conditions := []string{"1 = 1"}
+	args := []any{}
+	if len(filter.UserID) > 0 {
+		conditions = append(conditions, "user_id = :user_id")
+		args = append(args, sql.Named("user_id", filter.UserID))
+	}
+	if len(filter.TaskID) > 0 {
+		conditions = append(conditions, "task_id = :task_id")
+		args = append(args, sql.Named("task_id", filter.TaskID))
+	}
+	if len(filter.Actor) > 0 {
+		conditions = append(conditions, "actor = :actor")
+		args = append(args, sql.Named("actor", filter.Actor))
+	}
+	if len(filter.Action) > 0 {
+		conditions = append(conditions, "action = :action")
+		args = append(args, sql.Named("action", filter.Action))
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= :from")
+		args = append(args, sql.Named("from", filter.From.UTC().Format(time.RFC3339)))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < :to")
+		args = append(args, sql.Named("to", filter.To.UTC().Format(time.RFC3339)))
+	}
+
+	query := `SELECT id, task_id, action, actor, created_at, old_json, new_json, request_id 
+		FROM audit 
+		WHERE ` + strings.Join(conditions, " AND ") + ` 
+		ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += " LIMIT :limit"
+		args = append(args, sql.Named("limit", filter.Limit))
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return []model.AuditEntry{}, err
+	}
+	defer rows.Close()
+
+	entries := []model.AuditEntry{}
+	for rows.Next() {
+		var (
+			entry            model.AuditEntry
+			oldJSON, newJSON string
+		)
+		err := rows.Scan(&entry.ID, &entry.TaskID, &entry.Action, &entry.Actor, &entry.CreatedAt,
+			&oldJSON, &newJSON, &entry.RequestID)
+		if err != nil {
+			return []model.AuditEntry{}, err
+		}
+		if len(oldJSON) > 0 {
+			entry.Old = []byte(oldJSON)
+		}
+		if len(newJSON) > 0 {
+			entry.New = []byte(newJSON)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []model.AuditEntry{}, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SchemaVersion возвращает версию схемы базы данных, с которой работает приложение
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// Snapshot сохраняет согласованную копию базы данных в новый файл path.
+// VACUUM INTO можно выполнять во время работы сервера, файл path не должен существовать
+func Snapshot(path string) error {
+	_, err := db.Exec("VACUUM INTO :path", sql.Named("path", path))
+	return err
+}
+
+// VerifySnapshot проверяет целостность копии базы данных в файле path и совместимость
+// её схемы с приложением. Возвращает версию схемы копии
+func VerifySnapshot(path string) (int, error) {
+	snapshot, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer snapshot.Close()
+
+	var check string
+	if err := snapshot.QueryRow("PRAGMA integrity_check").Scan(&check); err != nil {
+		return 0, fmt.Errorf("not a valid database: %w", err)
+	}
+	if check != "ok" {
+		return 0, fmt.Errorf("integrity check failed: %s", check)
+	}
+
+	var tables int
+	err = snapshot.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'scheduler'").Scan(&tables)
+	if err != nil {
+		return 0, err
+	}
+	if tables == 0 {
+		return 0, errors.New("table scheduler not found")
+	}
+
+	var version int
+	if err := snapshot.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	if version > SchemaVersion() {
+		return version, fmt.Errorf("schema version %d is newer than supported %d", version, SchemaVersion())
+	}
+
+	return version, nil
+}
+
+// MigrateSnapshot применяет недостающие миграции к копии базы данных в файле path, не затрагивая
+// текущую базу. Копию следует заранее проверить через VerifySnapshot
+func MigrateSnapshot(path string) error {
+	snapshot, err := sqlx.Open("sqlite3", "file:"+path+"?_txlock=immediate")
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	if err := createTable(snapshot); err != nil {
+		return err
+	}
+	return migrate(snapshot)
+}
+
+// Restore заменяет содержимое базы данных копией из файла path с помощью SQLite backup API.
+// Схема копии должна совпадать с текущей, поэтому старые копии сначала обновляются через MigrateSnapshot.
+// Отозванные токены текущей базы переносятся в копию, чтобы после восстановления они не стали
+// снова действительными
+func Restore(path string) error {
+	ctx := context.Background()
+
+	snapshot, err := sqlx.Open("sqlite3", "file:"+path+"?_txlock=immediate")
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	var version int
+	if err := snapshot.Get(&version, "PRAGMA user_version"); err != nil {
+		return err
+	}
+	if version != SchemaVersion() {
+		return fmt.Errorf("schema version %d does not match current %d", version, SchemaVersion())
+	}
+	if err := copyRevokedTokens(snapshot); err != nil {
+		return fmt.Errorf("copy revoked tokens: %w", err)
+	}
+
+	srcConn, err := snapshot.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	dstConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(dst any) error {
+		return srcConn.Raw(func(src any) error {
+			backup, err := dst.(*sqlite3.SQLiteConn).Backup("main", src.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
+
+// copyRevokedTokens добавляет в копию базы данных snapshot отозванные токены текущей базы
+func copyRevokedTokens(snapshot *sqlx.DB) error {
+	var tokens []struct {
+		JTI       string `db:"jti"`
+		ExpiresAt string `db:"expires_at"`
+	}
+	if err := db.Select(&tokens, "SELECT jti, expires_at FROM revoked_tokens"); err != nil {
+		return err
+	}
+
+	tx, err := snapshot.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, token := range tokens {
+		_, err := tx.Exec("INSERT OR IGNORE INTO revoked_tokens (jti, expires_at) VALUES (:jti, :expires_at)",
+			sql.Named("jti", token.JTI),
+			sql.Named("expires_at", token.ExpiresAt))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// ReadCalDAVResources возвращает ресурсы задач пользователя, созданных клиентами CalDAV, по ID задачи
+func ReadCalDAVResources(userID string) (map[string]model.CalDAVResource, error) {
+	var resources []model.CalDAVResource
+	err := db.Select(&resources, "SELECT task_id, name, uid FROM caldav_resources WHERE task_id IN ("+userTasks+")",
+		sql.Named("user_id", userID))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]model.CalDAVResource, len(resources))
+	for _, resource := range resources {
+		result[resource.TaskID] = resource
+	}
+	return result, nil
+}
+
+// FindCalDAVResource ищет ресурс CalDAV задачи пользователя по имени
+func FindCalDAVResource(userID string, name string) (model.CalDAVResource, error) {
+	var resource model.CalDAVResource
+	err := db.Get(&resource, "SELECT task_id, name, uid FROM caldav_resources WHERE name = :name AND task_id IN ("+userTasks+")",
+		sql.Named("name", name),
+		sql.Named("user_id", userID))
+	return resource, err
+}
+
+// InsertCalDAVResource сохраняет имя ресурса и UID задачи, созданной клиентом CalDAV
+func InsertCalDAVResource(resource model.CalDAVResource) error {
+	_, err := db.Exec("INSERT INTO caldav_resources (task_id, name, uid) VALUES (:task_id, :name, :uid)",
+		sql.Named("task_id", resource.TaskID),
+		sql.Named("name", resource.Name),
+		sql.Named("uid", resource.UID))
+	return err
+}
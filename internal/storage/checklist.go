@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Zelvalna/go_final_project/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrChecklistOrder возвращается, если новый порядок не содержит ровно все пункты чек-листа
+var ErrChecklistOrder = errors.New("order must list every checklist item exactly once")
+
+const checklistColumns = "id, task_id, text, position, checked"
+
+// checkActiveTask проверяет, что задача пользователя или открытая ему для изменения задача существует,
+// не выполнена и не находится в корзине. Для задачи, открытой только для просмотра, возвращается ErrForbidden
+func checkActiveTask(tx *sqlx.Tx, userID string, taskID string) error {
+	if _, err := taskOwner(tx, userID, taskID, true); err != nil {
+		return err
+	}
+	var exists bool
+	err := tx.Get(&exists, "SELECT COUNT(*) > 0 FROM scheduler WHERE id = :id AND deleted_at = '' AND done = 0",
+		sql.Named("id", taskID))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReadChecklist читает чек-лист задачи пользователя или открытой ему задачи по порядку пунктов
+func ReadChecklist(userID string, taskID string) ([]model.ChecklistItem, error) {
+	items := []model.ChecklistItem{}
+	err := db.Select(&items, "SELECT "+checklistColumns+" FROM checklist WHERE task_id = :task_id AND task_id IN ("+visibleTasks+") ORDER BY position, id",
+		sql.Named("task_id", taskID),
+		sql.Named("user_id", userID))
+	return items, err
+}
+
+// InsertChecklistItem добавляет пункт в конец чек-листа активной задачи, которую пользователь может изменять
+func InsertChecklistItem(userID string, item model.ChecklistItem) (model.ChecklistItem, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.ChecklistItem{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkActiveTask(tx, userID, item.TaskID); err != nil {
+		return model.ChecklistItem{}, err
+	}
+	result, err := tx.Exec(`INSERT INTO checklist (task_id, position, text) 
+		VALUES (:task_id, (SELECT COALESCE(MAX(position), 0) + 1 FROM checklist WHERE task_id = :task_id), :text)`,
+		sql.Named("task_id", item.TaskID),
+		sql.Named("text", item.Text))
+	if err != nil {
+		return model.ChecklistItem{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.ChecklistItem{}, err
+	}
+
+	var inserted model.ChecklistItem
+	if err := tx.Get(&inserted, "SELECT "+checklistColumns+" FROM checklist WHERE id = :id", sql.Named("id", id)); err != nil {
+		return model.ChecklistItem{}, err
+	}
+	return inserted, tx.Commit()
+}
+
+// CheckChecklistItem отмечает пункт чек-листа активной задачи, которую пользователь может изменять, или снимает отметку
+func CheckChecklistItem(userID string, id string, checked bool) (model.ChecklistItem, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.ChecklistItem{}, err
+	}
+	defer tx.Rollback()
+
+	var item model.ChecklistItem
+	if err := tx.Get(&item, "SELECT "+checklistColumns+" FROM checklist WHERE id = :id", sql.Named("id", id)); err != nil {
+		return model.ChecklistItem{}, err
+	}
+	if err := checkActiveTask(tx, userID, item.TaskID); err != nil {
+		return model.ChecklistItem{}, err
+	}
+	_, err = tx.Exec("UPDATE checklist SET checked = :checked WHERE id = :id",
+		sql.Named("checked", checked),
+		sql.Named("id", id))
+	if err != nil {
+		return model.ChecklistItem{}, err
+	}
+	item.Checked = checked
+	return item, tx.Commit()
+}
+
+// ReorderChecklist расставляет пункты чек-листа активной задачи, которую пользователь может изменять, в порядке ids
+func ReorderChecklist(userID string, taskID string, ids []string) ([]model.ChecklistItem, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkActiveTask(tx, userID, taskID); err != nil {
+		return nil, err
+	}
+	var current []string
+	if err := tx.Select(&current, "SELECT id FROM checklist WHERE task_id = :task_id", sql.Named("task_id", taskID)); err != nil {
+		return nil, err
+	}
+	if len(ids) != len(current) {
+		return nil, ErrChecklistOrder
+	}
+	remaining := make(map[string]bool, len(current))
+	for _, id := range current {
+		remaining[id] = true
+	}
+	for i, id := range ids {
+		if !remaining[id] {
+			return nil, ErrChecklistOrder
+		}
+		delete(remaining, id)
+		_, err := tx.Exec("UPDATE checklist SET position = :position WHERE id = :id",
+			sql.Named("position", i+1),
+			sql.Named("id", id))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ReadChecklist(userID, taskID)
+}
+
+// DeleteChecklistItem удаляет пункт чек-листа активной задачи, которую пользователь может изменять
+func DeleteChecklistItem(userID string, id string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var item model.ChecklistItem
+	if err := tx.Get(&item, "SELECT "+checklistColumns+" FROM checklist WHERE id = :id", sql.Named("id", id)); err != nil {
+		return err
+	}
+	if err := checkActiveTask(tx, userID, item.TaskID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM checklist WHERE id = :id", sql.Named("id", id)); err != nil {
+		return err
+	}
+	// Следующие пункты сдвигаются, чтобы номера шли подряд
+	_, err = tx.Exec("UPDATE checklist SET position = position - 1 WHERE task_id = :task_id AND position > :position",
+		sql.Named("task_id", item.TaskID),
+		sql.Named("position", item.Position))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// CompleteTask отмечает выполнение задачи пользователя или открытой ему для изменения задачи в одной транзакции: разовая задача переходит
+// в состояние "выполнена", у повторяющейся дата переносится на следующее повторение
+// и сбрасываются отметки чек-листа, а в историю добавляется запись о выполнении.
+// Если version не пустая, задача должна иметь эту версию, иначе возвращается
+// ErrVersionConflict вместе с текущим состоянием задачи. Заблокированная задача
+// выполняется только при force, иначе возвращается ErrTaskBlocked.
+// Возвращает задачу до и после выполнения
+func CompleteTask(userID string, id string, version string, force bool, now time.Time) (model.Task, model.Task, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := taskOwner(tx, userID, id, true); err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	row := tx.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id AND deleted_at = '' AND done = 0",
+		sql.Named("id", id))
+	task, err := scanTask(row)
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	if len(version) > 0 && version != task.Version {
+		return task, model.Task{}, ErrVersionConflict
+	}
+	if task.Blocked && !force {
+		return task, model.Task{}, ErrTaskBlocked
+	}
+
+	updated := task
+	if task.Repeat == "" {
+		updated.Done = true
+	} else {
+		updated.Date, err = dates.GetNextDate(now, task.Date, task.Repeat)
+		if err != nil {
+			return task, model.Task{}, err
+		}
+	}
+
+	// Условие на версию защищает от повторного выполнения той же задачи параллельным запросом
+	result, err := tx.Exec(`UPDATE scheduler SET date = :date, done = :done, version = version + 1 
+		WHERE id = :id AND version = :version AND deleted_at = '' AND done = 0`,
+		sql.Named("date", updated.Date),
+		sql.Named("done", updated.Done),
+		sql.Named("id", task.ID),
+		sql.Named("version", task.Version))
+	if err != nil {
+		return task, model.Task{}, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return task, model.Task{}, err
+	}
+	if rowsAffected == 0 {
+		return task, model.Task{}, ErrVersionConflict
+	}
+
+	// Следующее повторение начинается с неотмеченного чек-листа
+	if len(task.Repeat) > 0 {
+		if _, err := tx.Exec("UPDATE checklist SET checked = 0 WHERE task_id = :id", sql.Named("id", task.ID)); err != nil {
+			return task, model.Task{}, err
+		}
+	}
+
+	_, err = tx.Exec("INSERT INTO completions (task_id, date, completed_at) VALUES (:task_id, :date, :completed_at)",
+		sql.Named("task_id", task.ID),
+		sql.Named("date", task.Date),
+		sql.Named("completed_at", now.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return task, model.Task{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return task, model.Task{}, err
+	}
+
+	row = db.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id", sql.Named("id", id))
+	updated, err = scanTask(row)
+	return task, updated, err
+}
+
+// ReadCompletions читает историю выполнения задачи пользователя или открытой ему задачи, начиная с последних выполнений
+func ReadCompletions(userID string, taskID string) ([]model.Completion, error) {
+	rows, err := db.Query(`SELECT c.id, c.task_id, c.date, c.completed_at, s.title 
+		FROM completions c JOIN scheduler s ON s.id = c.task_id 
+		WHERE c.task_id = :task_id AND c.task_id IN (`+visibleTasks+`) 
+		ORDER BY c.completed_at DESC, c.id DESC`,
+		sql.Named("task_id", taskID),
+		sql.Named("user_id", userID))
+	if err != nil {
+		return []model.Completion{}, err
+	}
+	defer rows.Close()
+
+	return scanCompletions(rows)
+}
+
+// ReadCompletionsInRange читает выполнения задач пользователя и открытых ему задач, отмеченные в промежутке [from, to)
+func ReadCompletionsInRange(userID string, from, to time.Time) ([]model.Completion, error) {
+	rows, err := db.Query(`SELECT c.id, c.task_id, c.date, c.completed_at, s.title 
+		FROM completions c JOIN scheduler s ON s.id = c.task_id 
+		WHERE c.completed_at >= :from AND c.completed_at < :to AND c.task_id IN (`+visibleTasks+`) 
+		ORDER BY c.completed_at, c.id`,
+		sql.Named("from", from.UTC().Format(time.RFC3339)),
+		sql.Named("to", to.UTC().Format(time.RFC3339)),
+		sql.Named("user_id", userID))
+	if err != nil {
+		return []model.Completion{}, err
+	}
+	defer rows.Close()
+
+	return scanCompletions(rows)
+}
+
+// ReadAllCompletions читает историю выполнения всех задач пользователя, сгруппированную по ID задачи
+func ReadAllCompletions(userID string) (map[string][]model.Completion, error) {
+	rows, err := db.Query(`SELECT c.id, c.task_id, c.date, c.completed_at, s.title 
+		FROM completions c JOIN scheduler s ON s.id = c.task_id 
+		WHERE s.user_id = :user_id 
+		ORDER BY c.task_id, c.completed_at, c.id`,
+		sql.Named("user_id", userID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	completions, err := scanCompletions(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byTask := make(map[string][]model.Completion)
+	for _, c := range completions {
+		byTask[c.TaskID] = append(byTask[c.TaskID], c)
+	}
+	return byTask, nil
+}
+
+// ImportCompletions добавляет в историю задачи пользователя выполнения из загруженного файла.
+// Выполнения с датой, которая уже есть в истории задачи, пропускаются
+func ImportCompletions(userID string, taskID string, completions []model.Completion) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := taskOwner(tx, userID, taskID, true); err != nil {
+		return err
+	}
+
+	for _, c := range completions {
+		_, err := tx.Exec(`INSERT INTO completions (task_id, date, completed_at)
+			SELECT :task_id, :date, :completed_at
+			WHERE NOT EXISTS (SELECT 1 FROM completions WHERE task_id = :task_id AND date = :date)`,
+			sql.Named("task_id", taskID),
+			sql.Named("date", c.Date),
+			sql.Named("completed_at", c.CompletedAt))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// scanCompletions считывает выполнения задач из результата запроса
+func scanCompletions(rows *sql.Rows) ([]model.Completion, error) {
+	completions := []model.Completion{}
+
+	for rows.Next() {
+		var c model.Completion
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.Date, &c.CompletedAt, &c.Title); err != nil {
+			return []model.Completion{}, err
+		}
+		completions = append(completions, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []model.Completion{}, err
+	}
+
+	return completions, nil
+}
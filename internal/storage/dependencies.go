@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Zelvalna/go_final_project/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrBlockerNotFound возвращается, если блокирующей задачи нет в базе
+	ErrBlockerNotFound = errors.New("blocking task not found")
+	// ErrDependencyCycle возвращается, если зависимость замыкает цикл задач
+	ErrDependencyCycle = errors.New("dependency creates a cycle")
+	// ErrRepeatingBlocker возвращается, если блокирующая задача повторяется: такая задача
+	// никогда не становится выполненной и блокировала бы зависимые задачи навсегда
+	ErrRepeatingBlocker = errors.New("repeating task cannot block other tasks")
+	// ErrTaskBlocked возвращается при выполнении задачи, которую блокируют невыполненные задачи
+	ErrTaskBlocked = errors.New("task is blocked by unfinished tasks")
+)
+
+// blockersColumn возвращает подзапрос с ID задач, блокирующих задачу с ID idColumn, через запятую
+func blockersColumn(idColumn string) string {
+	return `COALESCE((SELECT group_concat(d.blocker_id) FROM task_deps d WHERE d.task_id = ` + idColumn + `), '')`
+}
+
+// blockedColumn возвращает подзапрос, проверяющий, что у задачи с ID idColumn есть
+// невыполненная блокирующая задача не в корзине
+func blockedColumn(idColumn string) string {
+	return `EXISTS (SELECT 1 FROM task_deps d JOIN scheduler b ON b.id = d.blocker_id
+		WHERE d.task_id = ` + idColumn + ` AND b.deleted_at = '' AND b.done = 0)`
+}
+
+// splitBlockers разбирает ID блокирующих задач, полученные подзапросом blockersColumn
+func splitBlockers(blockers string) []string {
+	if len(blockers) == 0 {
+		return nil
+	}
+	return strings.Split(blockers, ",")
+}
+
+// setTaskBlockers заменяет задачи, блокирующие задачу taskID. Блокирующая задача должна
+// принадлежать тому же пользователю и не повторяться, а зависимость не должна замыкать цикл
+func setTaskBlockers(tx *sqlx.Tx, userID string, taskID int64, blockers []string) error {
+	if _, err := tx.Exec("DELETE FROM task_deps WHERE task_id = :id", sql.Named("id", taskID)); err != nil {
+		return err
+	}
+	for _, blocker := range blockers {
+		var repeat string
+		err := tx.Get(&repeat, "SELECT repeat FROM scheduler WHERE id = :id AND user_id = :user_id",
+			sql.Named("id", blocker),
+			sql.Named("user_id", userID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrBlockerNotFound, blocker)
+		}
+		if err != nil {
+			return err
+		}
+		if len(repeat) > 0 {
+			return fmt.Errorf("%w: %s", ErrRepeatingBlocker, blocker)
+		}
+
+		// Цикл появится, если задача уже достижима из блокирующей по цепочке зависимостей,
+		// в том числе если задача блокирует сама себя
+		var cycle bool
+		err = tx.Get(&cycle, `WITH RECURSIVE chain(id) AS (
+				SELECT CAST(:blocker AS INTEGER)
+				UNION
+				SELECT d.blocker_id FROM task_deps d JOIN chain ON d.task_id = chain.id
+			)
+			SELECT EXISTS (SELECT 1 FROM chain WHERE id = :task)`,
+			sql.Named("blocker", blocker),
+			sql.Named("task", taskID))
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return fmt.Errorf("%w: task %s already depends on task %d", ErrDependencyCycle, blocker, taskID)
+		}
+
+		_, err = tx.Exec("INSERT INTO task_deps (task_id, blocker_id) VALUES (:task_id, :blocker_id)",
+			sql.Named("task_id", taskID),
+			sql.Named("blocker_id", blocker))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRepeatingBlocker проверяет, что задача id, которой задают правило повторения repeat,
+// не блокирует другие задачи
+func checkRepeatingBlocker(tx *sqlx.Tx, id string, repeat string) error {
+	if len(repeat) == 0 {
+		return nil
+	}
+	var blocks bool
+	err := tx.Get(&blocks, "SELECT EXISTS (SELECT 1 FROM task_deps WHERE blocker_id = :id)", sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+	if blocks {
+		return fmt.Errorf("%w: %s", ErrRepeatingBlocker, id)
+	}
+	return nil
+}
+
+// SetTaskBlockers заменяет задачи, блокирующие активную задачу пользователя или открытую ему
+// для изменения задачу id. Возвращает задачу до и после изменения
+func SetTaskBlockers(userID string, id string, blockers []string) (model.Task, model.Task, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	defer tx.Rollback()
+
+	ownerID, err := taskOwner(tx, userID, id, true)
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	oldTask, err := readTask(tx, id)
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	taskID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return model.Task{}, model.Task{}, err
+	}
+	if err := setTaskBlockers(tx, ownerID, taskID, blockers); err != nil {
+		return oldTask, model.Task{}, err
+	}
+	_, err = tx.Exec("UPDATE scheduler SET version = version + 1 WHERE id = :id", sql.Named("id", id))
+	if err != nil {
+		return oldTask, model.Task{}, err
+	}
+
+	task, err := readTask(tx, id)
+	if err != nil {
+		return oldTask, model.Task{}, err
+	}
+	return oldTask, task, tx.Commit()
+}
+
+// graphEdges зависимости между задачами пользователя, которые не находятся в корзине
+const graphEdges = `SELECT d.task_id, d.blocker_id
+	FROM task_deps d
+	JOIN scheduler t ON t.id = d.task_id
+	JOIN scheduler b ON b.id = d.blocker_id
+	WHERE t.user_id = :user_id AND b.user_id = :user_id AND t.deleted_at = '' AND b.deleted_at = ''`
+
+// ReadTaskGraph читает граф зависимостей задач пользователя, которые не находятся в корзине
+func ReadTaskGraph(userID string) (model.TaskGraph, error) {
+	graph := model.TaskGraph{Nodes: []model.GraphNode{}, Edges: []model.GraphEdge{}}
+
+	err := db.Select(&graph.Edges, graphEdges+" ORDER BY d.task_id, d.blocker_id", sql.Named("user_id", userID))
+	if err != nil {
+		return model.TaskGraph{}, err
+	}
+
+	err = db.Select(&graph.Nodes, `WITH edges AS (`+graphEdges+`)
+		SELECT id, date, title, done, `+blockedColumn("scheduler.id")+` AS blocked
+		FROM scheduler
+		WHERE id IN (SELECT task_id FROM edges UNION SELECT blocker_id FROM edges)
+		ORDER BY date, id`,
+		sql.Named("user_id", userID))
+	if err != nil {
+		return model.TaskGraph{}, err
+	}
+
+	return graph, nil
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrProjectNotFound возвращается, если проект задачи не существует
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrProjectArchived возвращается при добавлении задачи в архивный проект
+	ErrProjectArchived = errors.New("project is archived")
+)
+
+// projectColumns столбцы проекта вместе с количеством активных задач в нём
+const projectColumns = `id, name, archived_at,
+	(SELECT COUNT(*) FROM scheduler s WHERE s.project_id = projects.id AND s.deleted_at = '' AND s.done = 0) AS tasks`
+
+// checkTaskProject проверяет, что задачу можно добавить в проект пользователя, и возвращает ID проекта.
+// В архивном проекте может оставаться только задача taskID, которая уже в нём находится
+func checkTaskProject(tx *sqlx.Tx, userID string, projectID string, taskID string) (int64, error) {
+	if len(projectID) == 0 || projectID == "0" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(projectID, 10, 64)
+	if err != nil {
+		return 0, ErrProjectNotFound
+	}
+
+	var archivedAt string
+	err = tx.Get(&archivedAt, "SELECT archived_at FROM projects WHERE id = :id AND user_id = :user_id",
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrProjectNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(archivedAt) > 0 {
+		var current int64
+		err := tx.Get(&current, "SELECT project_id FROM scheduler WHERE id = :id", sql.Named("id", taskID))
+		if err != nil || current != id {
+			return 0, ErrProjectArchived
+		}
+	}
+	return id, nil
+}
+
+// ReadProjects читает проекты пользователя в порядке названий. Архивные проекты возвращаются,
+// только если archived равен true
+func ReadProjects(userID string, archived bool) ([]model.Project, error) {
+	query := "SELECT " + projectColumns + " FROM projects WHERE user_id = :user_id"
+	if !archived {
+		query += " AND archived_at = ''"
+	}
+	projects := []model.Project{}
+	err := db.Select(&projects, query+" ORDER BY name COLLATE NOCASE, id", sql.Named("user_id", userID))
+	return projects, err
+}
+
+// FindProject читает проект пользователя или открытый ему проект по ID
+func FindProject(userID string, id string) (model.Project, error) {
+	var project model.Project
+	err := db.Get(&project, "SELECT "+projectColumns+` FROM projects WHERE id = :id
+		AND (user_id = :user_id OR id IN (SELECT project_id FROM shares WHERE user_id = :user_id))`,
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	return project, err
+}
+
+// InsertProject добавляет проект пользователя и возвращает его ID
+func InsertProject(userID string, project model.Project) (int, error) {
+	result, err := db.Exec("INSERT INTO projects (name, user_id) VALUES (:name, :user_id)",
+		sql.Named("name", project.Name),
+		sql.Named("user_id", userID))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// FindOrCreateProject возвращает ID активного проекта пользователя с названием name без учёта
+// регистра, а если такого проекта нет, создаёт его
+func FindOrCreateProject(userID string, name string) (string, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.Get(&id, `SELECT id FROM projects
+		WHERE user_id = :user_id AND archived_at = '' AND name = :name COLLATE NOCASE
+		ORDER BY id LIMIT 1`,
+		sql.Named("user_id", userID),
+		sql.Named("name", name))
+	if errors.Is(err, sql.ErrNoRows) {
+		var result sql.Result
+		result, err = tx.Exec("INSERT INTO projects (name, user_id) VALUES (:name, :user_id)",
+			sql.Named("name", name),
+			sql.Named("user_id", userID))
+		if err != nil {
+			return "", err
+		}
+		id, err = result.LastInsertId()
+	}
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), tx.Commit()
+}
+
+// UpdateProject переименовывает проект пользователя или открытый ему для изменения проект
+func UpdateProject(userID string, project model.Project) (model.Project, error) {
+	if _, err := projectOwner(db, userID, project.ID, true); err != nil {
+		return model.Project{}, err
+	}
+	result, err := db.Exec("UPDATE projects SET name = :name WHERE id = :id",
+		sql.Named("name", project.Name),
+		sql.Named("id", project.ID))
+	if err != nil {
+		return model.Project{}, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return model.Project{}, err
+	}
+	if rowsAffected == 0 {
+		return model.Project{}, sql.ErrNoRows
+	}
+
+	return FindProject(userID, project.ID)
+}
+
+// ArchiveProject архивирует проект пользователя или открытый ему для изменения проект либо
+// возвращает его из архива. Задачи архивного проекта не показываются в общем списке задач
+func ArchiveProject(userID string, id string, archived bool, now time.Time) (model.Project, error) {
+	if _, err := projectOwner(db, userID, id, true); err != nil {
+		return model.Project{}, err
+	}
+	archivedAt := ""
+	if archived {
+		archivedAt = now.UTC().Format(time.RFC3339)
+	}
+	// Время архивации не меняется при повторной архивации
+	result, err := db.Exec(`UPDATE projects 
+		SET archived_at = CASE WHEN :archived_at != '' AND archived_at != '' THEN archived_at ELSE :archived_at END 
+		WHERE id = :id`,
+		sql.Named("archived_at", archivedAt),
+		sql.Named("id", id))
+	if err != nil {
+		return model.Project{}, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return model.Project{}, err
+	}
+	if rowsAffected == 0 {
+		return model.Project{}, sql.ErrNoRows
+	}
+
+	return FindProject(userID, id)
+}
+
+// DeleteProject удаляет проект пользователя вместе с доступами к нему. Удалить проект может
+// только владелец, для открытого пользователю проекта возвращается ErrForbidden. В режиме model.ProjectDeleteMove задачи проекта переносятся
+// в проект target (пустой - без проекта), а в режиме model.ProjectDeleteCascade перемещаются
+// в корзину. Возвращает задачи проекта до и после изменения
+func DeleteProject(userID string, id string, mode string, target string, now time.Time) ([]model.Task, []model.Task, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	owner, err := projectOwner(tx, userID, id, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if owner != userID {
+		return nil, nil, ErrForbidden
+	}
+
+	var targetID int64
+	if mode == model.ProjectDeleteMove {
+		if target == id {
+			return nil, nil, errors.New("cannot move tasks to the deleted project")
+		}
+		if targetID, err = checkTaskProject(tx, userID, target, ""); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Возвращаются только задачи вне корзины, задачи из корзины лишь отвязываются от проекта
+	rows, err := tx.Query("SELECT "+taskColumns+" FROM scheduler WHERE project_id = :id AND deleted_at = '' ORDER BY id",
+		sql.Named("id", id))
+	if err != nil {
+		return nil, nil, err
+	}
+	oldTasks, err := scanTasks(rows)
+	rows.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch mode {
+	case model.ProjectDeleteMove:
+		_, err = tx.Exec("UPDATE scheduler SET project_id = :target, version = version + 1 WHERE project_id = :id AND deleted_at = ''",
+			sql.Named("target", targetID),
+			sql.Named("id", id))
+		if err == nil {
+			// Задачи в корзине переносятся без изменения версии, чтобы после восстановления
+			// они не ссылались на удалённый проект
+			_, err = tx.Exec("UPDATE scheduler SET project_id = :target WHERE project_id = :id",
+				sql.Named("target", targetID),
+				sql.Named("id", id))
+		}
+	case model.ProjectDeleteCascade:
+		_, err = tx.Exec(`UPDATE scheduler SET deleted_at = :deleted_at, project_id = 0, version = version + 1 
+			WHERE project_id = :id AND deleted_at = ''`,
+			sql.Named("deleted_at", now.UTC().Format(time.RFC3339)),
+			sql.Named("id", id))
+		if err == nil {
+			_, err = tx.Exec("UPDATE scheduler SET project_id = 0 WHERE project_id = :id", sql.Named("id", id))
+		}
+	default:
+		return nil, nil, errors.New("unknown delete mode")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM projects WHERE id = :id", sql.Named("id", id)); err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM shares WHERE project_id = :id", sql.Named("id", id)); err != nil {
+		return nil, nil, err
+	}
+
+	newTasks := make([]model.Task, 0, len(oldTasks))
+	for _, task := range oldTasks {
+		row := tx.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id", sql.Named("id", task.ID))
+		updated, err := scanTask(row)
+		if err != nil {
+			return nil, nil, err
+		}
+		newTasks = append(newTasks, updated)
+	}
+
+	return oldTasks, newTasks, tx.Commit()
+}
@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrForbidden возвращается, если у пользователя нет прав на действие с задачей или проектом,
+	// которые ему открыты: задача открыта только для просмотра или действие доступно только владельцу
+	ErrForbidden = errors.New("permission denied")
+	// ErrUserNotFound возвращается, если пользователя, которому открывается доступ, нет
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// sharedTasks возвращает подзапрос с ID задач, открытых пользователю :user_id напрямую или через
+// проект. Если edit равен true, возвращаются только задачи, открытые для изменения
+func sharedTasks(edit bool) string {
+	permission := ""
+	if edit {
+		permission = " AND sh.permission = '" + model.PermissionEdit + "'"
+	}
+	return `SELECT sh.task_id FROM shares sh WHERE sh.user_id = :user_id AND sh.task_id != 0` + permission + `
+		UNION SELECT s.id FROM scheduler s JOIN shares sh ON sh.project_id = s.project_id
+		WHERE sh.user_id = :user_id AND sh.project_id != 0` + permission
+}
+
+// accessibleTasks возвращает подзапрос с ID задач других пользователей, открытых пользователю :user_id
+// или назначенных ему. Если edit равен true, возвращаются только задачи, которые он может изменять
+func accessibleTasks(edit bool) string {
+	return sharedTasks(edit) + " UNION " + assignedTasks(edit)
+}
+
+// visibleTasks подзапрос с ID задач, которые принадлежат пользователю :user_id, открыты или назначены ему
+var visibleTasks = userTasks + " UNION " + accessibleTasks(false)
+
+// access владелец задачи или проекта и права на неё пользователя, который к ней обращается
+type access struct {
+	Owner    string `db:"user_id"`
+	Visible  bool   `db:"visible"`
+	Editable bool   `db:"editable"`
+}
+
+// check проверяет права на доступ и возвращает ID владельца. Если задача или проект не
+// принадлежат пользователю и не открыты ему, возвращается sql.ErrNoRows, а если для изменения
+// edit они открыты только для просмотра - ErrForbidden
+func (a access) check(edit bool) (string, error) {
+	if !a.Visible {
+		return "", sql.ErrNoRows
+	}
+	if edit && !a.Editable {
+		return "", ErrForbidden
+	}
+	return a.Owner, nil
+}
+
+// taskOwner проверяет права пользователя на задачу id и возвращает ID её владельца
+func taskOwner(q sqlx.Queryer, userID string, id string, edit bool) (string, error) {
+	var a access
+	err := sqlx.Get(q, &a, `SELECT user_id,
+			user_id = :user_id OR id IN (`+accessibleTasks(false)+`) AS visible,
+			user_id = :user_id OR id IN (`+accessibleTasks(true)+`) AS editable
+		FROM scheduler WHERE id = :id`,
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	if err != nil {
+		return "", err
+	}
+	return a.check(edit)
+}
+
+// projectOwner проверяет права пользователя на проект id и возвращает ID его владельца
+func projectOwner(q sqlx.Queryer, userID string, id string, edit bool) (string, error) {
+	var a access
+	err := sqlx.Get(q, &a, `SELECT user_id,
+			user_id = :user_id OR EXISTS (SELECT 1 FROM shares WHERE project_id = projects.id AND user_id = :user_id) AS visible,
+			user_id = :user_id OR EXISTS (SELECT 1 FROM shares WHERE project_id = projects.id AND user_id = :user_id
+				AND permission = :edit) AS editable
+		FROM projects WHERE id = :id`,
+		sql.Named("id", id),
+		sql.Named("user_id", userID),
+		sql.Named("edit", model.PermissionEdit))
+	if err != nil {
+		return "", err
+	}
+	return a.check(edit)
+}
+
+// shareOwner проверяет, что задача или проект доступа принадлежат пользователю: открывать и
+// просматривать доступ может только владелец
+func shareOwner(q sqlx.Queryer, userID string, share model.Share) error {
+	var (
+		owner string
+		err   error
+	)
+	if len(share.TaskID) > 0 {
+		owner, err = taskOwner(q, userID, share.TaskID, false)
+	} else {
+		owner, err = projectOwner(q, userID, share.ProjectID, false)
+	}
+	if err != nil {
+		return err
+	}
+	if owner != userID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// shareTarget возвращает аргументы запроса с ID задачи и проекта доступа, отсутствующий ID равен 0
+func shareTarget(share model.Share) []any {
+	taskID, projectID := share.TaskID, share.ProjectID
+	if len(taskID) == 0 {
+		taskID = "0"
+	}
+	if len(projectID) == 0 {
+		projectID = "0"
+	}
+	return []any{sql.Named("task_id", taskID), sql.Named("project_id", projectID)}
+}
+
+// shareColumns столбцы доступа вместе с именем пользователя, которому он открыт
+const shareColumns = `shares.id, COALESCE(NULLIF(shares.task_id, 0), '') AS task_id,
+	COALESCE(NULLIF(shares.project_id, 0), '') AS project_id, users.name AS user, shares.permission, shares.created_at`
+
+// InsertShare открывает задачу или проект пользователя другому пользователю share.User с правами
+// share.Permission. Повторный доступ тому же пользователю заменяет права. Возвращает ID доступа
+func InsertShare(userID string, share model.Share, now time.Time) (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := shareOwner(tx, userID, share); err != nil {
+		return 0, err
+	}
+	var grantee string
+	err = tx.Get(&grantee, "SELECT id FROM users WHERE key = :key", sql.Named("key", userKey(share.User)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if grantee == userID {
+		return 0, errors.New("cannot share with yourself")
+	}
+
+	args := append(shareTarget(share),
+		sql.Named("user_id", grantee),
+		sql.Named("permission", share.Permission),
+		sql.Named("created_at", now.UTC().Format(time.RFC3339)))
+	_, err = tx.Exec(`INSERT INTO shares (task_id, project_id, user_id, permission, created_at)
+		VALUES (:task_id, :project_id, :user_id, :permission, :created_at)
+		ON CONFLICT (task_id, project_id, user_id) DO UPDATE SET permission = excluded.permission`, args...)
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = tx.Get(&id, `SELECT id FROM shares
+		WHERE task_id = :task_id AND project_id = :project_id AND user_id = :user_id`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// ReadShares читает доступы к задаче или проекту пользователя
+func ReadShares(userID string, share model.Share) ([]model.Share, error) {
+	if err := shareOwner(db, userID, share); err != nil {
+		return nil, err
+	}
+	shares := []model.Share{}
+	err := db.Select(&shares, "SELECT "+shareColumns+` FROM shares JOIN users ON users.id = shares.user_id
+		WHERE shares.task_id = :task_id AND shares.project_id = :project_id
+		ORDER BY users.key`, shareTarget(share)...)
+	return shares, err
+}
+
+// DeleteShare закрывает доступ. Закрыть доступ может владелец задачи или проекта либо
+// пользователь, которому доступ был открыт
+func DeleteShare(userID string, id string) error {
+	result, err := db.Exec(`DELETE FROM shares WHERE id = :id AND (user_id = :user_id
+			OR task_id IN (`+userTasks+`)
+			OR project_id IN (SELECT id FROM projects WHERE user_id = :user_id))`,
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// extraScanner считывает после столбцов задачи дополнительные столбцы в extra
+type extraScanner struct {
+	scanner
+	extra []any
+}
+
+func (s extraScanner) Scan(dest ...any) error {
+	return s.scanner.Scan(append(dest, s.extra...)...)
+}
+
+// ReadShared читает активные задачи и проекты других пользователей, открытые пользователю,
+// вместе с именем владельца и правами доступа
+func ReadShared(userID string) (model.Shared, error) {
+	shared := model.Shared{Tasks: []model.SharedTask{}, Projects: []model.SharedProject{}}
+
+	rows, err := db.Query("SELECT "+taskColumns+`,
+			(SELECT name FROM users WHERE users.id = scheduler.user_id),
+			CASE WHEN id IN (`+sharedTasks(true)+`) THEN :edit ELSE :view END
+		FROM scheduler
+		WHERE id IN (`+sharedTasks(false)+`) AND user_id != :user_id AND deleted_at = '' AND done = 0
+		ORDER BY date, id`,
+		sql.Named("user_id", userID),
+		sql.Named("edit", model.PermissionEdit),
+		sql.Named("view", model.PermissionView))
+	if err != nil {
+		return model.Shared{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var task model.SharedTask
+		task.Task, err = scanTask(extraScanner{rows, []any{&task.Owner, &task.Permission}})
+		if err != nil {
+			return model.Shared{}, err
+		}
+		shared.Tasks = append(shared.Tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return model.Shared{}, err
+	}
+
+	err = db.Select(&shared.Projects, "SELECT "+projectColumns+`,
+			(SELECT name FROM users WHERE users.id = projects.user_id) AS owner,
+			CASE WHEN EXISTS (SELECT 1 FROM shares WHERE project_id = projects.id AND user_id = :user_id AND permission = :edit)
+				THEN :edit ELSE :view END AS permission
+		FROM projects
+		WHERE id IN (SELECT project_id FROM shares WHERE user_id = :user_id AND project_id != 0) AND user_id != :user_id
+		ORDER BY name COLLATE NOCASE, id`,
+		sql.Named("user_id", userID),
+		sql.Named("edit", model.PermissionEdit),
+		sql.Named("view", model.PermissionView))
+	if err != nil {
+		return model.Shared{}, err
+	}
+
+	return shared, nil
+}
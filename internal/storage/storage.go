@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/Zelvalna/go_final_project/model"
 	"github.com/jmoiron/sqlx"
@@ -14,15 +16,20 @@ import (
 
 var db *sqlx.DB
 
-// InitDB инициализирует соединение с базой данных и создает таблицу, если она не существует
-func InitDB() (*sqlx.DB, error) {
-	dbFile := "./scheduler.db"
+// ErrVersionConflict возвращается, если задача была изменена после того, как клиент её прочитал
+var ErrVersionConflict = errors.New("task was modified by another request")
 
-	// Получаем путь к базе данных из переменной окружения
-	dbPath := os.Getenv("TODO_DBFILE")
-	if len(dbPath) > 0 {
-		dbFile = dbPath
+// dbFilePath возвращает путь к файлу базы данных из переменной окружения TODO_DBFILE
+func dbFilePath() string {
+	if dbPath := os.Getenv("TODO_DBFILE"); len(dbPath) > 0 {
+		return dbPath
 	}
+	return "./scheduler.db"
+}
+
+// InitDB инициализирует соединение с базой данных и создает таблицу, если она не существует
+func InitDB() (*sqlx.DB, error) {
+	dbFile := dbFilePath()
 	// Вывод пути к базе данных для проверки
 	log.Println("Путь к базе данных:", dbFile)
 
@@ -37,8 +44,9 @@ func InitDB() (*sqlx.DB, error) {
 		file.Close()
 	}
 
-	// Открываем соединение с базой данных
-	db, err = sqlx.Open("sqlite3", dbFile)
+	// Открываем соединение с базой данных. Транзакции сразу захватывают блокировку на запись,
+	// чтобы параллельные операции "прочитать-изменить-записать" выполнялись по очереди
+	db, err = sqlx.Open("sqlite3", dbFile+"?_txlock=immediate&_busy_timeout=5000")
 	if err != nil {
 		log.Fatalf("Error opening database: %v", err)
 		return nil, err
@@ -50,6 +58,43 @@ func InitDB() (*sqlx.DB, error) {
 		return nil, err
 	}
 
+	// Применяем изменения схемы
+	err = migrate(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// OpenDB открывает существующую базу данных, не создавая её и не применяя миграции. Это нужно
+// утилитам, которые работают с базой сервера: база по неверному пути не должна создаваться пустой.
+// Схема базы должна совпадать с текущей, с readOnly база открывается только для чтения
+func OpenDB(readOnly bool) (*sqlx.DB, error) {
+	dbFile := dbFilePath()
+	if _, err := os.Stat(dbFile); err != nil {
+		return nil, fmt.Errorf("database file %s: %w", dbFile, err)
+	}
+
+	mode := "rw"
+	if readOnly {
+		mode = "ro"
+	}
+	conn, err := sqlx.Open("sqlite3", "file:"+dbFile+"?mode="+mode+"&_txlock=immediate&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	var version int
+	if err := conn.Get(&version, "PRAGMA user_version"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if version != SchemaVersion() {
+		conn.Close()
+		return nil, fmt.Errorf("database %s has schema version %d, expected %d", dbFile, version, SchemaVersion())
+	}
+
+	db = conn
 	return db, nil
 }
 
@@ -69,18 +114,290 @@ func createTable(db *sqlx.DB) error {
 	return err
 }
 
-// InsertTask добавляет новую задачу в базу данных
-func InsertTask(task model.Task) (int, error) {
+// migrations содержит изменения схемы, которые применяются по порядку.
+// Номер последней применённой миграции хранится в PRAGMA user_version
+var migrations = []string{
+	// 1: мягкое удаление задач в корзину
+	`ALTER TABLE scheduler ADD COLUMN deleted_at TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS idx_deleted_at ON scheduler(deleted_at);`,
+	// 2: выполненные задачи и история выполнения
+	`ALTER TABLE scheduler ADD COLUMN done INTEGER NOT NULL DEFAULT 0;
+	CREATE TABLE IF NOT EXISTS completions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		date TEXT NOT NULL,
+		completed_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_completions_task_id ON completions(task_id);
+	CREATE INDEX IF NOT EXISTS idx_completions_completed_at ON completions(completed_at);`,
+	// 3: журнал аудита изменений задач, доступный только для добавления записей
+	`CREATE TABLE IF NOT EXISTS audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		old_json TEXT NOT NULL DEFAULT '',
+		new_json TEXT NOT NULL DEFAULT '',
+		request_id TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_task_id ON audit(task_id);
+	CREATE INDEX IF NOT EXISTS idx_audit_created_at ON audit(created_at);
+	CREATE TRIGGER IF NOT EXISTS audit_no_update BEFORE UPDATE ON audit
+	BEGIN
+		SELECT RAISE(ABORT, 'audit log is append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS audit_no_delete BEFORE DELETE ON audit
+	BEGIN
+		SELECT RAISE(ABORT, 'audit log is append-only');
+	END;`,
+	// 4: версия задачи для оптимистичной блокировки
+	`ALTER TABLE scheduler ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+	// 5: имена и UID задач, созданных клиентами CalDAV
+	`CREATE TABLE IF NOT EXISTS caldav_resources (
+		task_id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		uid TEXT NOT NULL DEFAULT ''
+	);`,
+	// 6: журнал изменений задач для синхронизации. Для каждой задачи хранится только
+	// последнее изменение, а seq растёт монотонно благодаря AUTOINCREMENT.
+	// created_seq - номер изменения, которым задача была создана, NULL - это изменение
+	`CREATE TABLE IF NOT EXISTS changes (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL UNIQUE,
+		created_seq INTEGER,
+		changed_at TEXT NOT NULL
+	);
+	INSERT INTO changes (task_id, changed_at)
+		SELECT id, strftime('%Y-%m-%dT%H:%M:%SZ', 'now') FROM scheduler ORDER BY id;
+	CREATE TRIGGER IF NOT EXISTS changes_insert AFTER INSERT ON scheduler
+	BEGIN
+		INSERT OR REPLACE INTO changes (task_id, changed_at)
+			VALUES (NEW.id, strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+	END;
+	CREATE TRIGGER IF NOT EXISTS changes_update AFTER UPDATE ON scheduler
+	BEGIN
+		INSERT OR REPLACE INTO changes (task_id, created_seq, changed_at)
+			VALUES (NEW.id,
+				(SELECT COALESCE(created_seq, seq) FROM changes WHERE task_id = NEW.id),
+				strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+	END;
+	CREATE TRIGGER IF NOT EXISTS changes_delete AFTER DELETE ON scheduler
+	BEGIN
+		INSERT OR REPLACE INTO changes (task_id, created_seq, changed_at)
+			VALUES (OLD.id,
+				(SELECT COALESCE(created_seq, seq) FROM changes WHERE task_id = OLD.id),
+				strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+	END;`,
+	// 7: метки задач. key - название в нижнем регистре, так как NOCASE в SQLite
+	// не учитывает регистр только латинских букв
+	`CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		key TEXT NOT NULL UNIQUE,
+		color TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS task_tags (
+		task_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (task_id, tag_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_task_tags_tag_id ON task_tags(tag_id);`,
+	// 8: приоритет задачи
+	`ALTER TABLE scheduler ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;`,
+	// 9: проекты задач, project_id = 0 - задача не входит в проект
+	`CREATE TABLE IF NOT EXISTS projects (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		archived_at TEXT NOT NULL DEFAULT ''
+	);
+	ALTER TABLE scheduler ADD COLUMN project_id INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS idx_project_id ON scheduler(project_id);`,
+	// 10: чек-листы задач
+	`CREATE TABLE IF NOT EXISTS checklist (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		checked INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_checklist_task_id ON checklist(task_id, position);`,
+	// 11: вложения задач, сами файлы хранятся в каталоге вложений
+	`CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		file TEXT NOT NULL UNIQUE,
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_attachments_task_id ON attachments(task_id);`,
+	// 12: зависимости задач: задача task_id ждёт выполнения задачи blocker_id. Когда блокирующая
+	// задача выполняется, удаляется или восстанавливается, версия зависящих от неё задач
+	// увеличивается, так как меняется их признак blocked
+	`CREATE TABLE IF NOT EXISTS task_deps (
+		task_id INTEGER NOT NULL,
+		blocker_id INTEGER NOT NULL,
+		PRIMARY KEY (task_id, blocker_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_task_deps_blocker_id ON task_deps(blocker_id);
+	CREATE TRIGGER IF NOT EXISTS task_deps_blocker_changed AFTER UPDATE OF done, deleted_at ON scheduler
+	WHEN OLD.done != NEW.done OR OLD.deleted_at != NEW.deleted_at
+	BEGIN
+		UPDATE scheduler SET version = version + 1
+			WHERE id IN (SELECT task_id FROM task_deps WHERE blocker_id = NEW.id);
+	END;`,
+	// 13: пользователи. Задачи, проекты, метки, журнал аудита и журнал изменений принадлежат
+	// пользователю user_id, а всё созданное раньше - администратору. Пароль администратора
+	// задаётся при запуске из TODO_PASSWORD. Метки пересоздаются, чтобы названия были
+	// уникальны только в пределах пользователя
+	`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		key TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL DEFAULT '',
+		admin INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL
+	);
+	INSERT INTO users (id, name, key, admin, created_at)
+		VALUES (1, 'admin', 'admin', 1, strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));
+	ALTER TABLE scheduler ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+	CREATE INDEX IF NOT EXISTS idx_user_id ON scheduler(user_id, date);
+	ALTER TABLE projects ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE audit ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE changes ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+	CREATE INDEX IF NOT EXISTS idx_changes_user_id ON changes(user_id, seq);
+	DROP TRIGGER changes_insert;
+	DROP TRIGGER changes_update;
+	DROP TRIGGER changes_delete;
+	CREATE TRIGGER changes_insert AFTER INSERT ON scheduler
+	BEGIN
+		INSERT OR REPLACE INTO changes (task_id, changed_at, user_id)
+			VALUES (NEW.id, strftime('%Y-%m-%dT%H:%M:%SZ', 'now'), NEW.user_id);
+	END;
+	CREATE TRIGGER changes_update AFTER UPDATE ON scheduler
+	BEGIN
+		INSERT OR REPLACE INTO changes (task_id, created_seq, changed_at, user_id)
+			VALUES (NEW.id,
+				(SELECT COALESCE(created_seq, seq) FROM changes WHERE task_id = NEW.id),
+				strftime('%Y-%m-%dT%H:%M:%SZ', 'now'), NEW.user_id);
+	END;
+	CREATE TRIGGER changes_delete AFTER DELETE ON scheduler
+	BEGIN
+		INSERT OR REPLACE INTO changes (task_id, created_seq, changed_at, user_id)
+			VALUES (OLD.id,
+				(SELECT COALESCE(created_seq, seq) FROM changes WHERE task_id = OLD.id),
+				strftime('%Y-%m-%dT%H:%M:%SZ', 'now'), OLD.user_id);
+	END;
+	CREATE TABLE user_tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		key TEXT NOT NULL,
+		color TEXT NOT NULL DEFAULT '',
+		UNIQUE (user_id, key)
+	);
+	INSERT INTO user_tags (id, user_id, name, key, color) SELECT id, 1, name, key, color FROM tags;
+	DROP TABLE tags;
+	ALTER TABLE user_tags RENAME TO tags;`,
+	// 14: доступ пользователя user_id к задаче task_id или проекту project_id другого пользователя,
+	// у доступа заполнен только один из этих столбцов
+	`CREATE TABLE IF NOT EXISTS shares (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL DEFAULT 0,
+		project_id INTEGER NOT NULL DEFAULT 0,
+		user_id INTEGER NOT NULL,
+		permission TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		UNIQUE (task_id, project_id, user_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_shares_user_id ON shares(user_id);`,
+	// 15: назначение задачи исполнителю assignee_id, 0 - задача не назначена, и уведомления
+	// пользователей об изменениях назначения
+	`ALTER TABLE scheduler ADD COLUMN assignee_id INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE scheduler ADD COLUMN assignee_status TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS idx_assignee_id ON scheduler(assignee_id);
+	CREATE TABLE IF NOT EXISTS notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		task_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, id);`,
+	// 16: отозванные токены с ID jti. Запись нужна только до окончания действия токена expires_at
+	`CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti TEXT PRIMARY KEY,
+		expires_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);`,
+	// 17: токены подписки на календарь. Хранится только хеш token_hash, у пользователя один токен
+	`CREATE TABLE IF NOT EXISTS feed_tokens (
+		user_id INTEGER PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at TEXT NOT NULL
+	);`,
+}
+
+// migrate применяет к базе данных ещё не применённые миграции
+func migrate(db *sqlx.DB) error {
+	var version int
+	if err := db.Get(&version, "PRAGMA user_version"); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Beginx()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Применена миграция базы данных %d", i+1)
+	}
+
+	return nil
+}
+
+// InsertTask добавляет новую задачу пользователя userID в базу данных
+func InsertTask(userID string, task model.Task) (int, error) {
 	if db == nil {
 		return 0, errors.New("database not initialized")
 	}
-	// Вставляем задачу в таблицу
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	projectID, err := checkTaskProject(tx, userID, task.ProjectID, "")
+	if err != nil {
+		return 0, err
+	}
 
-	result, err := db.Exec("INSERT INTO scheduler (date, title, comment, repeat) VALUES (:date, :title, :comment, :repeat)",
+	// Вставляем задачу в таблицу
+	result, err := tx.Exec(`INSERT INTO scheduler (date, title, comment, repeat, done, priority, project_id, user_id) 
+		VALUES (:date, :title, :comment, :repeat, :done, :priority, :project_id, :user_id)`,
 		sql.Named("date", task.Date),
 		sql.Named("title", task.Title),
 		sql.Named("comment", task.Comment),
-		sql.Named("repeat", task.Repeat))
+		sql.Named("repeat", task.Repeat),
+		sql.Named("done", task.Done),
+		sql.Named("priority", task.Priority),
+		sql.Named("project_id", projectID),
+		sql.Named("user_id", userID))
 	if err != nil {
 		return 0, err
 	}
@@ -90,89 +407,219 @@ func InsertTask(task model.Task) (int, error) {
 		return 0, err
 	}
 
-	return int(id), nil
+	if err := setTaskTags(tx, userID, id, task.Tags); err != nil {
+		return 0, err
+	}
+	if err := setTaskBlockers(tx, userID, id, task.BlockedBy); err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
 }
 
-// ReadTasks читает все задачи из базы данных, ограничивая результат 15 записями
-func ReadTasks() ([]model.Task, error) {
-	var tasks []model.Task
+// TaskFilter дополнительные условия отбора и порядок активных задач пользователя
+type TaskFilter struct {
+	// Tag название метки, пустое - без отбора по метке
+	Tag string
+	// ProjectID ID проекта, "0" - задачи без проекта. Без отбора по проекту
+	// задачи архивных проектов не возвращаются
+	ProjectID string
+	// Order порядок задач: model.OrderDate (по умолчанию) или model.OrderSmart
+	Order string
+	// Today сегодняшняя дата в формате model.DatePat, задачи до неё считаются просроченными
+	Today string
+	// Assigned вместо собственных задач выбирает назначенные пользователю задачи других
+	// пользователей, от которых он не отказался
+	Assigned bool
+}
 
-	rows, err := db.Query("SELECT id, date, title, comment, repeat FROM scheduler ORDER BY date")
-	if err != nil {
-		return []model.Task{}, err
+// owner возвращает условие, которое выбирает задачи пользователя :user_id
+func (f TaskFilter) owner() string {
+	if f.Assigned {
+		return "id IN (" + assignedTasks(false) + ")"
 	}
-	defer rows.Close()
+	return "user_id = :user_id"
+}
 
-	for rows.Next() {
-		var task model.Task
-		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
-			return []model.Task{}, err
-		}
-		tasks = append(tasks, task)
+// where возвращает условия фильтра, которые добавляются к запросу через AND, и их аргументы
+func (f TaskFilter) where() (string, []any) {
+	var (
+		clause string
+		args   []any
+	)
+	if len(f.Tag) > 0 {
+		clause += ` AND id IN (SELECT tt.task_id FROM task_tags tt JOIN tags t ON t.id = tt.tag_id WHERE t.key = :tag)`
+		args = append(args, sql.Named("tag", tagKey(f.Tag)))
 	}
-
-	if err := rows.Err(); err != nil {
-		return []model.Task{}, err
+	if len(f.ProjectID) > 0 {
+		clause += ` AND project_id = :project_id`
+		args = append(args, sql.Named("project_id", f.ProjectID))
+	} else {
+		clause += ` AND project_id NOT IN (SELECT id FROM projects WHERE archived_at != '')`
 	}
+	return clause, args
+}
 
-	if tasks == nil {
-		tasks = []model.Task{}
+// orderBy возвращает выражение сортировки задач и его аргументы
+func (f TaskFilter) orderBy() (string, []any) {
+	if f.Order != model.OrderSmart {
+		return " ORDER BY date", nil
 	}
+	// Задачи без приоритета идут после задач с наименьшим приоритетом
+	return ` ORDER BY date < :today DESC, CASE WHEN priority = 0 THEN :nopriority ELSE priority END, date, id`,
+		[]any{sql.Named("today", f.Today), sql.Named("nopriority", model.MaxPriority+1)}
+}
 
-	return tasks, nil
+// query возвращает условия и сортировку фильтра вместе с аргументами
+func (f TaskFilter) query() (string, string, []any) {
+	where, args := f.where()
+	order, orderArgs := f.orderBy()
+	return where, order, append(args, orderArgs...)
 }
 
-// SearchTasks ищет задачи по заголовку или комментарию
-func SearchTasks(search string) ([]model.Task, error) {
-	var tasks []model.Task
+// ReadTasks читает все активные задачи пользователя, подходящие под фильтр
+func ReadTasks(userID string, filter TaskFilter) ([]model.Task, error) {
+	where, order, args := filter.query()
+	rows, err := db.Query("SELECT "+taskColumns+" FROM scheduler WHERE "+filter.owner()+" AND deleted_at = '' AND done = 0"+where+order,
+		append(args, sql.Named("user_id", userID))...)
+	if err != nil {
+		return []model.Task{}, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
 
-	query := `SELECT id, date, title, comment, repeat 
+// SearchTasks ищет задачи пользователя, подходящие под фильтр, по заголовку или комментарию
+func SearchTasks(userID string, search string, filter TaskFilter) ([]model.Task, error) {
+	where, order, args := filter.query()
+	query := `SELECT ` + taskColumns + ` 
 		FROM scheduler 
-		WHERE title LIKE :search OR comment LIKE :search 
-		ORDER BY date 
+		WHERE (title LIKE :search OR comment LIKE :search) AND ` + filter.owner() + ` AND deleted_at = '' AND done = 0` + where + order + ` 
 		LIMIT 10
 	`
 	search = fmt.Sprintf("%%%s%%", search)
-	rows, err := db.Query(query, sql.Named("search", search))
+	rows, err := db.Query(query, append(args, sql.Named("search", search), sql.Named("user_id", userID))...)
 
 	if err != nil {
 		return []model.Task{}, err
 	}
 	defer rows.Close()
 
-	for rows.Next() {
-		var task model.Task
-		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
-			return []model.Task{}, err
-		}
-		tasks = append(tasks, task)
+	return scanTasks(rows)
+}
+
+// SearchTasksByDate ищет задачи пользователя, подходящие под фильтр, по дате
+func SearchTasksByDate(userID string, date string, filter TaskFilter) ([]model.Task, error) {
+	where, order, args := filter.query()
+	rows, err := db.Query("SELECT "+taskColumns+" FROM scheduler WHERE date = :date AND "+filter.owner()+" AND deleted_at = '' AND done = 0"+where+order+" LIMIT 10",
+		append(args, sql.Named("date", date), sql.Named("user_id", userID))...)
+	if err != nil {
+		return []model.Task{}, err
 	}
+	defer rows.Close()
 
-	if err := rows.Err(); err != nil {
+	return scanTasks(rows)
+}
+
+// ReadTasksInRange читает задачи пользователя с датой в диапазоне [from, to], а также
+// повторяющиеся задачи, которые начинаются не позже конца диапазона
+func ReadTasksInRange(userID string, from, to string) ([]model.Task, error) {
+	query := `SELECT ` + taskColumns + ` 
+		FROM scheduler 
+		WHERE date <= :to AND (date >= :from OR repeat != '') AND user_id = :user_id AND deleted_at = '' AND done = 0 
+		ORDER BY date
+	`
+	rows, err := db.Query(query, sql.Named("from", from), sql.Named("to", to), sql.Named("user_id", userID))
+	if err != nil {
 		return []model.Task{}, err
 	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
 
-	if tasks == nil {
-		tasks = []model.Task{}
+// ReadAllTasks читает все задачи пользователя, кроме находящихся в корзине, включая выполненные
+func ReadAllTasks(userID string) ([]model.Task, error) {
+	rows, err := db.Query("SELECT "+taskColumns+" FROM scheduler WHERE user_id = :user_id AND deleted_at = '' ORDER BY id",
+		sql.Named("user_id", userID))
+	if err != nil {
+		return []model.Task{}, err
 	}
+	defer rows.Close()
 
-	return tasks, nil
+	return scanTasks(rows)
 }
 
-// SearchTasksByDate ищет задачи по дате
-func SearchTasksByDate(date string) ([]model.Task, error) {
-	var tasks []model.Task
+// FindDuplicate ищет задачу пользователя, не находящуюся в корзине, с тем же заголовком и тем же ID
+// либо той же датой и правилом повтора. Совпадение по ID имеет приоритет
+func FindDuplicate(userID string, task model.Task) (model.Task, error) {
+	row := db.QueryRow(`SELECT `+taskColumns+` 
+		FROM scheduler 
+		WHERE user_id = :user_id AND deleted_at = '' AND title = :title AND (id = :id OR (date = :date AND repeat = :repeat)) 
+		ORDER BY id = :id DESC 
+		LIMIT 1`,
+		sql.Named("user_id", userID),
+		sql.Named("id", task.ID),
+		sql.Named("title", task.Title),
+		sql.Named("date", task.Date),
+		sql.Named("repeat", task.Repeat))
+	return scanTask(row)
+}
 
-	rows, err := db.Query("SELECT id, date, title, comment, repeat FROM scheduler WHERE date = :date LIMIT 10",
-		sql.Named("date", date))
+// ReadTrash читает задачи пользователя, находящиеся в корзине, начиная с последних удалённых
+func ReadTrash(userID string) ([]model.Task, error) {
+	rows, err := db.Query("SELECT "+taskColumns+" FROM scheduler WHERE user_id = :user_id AND deleted_at != '' ORDER BY deleted_at DESC",
+		sql.Named("user_id", userID))
 	if err != nil {
 		return []model.Task{}, err
 	}
 	defer rows.Close()
 
+	return scanTasks(rows)
+}
+
+// taskColumns список столбцов задачи в порядке, ожидаемом scanTask
+var taskColumns = "id, date, title, comment, repeat, deleted_at, done, version, priority, " +
+	"COALESCE(NULLIF(project_id, 0), ''), " + tagsColumn("scheduler.id") + ", " +
+	blockersColumn("scheduler.id") + ", " + blockedColumn("scheduler.id") + ", " +
+	assigneeColumn("scheduler.assignee_id") + ", assignee_status"
+
+// userTasks подзапрос с ID задач пользователя :user_id для проверки доступа к связанным с задачей записям
+const userTasks = "SELECT id FROM scheduler WHERE user_id = :user_id"
+
+// tagsColumn возвращает подзапрос с названиями меток задачи с ID idColumn через перевод строки
+func tagsColumn(idColumn string) string {
+	return `COALESCE((SELECT group_concat(t.name, char(10)) FROM task_tags tt JOIN tags t ON t.id = tt.tag_id 
+		WHERE tt.task_id = ` + idColumn + `), '')`
+}
+
+// scanner общий интерфейс для *sql.Row и *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTask считывает задачу из строки результата запроса
+func scanTask(row scanner) (model.Task, error) {
+	var (
+		task     model.Task
+		tags     string
+		blockers string
+	)
+	err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.DeletedAt, &task.Done, &task.Version,
+		&task.Priority, &task.ProjectID, &tags, &blockers, &task.Blocked, &task.Assignee, &task.AssigneeStatus)
+	task.Tags = splitTags(tags)
+	task.BlockedBy = splitBlockers(blockers)
+	return task, err
+}
+
+// scanTasks считывает задачи из результата запроса
+func scanTasks(rows *sql.Rows) ([]model.Task, error) {
+	tasks := []model.Task{}
+
 	for rows.Next() {
-		var task model.Task
-		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
+		task, err := scanTask(rows)
+		if err != nil {
 			return []model.Task{}, err
 		}
 		tasks = append(tasks, task)
@@ -182,35 +629,76 @@ func SearchTasksByDate(date string) ([]model.Task, error) {
 		return []model.Task{}, err
 	}
 
-	if tasks == nil {
-		tasks = []model.Task{}
-	}
-
 	return tasks, nil
 }
 
-// ReadTaskById читает задачу по ID
-func GetTaskById(id string) (model.Task, error) {
-	var task model.Task
-
-	row := db.QueryRow("SELECT id, date, title, comment, repeat FROM scheduler WHERE id = :id",
-		sql.Named("id", id))
-	if err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
+// ReadTaskById читает активную задачу пользователя или открытую ему задачу по ID
+func GetTaskById(userID string, id string) (model.Task, error) {
+	row := db.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id AND id IN ("+visibleTasks+") AND deleted_at = '' AND done = 0",
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	task, err := scanTask(row)
+	if err != nil {
 		return model.Task{}, err
 	}
 
 	return task, nil
 }
 
-// UpdateTask обновляет задачу по ID
-func UpdateTask(task model.Task) (model.Task, error) {
+// FindTask читает задачу пользователя или открытую ему задачу по ID независимо от того,
+// выполнена ли она или находится в корзине
+func FindTask(userID string, id string) (model.Task, error) {
+	row := db.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id AND id IN ("+visibleTasks+")",
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	return scanTask(row)
+}
+
+// UpdateTask обновляет задачу пользователя или открытую ему для изменения задачу по ID и возвращает
+// её с новой версией. Если у задачи указана версия, обновление выполняется только при совпадении версий.
+// Метки и блокирующие задачи заменяются, только если task.Tags и task.BlockedBy не nil, а проект,
+// метки и блокирующие задачи берутся из задач, проектов и меток владельца
+func UpdateTask(userID string, task model.Task) (model.Task, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.Task{}, err
+	}
+	defer tx.Rollback()
+
+	ownerID, err := taskOwner(tx, userID, task.ID, true)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Task{}, errors.New("failed to update")
+	}
+	if err != nil {
+		return model.Task{}, err
+	}
+	projectID, err := checkTaskProject(tx, ownerID, task.ProjectID, task.ID)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if err := checkRepeatingBlocker(tx, task.ID, task.Repeat); err != nil {
+		return model.Task{}, err
+	}
 
-	result, err := db.Exec("UPDATE scheduler SET date = :date, title = :title, comment = :comment, repeat = :repeat WHERE id = :id",
+	query := `UPDATE scheduler 
+		SET date = :date, title = :title, comment = :comment, repeat = :repeat, priority = :priority, 
+			project_id = :project_id, version = version + 1 
+		WHERE id = :id AND deleted_at = '' AND done = 0`
+	args := []any{
 		sql.Named("date", task.Date),
 		sql.Named("title", task.Title),
 		sql.Named("comment", task.Comment),
 		sql.Named("repeat", task.Repeat),
-		sql.Named("id", task.ID))
+		sql.Named("priority", task.Priority),
+		sql.Named("project_id", projectID),
+		sql.Named("id", task.ID),
+	}
+	if len(task.Version) > 0 {
+		query += " AND version = :version"
+		args = append(args, sql.Named("version", task.Version))
+	}
+
+	result, err := tx.Exec(query, args...)
 	if err != nil {
 		return model.Task{}, err
 	}
@@ -221,15 +709,55 @@ func UpdateTask(task model.Task) (model.Task, error) {
 	}
 
 	if rowsAffected == 0 {
+		tx.Rollback()
+		// Задача существует, значит не совпала версия
+		if _, err := GetTaskById(userID, task.ID); err == nil && len(task.Version) > 0 {
+			return model.Task{}, ErrVersionConflict
+		}
 		return model.Task{}, errors.New("failed to update")
 	}
 
-	return task, nil
+	id, err := strconv.ParseInt(task.ID, 10, 64)
+	if err != nil {
+		return model.Task{}, err
+	}
+	if task.Tags != nil {
+		if _, err := tx.Exec("DELETE FROM task_tags WHERE task_id = :id", sql.Named("id", id)); err != nil {
+			return model.Task{}, err
+		}
+		if err := setTaskTags(tx, ownerID, id, task.Tags); err != nil {
+			return model.Task{}, err
+		}
+	}
+	if task.BlockedBy != nil {
+		if err := setTaskBlockers(tx, ownerID, id, task.BlockedBy); err != nil {
+			return model.Task{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Task{}, err
+	}
+
+	return GetTaskById(userID, task.ID)
 }
 
-// DeleteTask удаляет задачу по ID
-func DeleteTask(id string) error {
-	result, err := db.Exec("DELETE FROM scheduler WHERE id = :id",
+// DeleteTask перемещает задачу пользователя или открытую ему для изменения задачу в корзину владельца по ID
+func DeleteTask(userID string, id string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Права проверяются в той же транзакции, чтобы доступ не отозвали между проверкой и удалением
+	if _, err := taskOwner(tx, userID, id, true); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("failed to delete")
+		}
+		return err
+	}
+	result, err := tx.Exec("UPDATE scheduler SET deleted_at = :deleted_at, version = version + 1 WHERE id = :id AND deleted_at = ''",
+		sql.Named("deleted_at", time.Now().UTC().Format(time.RFC3339)),
 		sql.Named("id", id))
 	if err != nil {
 		return err
@@ -244,5 +772,96 @@ func DeleteTask(id string) error {
 		return errors.New("failed to delete")
 	}
 
-	return err
+	return tx.Commit()
+}
+
+// RestoreTask возвращает задачу пользователя из корзины по ID
+func RestoreTask(userID string, id string) error {
+	result, err := db.Exec("UPDATE scheduler SET deleted_at = '', version = version + 1 WHERE id = :id AND user_id = :user_id AND deleted_at != ''",
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("failed to restore")
+	}
+
+	return nil
+}
+
+// PurgeTrash окончательно удаляет задачи, перемещённые в корзину раньше before,
+// вместе с историей их выполнения, ресурсами CalDAV, метками, чек-листами, вложениями, зависимостями и уведомлениями.
+// Возвращает количество удалённых задач и имена файлов их вложений, которые нужно удалить
+func PurgeTrash(before time.Time) (int64, []string, error) {
+	return purgeTasks("deleted_at != '' AND deleted_at < :before",
+		sql.Named("before", before.UTC().Format(time.RFC3339)))
+}
+
+// PurgeTask окончательно удаляет задачу пользователя из корзины так же, как PurgeTrash.
+// Если задачи нет в корзине, возвращается sql.ErrNoRows
+func PurgeTask(userID string, id string) ([]string, error) {
+	purged, files, err := purgeTasks("deleted_at != '' AND id = :id AND user_id = :user_id",
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	if err == nil && purged == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return files, err
+}
+
+// purgedTables таблицы со связанными с задачей записями, которые удаляются вместе с ней
+var purgedTables = []string{"attachments", "completions", "caldav_resources", "task_tags", "checklist", "task_deps", "shares", "notifications"}
+
+// purgeTasks удаляет задачи, подходящие под условие cond, вместе со связанными записями
+func purgeTasks(cond string, args ...any) (int64, []string, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var files []string
+	err = tx.Select(&files, "SELECT file FROM attachments WHERE task_id IN (SELECT id FROM scheduler WHERE "+cond+")", args...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Удалённая задача перестаёт блокировать другие задачи, и их список блокирующих задач меняется
+	purgedIDs := "SELECT id FROM scheduler WHERE " + cond
+	_, err = tx.Exec("UPDATE scheduler SET version = version + 1 WHERE id IN (SELECT task_id FROM task_deps WHERE blocker_id IN ("+purgedIDs+"))", args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM task_deps WHERE blocker_id IN ("+purgedIDs+")", args...); err != nil {
+		return 0, nil, err
+	}
+
+	for _, table := range purgedTables {
+		_, err := tx.Exec("DELETE FROM "+table+" WHERE task_id IN (SELECT id FROM scheduler WHERE "+cond+")", args...)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM scheduler WHERE "+cond, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return purged, files, nil
 }
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// TaskChange последнее изменение задачи из журнала изменений
+type TaskChange struct {
+	Seq int64
+	// Created задача создана после токена, с которым её запросили
+	Created   bool
+	ChangedAt string
+	// Exists задача ещё есть в базе, в том числе в корзине
+	Exists bool
+	Task   model.Task
+}
+
+// ReadChanges читает не более limit последних изменений задач пользователя с номером больше since
+// в порядке возрастания номеров
+func ReadChanges(userID string, since int64, limit int) ([]TaskChange, error) {
+	rows, err := db.Query(`SELECT c.seq, COALESCE(c.created_seq, c.seq) > :since, c.changed_at, s.id IS NOT NULL, 
+			c.task_id, COALESCE(s.date, ''), COALESCE(s.title, ''), COALESCE(s.comment, ''), COALESCE(s.repeat, ''), 
+			COALESCE(s.deleted_at, ''), COALESCE(s.done, 0), COALESCE(s.version, 0), 
+			COALESCE(s.priority, 0), COALESCE(NULLIF(s.project_id, 0), ''), `+tagsColumn("s.id")+`, 
+			`+blockersColumn("s.id")+`, `+blockedColumn("s.id")+`, 
+			`+assigneeColumn("s.assignee_id")+`, COALESCE(s.assignee_status, '') 
+		FROM changes c LEFT JOIN scheduler s ON s.id = c.task_id 
+		WHERE c.user_id = :user_id AND c.seq > :since 
+		ORDER BY c.seq 
+		LIMIT :limit`,
+		sql.Named("user_id", userID),
+		sql.Named("since", since),
+		sql.Named("limit", limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []TaskChange{}
+	for rows.Next() {
+		var (
+			c        TaskChange
+			tags     string
+			blockers string
+		)
+		err := rows.Scan(&c.Seq, &c.Created, &c.ChangedAt, &c.Exists,
+			&c.Task.ID, &c.Task.Date, &c.Task.Title, &c.Task.Comment, &c.Task.Repeat,
+			&c.Task.DeletedAt, &c.Task.Done, &c.Task.Version, &c.Task.Priority, &c.Task.ProjectID, &tags,
+			&blockers, &c.Task.Blocked, &c.Task.Assignee, &c.Task.AssigneeStatus)
+		if err != nil {
+			return nil, err
+		}
+		c.Task.Tags = splitTags(tags)
+		c.Task.BlockedBy = splitBlockers(blockers)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// ChangeToken возвращает номер последнего изменения задач пользователя
+func ChangeToken(userID string) (int64, error) {
+	var token int64
+	err := db.Get(&token, "SELECT COALESCE(MAX(seq), 0) FROM changes WHERE user_id = :user_id", sql.Named("user_id", userID))
+	return token, err
+}
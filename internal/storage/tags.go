@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/Zelvalna/go_final_project/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrTagExists возвращается, если метка с таким названием уже есть
+var ErrTagExists = errors.New("tag with this name already exists")
+
+// tagColumns столбцы метки вместе с количеством активных задач с ней
+const tagColumns = `id, name, color,
+	(SELECT COUNT(*) FROM task_tags tt JOIN scheduler s ON s.id = tt.task_id
+		WHERE tt.tag_id = tags.id AND s.deleted_at = '' AND s.done = 0) AS tasks`
+
+// splitTags разбирает названия меток, полученные подзапросом tagsColumn, и сортирует их
+func splitTags(tags string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	names := strings.Split(tags, "\n")
+	sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+	return names
+}
+
+// tagKey возвращает ключ метки, по которому названия сравниваются без учёта регистра
+func tagKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// setTaskTags отмечает задачу метками пользователя с указанными названиями, создавая недостающие метки
+func setTaskTags(tx *sqlx.Tx, userID string, taskID int64, names []string) error {
+	for _, name := range names {
+		_, err := tx.Exec("INSERT OR IGNORE INTO tags (user_id, name, key) VALUES (:user_id, :name, :key)",
+			sql.Named("user_id", userID),
+			sql.Named("name", name),
+			sql.Named("key", tagKey(name)))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT OR IGNORE INTO task_tags (task_id, tag_id) 
+			SELECT :task_id, id FROM tags WHERE user_id = :user_id AND key = :key`,
+			sql.Named("task_id", taskID),
+			sql.Named("user_id", userID),
+			sql.Named("key", tagKey(name)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchTaggedTasks увеличивает версию задач с меткой, чтобы клиенты синхронизации
+// получили изменённый список меток
+func touchTaggedTasks(tx *sqlx.Tx, tagID string) error {
+	_, err := tx.Exec("UPDATE scheduler SET version = version + 1 WHERE id IN (SELECT task_id FROM task_tags WHERE tag_id = :id)",
+		sql.Named("id", tagID))
+	return err
+}
+
+// isUniqueViolation проверяет, что запись нарушила ограничение уникальности
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// ReadTags читает все метки пользователя в порядке названий
+func ReadTags(userID string) ([]model.Tag, error) {
+	tags := []model.Tag{}
+	err := db.Select(&tags, "SELECT "+tagColumns+" FROM tags WHERE user_id = :user_id ORDER BY key",
+		sql.Named("user_id", userID))
+	return tags, err
+}
+
+// FindTag читает метку пользователя по ID
+func FindTag(userID string, id string) (model.Tag, error) {
+	var tag model.Tag
+	err := db.Get(&tag, "SELECT "+tagColumns+" FROM tags WHERE id = :id AND user_id = :user_id",
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	return tag, err
+}
+
+// InsertTag добавляет метку пользователя и возвращает её ID
+func InsertTag(userID string, tag model.Tag) (int, error) {
+	result, err := db.Exec("INSERT INTO tags (user_id, name, key, color) VALUES (:user_id, :name, :key, :color)",
+		sql.Named("user_id", userID),
+		sql.Named("name", tag.Name),
+		sql.Named("key", tagKey(tag.Name)),
+		sql.Named("color", tag.Color))
+	if isUniqueViolation(err) {
+		return 0, ErrTagExists
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// UpdateTag изменяет название и цвет метки пользователя. При переименовании меняется версия задач с этой меткой
+func UpdateTag(userID string, tag model.Tag) (model.Tag, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return model.Tag{}, err
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	err = tx.Get(&oldName, "SELECT name FROM tags WHERE id = :id AND user_id = :user_id",
+		sql.Named("id", tag.ID),
+		sql.Named("user_id", userID))
+	if err != nil {
+		return model.Tag{}, err
+	}
+	_, err = tx.Exec("UPDATE tags SET name = :name, key = :key, color = :color WHERE id = :id",
+		sql.Named("name", tag.Name),
+		sql.Named("key", tagKey(tag.Name)),
+		sql.Named("color", tag.Color),
+		sql.Named("id", tag.ID))
+	if isUniqueViolation(err) {
+		return model.Tag{}, ErrTagExists
+	}
+	if err != nil {
+		return model.Tag{}, err
+	}
+	if oldName != tag.Name {
+		if err := touchTaggedTasks(tx, tag.ID); err != nil {
+			return model.Tag{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Tag{}, err
+	}
+
+	return FindTag(userID, tag.ID)
+}
+
+// DeleteTag удаляет метку пользователя и снимает её со всех задач
+func DeleteTag(userID string, id string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.Get(&exists, "SELECT COUNT(*) > 0 FROM tags WHERE id = :id AND user_id = :user_id",
+		sql.Named("id", id),
+		sql.Named("user_id", userID))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	if err := touchTaggedTasks(tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM task_tags WHERE tag_id = :id", sql.Named("id", id)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE id = :id", sql.Named("id", id)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUserExists возвращается, если пользователь с таким именем уже есть
+	ErrUserExists = errors.New("user with this name already exists")
+	// ErrBadCredentials возвращается, если пользователя нет или пароль не подходит
+	ErrBadCredentials = errors.New("invalid name or password")
+)
+
+const userColumns = "id, name, admin, created_at"
+
+// userKey возвращает ключ пользователя, по которому имена сравниваются без учёта регистра
+func userKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// SetAdminPassword задаёт пароль администратора, если он отличается от сохранённого
+func SetAdminPassword(password string) error {
+	var hash string
+	if err := db.Get(&hash, "SELECT password_hash FROM users WHERE id = :id", sql.Named("id", model.AdminUserID)); err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+		return nil
+	}
+
+	newHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE users SET password_hash = :hash WHERE id = :id",
+		sql.Named("hash", string(newHash)),
+		sql.Named("id", model.AdminUserID))
+	return err
+}
+
+// Authenticate проверяет имя и пароль пользователя. Если пользователя нет или пароль
+// не подходит, возвращается ErrBadCredentials
+func Authenticate(name, password string) (model.User, error) {
+	var row struct {
+		model.User
+		Hash string `db:"password_hash"`
+	}
+	err := db.Get(&row, "SELECT "+userColumns+", password_hash FROM users WHERE key = :key",
+		sql.Named("key", userKey(name)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.User{}, ErrBadCredentials
+	}
+	if err != nil {
+		return model.User{}, err
+	}
+	if len(row.Hash) == 0 || bcrypt.CompareHashAndPassword([]byte(row.Hash), []byte(password)) != nil {
+		return model.User{}, ErrBadCredentials
+	}
+	return row.User, nil
+}
+
+// ReadUsers читает всех пользователей в порядке имён
+func ReadUsers() ([]model.User, error) {
+	users := []model.User{}
+	err := db.Select(&users, "SELECT "+userColumns+" FROM users ORDER BY key")
+	return users, err
+}
+
+// FindUser читает пользователя по ID
+func FindUser(id string) (model.User, error) {
+	var user model.User
+	err := db.Get(&user, "SELECT "+userColumns+" FROM users WHERE id = :id", sql.Named("id", id))
+	return user, err
+}
+
+// FindUserByName читает пользователя по имени без учёта регистра
+func FindUserByName(name string) (model.User, error) {
+	var user model.User
+	err := db.Get(&user, "SELECT "+userColumns+" FROM users WHERE key = :key", sql.Named("key", userKey(name)))
+	return user, err
+}
+
+// InsertUser добавляет пользователя с паролем user.Password и возвращает его ID
+func InsertUser(user model.User, now time.Time) (int, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+	result, err := db.Exec(`INSERT INTO users (name, key, password_hash, admin, created_at)
+		VALUES (:name, :key, :hash, :admin, :created_at)`,
+		sql.Named("name", user.Name),
+		sql.Named("key", userKey(user.Name)),
+		sql.Named("hash", string(hash)),
+		sql.Named("admin", user.Admin),
+		sql.Named("created_at", now.UTC().Format(time.RFC3339)))
+	if isUniqueViolation(err) {
+		return 0, ErrUserExists
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// RevokeToken отзывает токен с ID jti, который действует до expiresAt, и удаляет записи
+// о токенах, срок действия которых к моменту now уже истёк
+func RevokeToken(jti string, expiresAt time.Time, now time.Time) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM revoked_tokens WHERE expires_at < :now",
+		sql.Named("now", now.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT OR IGNORE INTO revoked_tokens (jti, expires_at) VALUES (:jti, :expires_at)",
+		sql.Named("jti", jti),
+		sql.Named("expires_at", expiresAt.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// TokenRevoked проверяет, отозван ли токен с ID jti
+func TokenRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := db.Get(&revoked, "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = :jti)", sql.Named("jti", jti))
+	return revoked, err
+}
+
+// feedTokenHash возвращает хеш токена подписки, под которым токен хранится в базе
+func feedTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetFeedToken задаёт пользователю токен подписки на календарь. Прежний токен перестаёт действовать
+func SetFeedToken(userID string, token string, now time.Time) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO feed_tokens (user_id, token_hash, created_at)
+		VALUES (:user_id, :token_hash, :created_at)`,
+		sql.Named("user_id", userID),
+		sql.Named("token_hash", feedTokenHash(token)),
+		sql.Named("created_at", now.UTC().Format(time.RFC3339)))
+	return err
+}
+
+// FindFeedUser читает пользователя по токену подписки на календарь
+func FindFeedUser(token string) (model.User, error) {
+	var user model.User
+	err := db.Get(&user, "SELECT "+userColumns+" FROM users WHERE id = (SELECT user_id FROM feed_tokens WHERE token_hash = :token_hash)",
+		sql.Named("token_hash", feedTokenHash(token)))
+	return user, err
+}
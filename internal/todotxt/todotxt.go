@@ -0,0 +1,254 @@
+// Package todotxt переводит задачи в формат todo.txt и обратно.
+//
+// Строка задачи имеет вид
+//
+//	[x] [(A)] [дата создания] заголовок [+проект] [@контекст] [due:YYYY-MM-DD] [rec:+1w]
+//
+// Проект +проект становится проектом задачи, а контексты @контекст - её метками. В названиях
+// проекта и меток пробелы заменяются на "_". Приоритеты (A)-(D) соответствуют приоритетам задачи 1-4.
+// Правило повтора записывается ключом rec:, а если его нельзя выразить в rec:, -
+// ключом repeat:, в котором пробелы заменены на "_", например repeat:m_-1,15
+package todotxt
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// datePat формат дат todo.txt
+const datePat = "2006-01-02"
+
+var (
+	priorityRe = regexp.MustCompile(`^\(([A-Z])\)$`)
+	recRe      = regexp.MustCompile(`^\+?([0-9]+)([dwmyb])$`)
+)
+
+// ErrUnsupportedRec возвращается для значений rec:, которые нельзя выразить правилом повтора задачи
+var ErrUnsupportedRec = errors.New("unsupported rec")
+
+// Format записывает задачу с названием проекта task.Project строкой todo.txt
+func Format(task model.ExportTask) string {
+	parts := []string{}
+	if task.Done {
+		parts = append(parts, "x")
+	}
+	if task.Priority > 0 && !task.Done {
+		parts = append(parts, fmt.Sprintf("(%c)", 'A'+task.Priority-1))
+	}
+	parts = append(parts, strings.Join(strings.Fields(task.Title), " "))
+	if len(task.Project) > 0 {
+		parts = append(parts, "+"+word(task.Project))
+	}
+	for _, tag := range task.Tags {
+		parts = append(parts, "@"+word(tag))
+	}
+
+	date, err := time.Parse(model.DatePat, task.Date)
+	if err == nil {
+		parts = append(parts, "due:"+date.Format(datePat))
+	}
+	if len(task.Repeat) > 0 {
+		if rec, ok := Rec(date, task.Repeat); ok && err == nil {
+			parts = append(parts, "rec:"+rec)
+		} else {
+			parts = append(parts, "repeat:"+strings.ReplaceAll(task.Repeat, " ", "_"))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Parse разбирает строку todo.txt в задачу и название её проекта. Приоритеты ниже (D) становятся
+// приоритетом 4, а дата создания и дата выполнения не сохраняются. Из нескольких проектов
+// задаче достаётся первый, остальные остаются в заголовке.
+// Если у задачи нет due:, rec: вычисляется относительно now
+func Parse(line string, now time.Time) (model.ExportTask, error) {
+	result := model.ExportTask{}
+	task := &result.Task
+	fields := strings.Fields(line)
+
+	// Выполненная задача: "x [дата выполнения] [дата создания]", иначе "[(A)] [дата создания]"
+	if len(fields) > 0 && fields[0] == "x" {
+		task.Done = true
+		fields = fields[1:]
+		for i := 0; i < 2 && len(fields) > 0 && isDate(fields[0]); i++ {
+			fields = fields[1:]
+		}
+	} else {
+		if len(fields) > 0 {
+			if match := priorityRe.FindStringSubmatch(fields[0]); match != nil {
+				task.Priority = min(int(match[1][0]-'A')+1, model.MaxPriority)
+				fields = fields[1:]
+			}
+		}
+		if len(fields) > 0 && isDate(fields[0]) {
+			fields = fields[1:]
+		}
+	}
+
+	title := make([]string, 0, len(fields))
+	var rec string
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, ":")
+		switch {
+		case ok && key == "due":
+			date, err := time.Parse(datePat, value)
+			if err != nil {
+				return result, fmt.Errorf("bad due date %q: %w", value, err)
+			}
+			task.Date = date.Format(model.DatePat)
+		case ok && key == "rec":
+			rec = value
+		case ok && key == "repeat":
+			task.Repeat = strings.ReplaceAll(value, "_", " ")
+		case len(field) > 1 && field[0] == '+' && len(result.Project) == 0:
+			result.Project = strings.ReplaceAll(field[1:], "_", " ")
+		case len(field) > 1 && field[0] == '@':
+			task.Tags = append(task.Tags, strings.ReplaceAll(field[1:], "_", " "))
+		default:
+			title = append(title, field)
+		}
+	}
+	task.Title = strings.Join(title, " ")
+
+	if len(rec) > 0 {
+		base := now
+		if len(task.Date) > 0 {
+			base, _ = time.Parse(model.DatePat, task.Date)
+		}
+		repeat, err := Repeat(rec, base)
+		if err != nil {
+			return result, err
+		}
+		task.Repeat = repeat
+	}
+	return result, nil
+}
+
+// Repeat переводит значение rec: в правило повтора задачи с датой date.
+// Повтор задачи всегда отсчитывается от её даты, поэтому rec: с "+" и без него равнозначны
+func Repeat(rec string, date time.Time) (string, error) {
+	match := recRe.FindStringSubmatch(rec)
+	if match == nil {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedRec, rec)
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil || n < 1 {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedRec, rec)
+	}
+
+	switch match[2] {
+	case "d":
+		if n <= 400 {
+			return fmt.Sprintf("d %d", n), nil
+		}
+	case "w":
+		if n*7 <= 400 {
+			return fmt.Sprintf("d %d", n*7), nil
+		}
+	case "b":
+		if n == 1 {
+			return "w 1,2,3,4,5", nil
+		}
+	case "y":
+		if n == 1 {
+			return "y", nil
+		}
+	case "m":
+		// Повтор раз в n месяцев выражается списком месяцев, если n делит год нацело
+		if 12%n == 0 {
+			if n == 1 {
+				return fmt.Sprintf("m %d", date.Day()), nil
+			}
+			months := make([]string, 0, 12/n)
+			for m := (int(date.Month())-1)%n + 1; m <= 12; m += n {
+				months = append(months, strconv.Itoa(m))
+			}
+			return fmt.Sprintf("m %d %s", date.Day(), strings.Join(months, ",")), nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnsupportedRec, rec)
+}
+
+// Rec переводит правило повтора задачи с датой date в значение rec:.
+// Если правило нельзя выразить в rec:, возвращается false
+func Rec(date time.Time, repeat string) (string, bool) {
+	fields := strings.Fields(repeat)
+	switch {
+	case len(fields) == 1 && fields[0] == "y":
+		return "+1y", true
+	case len(fields) == 2 && fields[0] == "d":
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 {
+			return "", false
+		}
+		if n%7 == 0 {
+			return fmt.Sprintf("+%dw", n/7), true
+		}
+		return fmt.Sprintf("+%dd", n), true
+	case len(fields) == 2 && fields[0] == "w":
+		if days := numbers(fields[1]); slices.Equal(days, []int{1, 2, 3, 4, 5}) {
+			return "+1b", true
+		} else if len(days) == 1 && days[0] == isoWeekday(date) {
+			return "+1w", true
+		}
+	case len(fields) >= 2 && fields[0] == "m":
+		if days := numbers(fields[1]); len(days) != 1 || days[0] != date.Day() {
+			return "", false
+		}
+		if len(fields) == 2 {
+			return "+1m", true
+		}
+		// Месяцы должны идти с одинаковым шагом, делящим год, и включать месяц даты задачи
+		months := numbers(fields[2])
+		if len(months) == 0 || 12%len(months) != 0 || !slices.Contains(months, int(date.Month())) {
+			return "", false
+		}
+		step := 12 / len(months)
+		for i := 1; i < len(months); i++ {
+			if months[i]-months[i-1] != step {
+				return "", false
+			}
+		}
+		return fmt.Sprintf("+%dm", step), true
+	}
+	return "", false
+}
+
+// numbers разбирает список чисел через запятую и упорядочивает его по возрастанию
+func numbers(list string) []int {
+	result := []int{}
+	for _, part := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil
+		}
+		result = append(result, n)
+	}
+	slices.Sort(result)
+	return result
+}
+
+// isoWeekday возвращает номер дня недели: 1 - понедельник, ..., 7 - воскресенье
+func isoWeekday(date time.Time) int {
+	if date.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(date.Weekday())
+}
+
+// word записывает название проекта или метки одним словом, заменяя пробелы на "_"
+func word(name string) string {
+	return strings.Join(strings.Fields(name), "_")
+}
+
+func isDate(s string) bool {
+	_, err := time.Parse(datePat, s)
+	return err == nil
+}
@@ -0,0 +1,260 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	"github.com/Zelvalna/go_final_project/internal/todotxt"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// Поддерживаемые форматы выгрузки и загрузки
+const (
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatTodoTxt = "todotxt"
+)
+
+// ErrUnknownFormat возвращается для неподдерживаемого формата
+var ErrUnknownFormat = errors.New("unknown format")
+
+// csvHeader заголовок CSV-файла. Даты выполнений и ID блокирующих задач записываются через пробел,
+// а метки - через запятую. Проект записывается названием
+var csvHeader = []string{"id", "date", "title", "comment", "repeat", "done", "completions", "tags", "priority",
+	"project", "blocked_by"}
+
+// ContentType возвращает тип содержимого для формата
+func ContentType(format string) (string, error) {
+	switch format {
+	case FormatJSON:
+		return "application/json; charset=UTF-8", nil
+	case FormatCSV:
+		return "text/csv; charset=UTF-8", nil
+	case FormatTodoTxt:
+		return "text/plain; charset=UTF-8", nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// Export записывает в w все задачи пользователя, кроме находящихся в корзине, вместе с историей выполнения
+func Export(w io.Writer, userID string, format string) error {
+	tasks, err := storage.ReadAllTasks(userID)
+	if err != nil {
+		return err
+	}
+	completions, err := storage.ReadAllCompletions(userID)
+	if err != nil {
+		return err
+	}
+	projects, err := storage.ReadProjects(userID, true)
+	if err != nil {
+		return err
+	}
+	exported := make([]model.ExportTask, 0, len(tasks))
+	for _, task := range tasks {
+		export := model.ExportTask{Task: task, Completions: completions[task.ID]}
+		for _, project := range projects {
+			if project.ID == task.ProjectID {
+				export.Project = project.Name
+			}
+		}
+		exported = append(exported, export)
+	}
+
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, exported)
+	case FormatCSV:
+		return writeCSV(w, exported)
+	case FormatTodoTxt:
+		return writeTodoTxt(w, exported)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// writeJSON записывает задачи по одной, не собирая весь документ в памяти
+func writeJSON(w io.Writer, tasks []model.ExportTask) error {
+	if _, err := io.WriteString(w, `{"tasks":[`); err != nil {
+		return err
+	}
+	for i, task := range tasks {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]}\n")
+	return err
+}
+
+func writeCSV(w io.Writer, tasks []model.ExportTask) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		dates := make([]string, 0, len(task.Completions))
+		for _, completion := range task.Completions {
+			dates = append(dates, completion.Date)
+		}
+		record := []string{
+			task.ID,
+			task.Date,
+			task.Title,
+			task.Comment,
+			task.Repeat,
+			strconv.FormatBool(task.Done),
+			strings.Join(dates, " "),
+			strings.Join(task.Tags, ","),
+			strconv.Itoa(task.Priority),
+			task.Project,
+			strings.Join(task.BlockedBy, " "),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Decode читает строки с задачами из r в указанном формате
+func Decode(r io.Reader, format string) ([]Row, error) {
+	switch format {
+	case FormatJSON:
+		return readJSON(r)
+	case FormatCSV:
+		return readCSV(r)
+	case FormatTodoTxt:
+		return readTodoTxt(r)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+func readJSON(r io.Reader) ([]Row, error) {
+	var export model.ExportTasks
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, err
+	}
+	rows := make([]Row, 0, len(export.Tasks))
+	for _, task := range export.Tasks {
+		rows = append(rows, Row{Task: task.Task, Project: task.Project, Completions: task.Completions})
+	}
+	return rows, nil
+}
+
+// readCSV сопоставляет столбцы по заголовку, поэтому порядок столбцов и лишние столбцы не важны
+func readCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("title column is missing")
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := []Row{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		done, _ := strconv.ParseBool(field(record, "done"))
+		// Некорректный приоритет оставляется как есть, чтобы строку отклонила проверка задачи
+		priority := 0
+		if value := field(record, "priority"); len(value) > 0 {
+			if priority, err = strconv.Atoi(value); err != nil {
+				priority = -1
+			}
+		}
+		// Без столбца tags метки задачи при перезаписи не меняются
+		var tags []string
+		if _, ok := columns["tags"]; ok {
+			tags = []string{}
+			if value := field(record, "tags"); len(value) > 0 {
+				tags = strings.Split(value, ",")
+			}
+		}
+		completions := []model.Completion{}
+		for _, date := range strings.Fields(field(record, "completions")) {
+			completions = append(completions, model.Completion{Date: date})
+		}
+		rows = append(rows, Row{
+			Task: model.Task{
+				ID:        field(record, "id"),
+				Date:      field(record, "date"),
+				Title:     field(record, "title"),
+				Comment:   field(record, "comment"),
+				Repeat:    field(record, "repeat"),
+				Done:      done,
+				Tags:      tags,
+				Priority:  priority,
+				BlockedBy: strings.Fields(field(record, "blocked_by")),
+			},
+			Project:     field(record, "project"),
+			Completions: completions,
+		})
+	}
+	return rows, nil
+}
+
+// writeTodoTxt записывает задачи по одной в строке todo.txt
+func writeTodoTxt(w io.Writer, tasks []model.ExportTask) error {
+	for _, task := range tasks {
+		if _, err := io.WriteString(w, todotxt.Format(task)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTodoTxt читает строки todo.txt. Пустые строки пропускаются, но учитываются в номерах строк
+func readTodoTxt(r io.Reader) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	rows := []Row{}
+	line := 0
+	now := time.Now()
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 {
+			continue
+		}
+		task, err := todotxt.Parse(text, now)
+		rows = append(rows, Row{Ref: "line " + strconv.Itoa(line), Task: task.Task, Project: task.Project, Err: err})
+	}
+	return rows, scanner.Err()
+}
@@ -0,0 +1,209 @@
+package transfer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/internal/storage"
+	dates "github.com/Zelvalna/go_final_project/internal/utils"
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// Режимы обработки задач, которые уже есть в базе
+const (
+	ModeSkip      = "skip"
+	ModeOverwrite = "overwrite"
+)
+
+// Options параметры загрузки задач
+type Options struct {
+	// UserID пользователь, которому загружаются задачи
+	UserID string
+	// DryRun только проверяет задачи и составляет отчёт, ничего не сохраняя
+	DryRun bool
+	// Mode определяет, что делать с дубликатами: пропустить или перезаписать
+	Mode string
+	// OnChange вызывается после каждой сохранённой задачи
+	OnChange func(action string, oldTask *model.Task, newTask model.Task)
+}
+
+// Row строка загружаемого файла: задача или ошибка её разбора
+type Row struct {
+	// Ref необязательная ссылка на строку в исходном файле, например UID компонента календаря
+	Ref  string
+	Task model.Task
+	// Project название проекта задачи
+	Project string
+	// Completions история выполнения задачи
+	Completions []model.Completion
+	Err         error
+}
+
+// checkCompletions проверяет даты выполнений. Если время выполнения не указано, например в CSV,
+// им считается начало дня выполнения
+func checkCompletions(completions []model.Completion) error {
+	for i, c := range completions {
+		date, err := time.Parse(model.DatePat, c.Date)
+		if err != nil {
+			return fmt.Errorf("invalid completion date %q", c.Date)
+		}
+		if len(c.CompletedAt) == 0 {
+			completions[i].CompletedAt = date.UTC().Format(time.RFC3339)
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, c.CompletedAt); err != nil {
+			return fmt.Errorf("invalid completion time %q", c.CompletedAt)
+		}
+	}
+	return nil
+}
+
+// ImportRows проверяет и сохраняет задачи из строк файла.
+// Дубликатом считается задача с тем же заголовком и тем же ID либо той же датой и правилом повтора.
+// ID проекта и блокирующих задач из файла относятся к базе, из которой задачи выгружены, поэтому
+// проект находится по названию и создаётся, если его нет, а зависимости восстанавливаются между
+// задачами файла после загрузки всех строк. История выполнения добавляется к сохранённой задаче.
+// Ошибка в одной строке не прерывает загрузку остальных и попадает в отчёт
+func ImportRows(rows []Row, opts Options) model.ImportReport {
+	report := model.ImportReport{
+		DryRun: opts.DryRun,
+		Total:  len(rows),
+		Errors: []model.ImportError{},
+	}
+	// warn добавляет в отчёт ошибку в строке, задача из которой уже сохранена
+	warn := func(row int, err error) {
+		report.Errors = append(report.Errors, model.ImportError{Row: row, Ref: rows[row-1].Ref, Error: err.Error()})
+	}
+	fail := func(row int, err error) {
+		report.Failed++
+		warn(row, err)
+	}
+	notify := func(action string, oldTask *model.Task, newTask model.Task) {
+		if opts.OnChange != nil {
+			opts.OnChange(action, oldTask, newTask)
+		}
+	}
+
+	// localIDs сопоставляет ID задач из файла с ID сохранённых или уже существующих задач
+	localIDs := make(map[string]string, len(rows))
+	// saved строки с сохранёнными задачами, для которых восстанавливаются зависимости
+	saved := make(map[int]string, len(rows))
+	// planned задачи, которые пробная загрузка посчитала созданными. Настоящая загрузка находит
+	// их дубликаты среди уже сохранённых строк файла, поэтому пробная проверяет их сама
+	planned := make(map[string]bool, len(rows))
+	now := time.Now()
+	for i, r := range rows {
+		row := i + 1
+		task := r.Task
+		if r.Err != nil {
+			fail(row, r.Err)
+			continue
+		}
+		sourceID := task.ID
+		task.ProjectID = ""
+		task.BlockedBy = nil
+		if err := dates.CheckTask(&task, now); err != nil {
+			fail(row, err)
+			continue
+		}
+		if err := checkCompletions(r.Completions); err != nil {
+			fail(row, err)
+			continue
+		}
+
+		existing, err := storage.FindDuplicate(opts.UserID, task)
+		switch {
+		case err == nil && opts.Mode != ModeOverwrite:
+			localIDs[sourceID] = existing.ID
+			report.Skipped++
+			continue
+		case err == nil && existing.Done:
+			fail(row, fmt.Errorf("task with id=%s is already done", existing.ID))
+			continue
+		case err != nil && !errors.Is(err, sql.ErrNoRows):
+			fail(row, err)
+			continue
+		}
+		if opts.DryRun {
+			key := strings.Join([]string{task.Title, task.Date, task.Repeat}, "\x00")
+			switch {
+			case err == nil:
+				report.Updated++
+			case planned[key] && opts.Mode != ModeOverwrite:
+				report.Skipped++
+			case planned[key]:
+				report.Updated++
+			default:
+				planned[key] = true
+				report.Created++
+			}
+			continue
+		}
+		if len(r.Project) > 0 {
+			projectID, err := storage.FindOrCreateProject(opts.UserID, r.Project)
+			if err != nil {
+				fail(row, err)
+				continue
+			}
+			task.ProjectID = projectID
+		}
+
+		if err == nil {
+			task.ID = existing.ID
+			task.Version = ""
+			updated, err := storage.UpdateTask(opts.UserID, task)
+			if err != nil {
+				fail(row, err)
+				continue
+			}
+			notify(model.AuditUpdate, &existing, updated)
+			report.Updated++
+		} else {
+			id, err := storage.InsertTask(opts.UserID, task)
+			if err != nil {
+				fail(row, err)
+				continue
+			}
+			task.ID = strconv.Itoa(id)
+			task.Version = ""
+			notify(model.AuditInsert, nil, task)
+			report.Created++
+		}
+		localIDs[sourceID] = task.ID
+		saved[row] = task.ID
+
+		if len(r.Completions) > 0 {
+			if err := storage.ImportCompletions(opts.UserID, task.ID, r.Completions); err != nil {
+				warn(row, fmt.Errorf("completions not imported: %w", err))
+			}
+		}
+	}
+
+	for i, r := range rows {
+		row := i + 1
+		id, ok := saved[row]
+		if !ok || len(r.Task.BlockedBy) == 0 {
+			continue
+		}
+		blockers := make([]string, 0, len(r.Task.BlockedBy))
+		for _, blocker := range r.Task.BlockedBy {
+			local, ok := localIDs[blocker]
+			if !ok {
+				warn(row, fmt.Errorf("blocking task %s is not in the file", blocker))
+				continue
+			}
+			blockers = append(blockers, local)
+		}
+		oldTask, task, err := storage.SetTaskBlockers(opts.UserID, id, blockers)
+		if err != nil {
+			warn(row, fmt.Errorf("dependencies not imported: %w", err))
+			continue
+		}
+		notify(model.AuditUpdate, &oldTask, task)
+	}
+	return report
+}
@@ -0,0 +1,192 @@
+package dates
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// CheckTask проверяет задачу перед сохранением: пустая дата заменяется сегодняшней,
+// прошедшая дата переносится на сегодня, заголовок должен быть непустым,
+// а правило повтора, приоритет, метки и блокирующие задачи - корректными
+func CheckTask(task *model.Task, now time.Time) error {
+	// Установка даты по умолчанию или проверка формата даты
+	if len(task.Date) == 0 {
+		task.Date = now.Format(model.DatePat)
+	} else {
+		date, err := time.Parse(model.DatePat, task.Date)
+		if err != nil {
+			return fmt.Errorf("bad data format: %w", err)
+		}
+
+		if date.Before(now) {
+			task.Date = now.Format(model.DatePat)
+		}
+	}
+	// Проверка заголовка задачи
+	if len(task.Title) == 0 {
+		return errors.New("invalid title: title is empty")
+	}
+	// Проверка формата повтора
+	if len(task.Repeat) > 0 {
+		if _, err := GetNextDate(now, task.Date, task.Repeat); err != nil {
+			return errors.New("invalid repeat format: no such format")
+		}
+	}
+	if task.Priority < 0 || task.Priority > model.MaxPriority {
+		return fmt.Errorf("invalid priority %d: expected 1-%d or 0 for none", task.Priority, model.MaxPriority)
+	}
+	// Метки без учёта регистра и пробелов по краям не должны повторяться
+	if task.Tags != nil {
+		tags := make([]string, 0, len(task.Tags))
+		seen := make(map[string]bool, len(task.Tags))
+		for _, tag := range task.Tags {
+			tag, err := CheckTagName(tag)
+			if err != nil {
+				return err
+			}
+			if key := strings.ToLower(tag); !seen[key] {
+				seen[key] = true
+				tags = append(tags, tag)
+			}
+		}
+		task.Tags = tags
+	}
+	if task.BlockedBy != nil {
+		blockers := make([]string, 0, len(task.BlockedBy))
+		seen := make(map[int64]bool, len(task.BlockedBy))
+		for _, blocker := range task.BlockedBy {
+			id, err := strconv.ParseInt(strings.TrimSpace(blocker), 10, 64)
+			if err != nil || id <= 0 {
+				return fmt.Errorf("invalid blocked_by: bad task id %q", blocker)
+			}
+			if !seen[id] {
+				seen[id] = true
+				blockers = append(blockers, strconv.FormatInt(id, 10))
+			}
+		}
+		task.BlockedBy = blockers
+	}
+	return nil
+}
+
+// tagColor формат цвета метки
+var tagColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// CheckTagName проверяет название метки и возвращает его без пробелов по краям.
+// Запятые запрещены, так как в CSV метки записываются через запятую
+func CheckTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return "", errors.New("invalid tag: name is empty")
+	}
+	if utf8.RuneCountInString(name) > model.MaxTagLength {
+		return "", fmt.Errorf("invalid tag %q: name is longer than %d characters", name, model.MaxTagLength)
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return r == ',' || unicode.IsControl(r) }) {
+		return "", fmt.Errorf("invalid tag %q: name contains a comma or control character", name)
+	}
+	return name, nil
+}
+
+// CheckProject проверяет название проекта и убирает пробелы по краям
+func CheckProject(project *model.Project) error {
+	project.Name = strings.TrimSpace(project.Name)
+	if len(project.Name) == 0 {
+		return errors.New("invalid project: name is empty")
+	}
+	if utf8.RuneCountInString(project.Name) > model.MaxProjectNameLength {
+		return fmt.Errorf("invalid project: name is longer than %d characters", model.MaxProjectNameLength)
+	}
+	return nil
+}
+
+// CheckChecklistItem проверяет текст пункта чек-листа и убирает пробелы по краям
+func CheckChecklistItem(item *model.ChecklistItem) error {
+	item.Text = strings.TrimSpace(item.Text)
+	if len(item.Text) == 0 {
+		return errors.New("invalid checklist item: text is empty")
+	}
+	if utf8.RuneCountInString(item.Text) > model.MaxChecklistItemLength {
+		return fmt.Errorf("invalid checklist item: text is longer than %d characters", model.MaxChecklistItemLength)
+	}
+	return nil
+}
+
+// CheckTag проверяет название и цвет метки
+func CheckTag(tag *model.Tag) error {
+	name, err := CheckTagName(tag.Name)
+	if err != nil {
+		return err
+	}
+	tag.Name = name
+	if len(tag.Color) > 0 && !tagColor.MatchString(tag.Color) {
+		return fmt.Errorf("invalid tag color %q: expected #RRGGBB", tag.Color)
+	}
+	return nil
+}
+
+// CheckUser проверяет имя и пароль нового пользователя и убирает пробелы по краям имени.
+// Имя используется для входа по схеме Basic, поэтому в нём не может быть двоеточия
+func CheckUser(user *model.User) error {
+	user.Name = strings.TrimSpace(user.Name)
+	if len(user.Name) == 0 {
+		return errors.New("invalid user: name is empty")
+	}
+	if utf8.RuneCountInString(user.Name) > model.MaxUserNameLength {
+		return fmt.Errorf("invalid user: name is longer than %d characters", model.MaxUserNameLength)
+	}
+	if strings.ContainsFunc(user.Name, func(r rune) bool { return r == ':' || unicode.IsSpace(r) || unicode.IsControl(r) }) {
+		return fmt.Errorf("invalid user %q: name contains a colon, space or control character", user.Name)
+	}
+	if utf8.RuneCountInString(user.Password) < model.MinPasswordLength {
+		return fmt.Errorf("invalid user %q: password is shorter than %d characters", user.Name, model.MinPasswordLength)
+	}
+	// bcrypt учитывает только первые 72 байта пароля
+	if len(user.Password) > 72 {
+		return fmt.Errorf("invalid user %q: password is longer than 72 bytes", user.Name)
+	}
+	return nil
+}
+
+// CheckShareTarget проверяет, что доступ относится к одной задаче или одному проекту
+func CheckShareTarget(share model.Share) error {
+	if (len(share.TaskID) > 0) == (len(share.ProjectID) > 0) {
+		return errors.New("invalid share: exactly one of task_id and project_id is required")
+	}
+	for _, id := range []string{share.TaskID, share.ProjectID} {
+		if len(id) == 0 {
+			continue
+		}
+		if n, err := strconv.Atoi(id); err != nil || n < 1 {
+			return fmt.Errorf("invalid share: bad id %q", id)
+		}
+	}
+	return nil
+}
+
+// CheckShare проверяет задачу или проект, пользователя и права доступа.
+// Без прав доступ открывается только для просмотра
+func CheckShare(share *model.Share) error {
+	if err := CheckShareTarget(*share); err != nil {
+		return err
+	}
+	share.User = strings.TrimSpace(share.User)
+	if len(share.User) == 0 {
+		return errors.New("invalid share: user is empty")
+	}
+	if len(share.Permission) == 0 {
+		share.Permission = model.PermissionView
+	}
+	if share.Permission != model.PermissionView && share.Permission != model.PermissionEdit {
+		return fmt.Errorf("invalid share: permission must be %s or %s", model.PermissionView, model.PermissionEdit)
+	}
+	return nil
+}
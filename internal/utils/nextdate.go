@@ -10,6 +10,11 @@ import (
 	"github.com/Zelvalna/go_final_project/model"
 )
 
+// maxRepeatMonths сколько месяцев после текущей даты ищется подходящий день для правила 'm'.
+// 29 февраля повторяется не реже, чем раз в восемь лет, поэтому правило без подходящего дня
+// за это время не сработает никогда, например "m 30 2"
+const maxRepeatMonths = 12 * 9
+
 // GetNextDate вычисляет следующую дату на основе текущей даты, исходной даты и правила повторения
 func GetNextDate(now time.Time, dateStr string, repeat string) (string, error) {
 	// Парсим строку с датой в объект времени
@@ -63,6 +68,9 @@ func GetNextDate(now time.Time, dateStr string, repeat string) (string, error) {
 				repeatDays = append(repeatDays, int(dayNumber))
 			}
 		}
+		if len(repeatDays) == 0 {
+			return "", errors.New("неверный формат повтора")
+		}
 		slices.Sort(repeatDays)
 		shift := repeatDays[0] - nowWeekDay
 		date = now.AddDate(0, 0, shift)
@@ -70,7 +78,7 @@ func GetNextDate(now time.Time, dateStr string, repeat string) (string, error) {
 		// Если повторение через определенные дни и месяцы
 		format := strings.Split(strings.TrimPrefix(repeat, "m "), " ")
 		allowDays, err := parseDays(format)
-		if err != nil {
+		if err != nil || len(allowDays) == 0 {
 			return "", errors.New("неверный формат повтора")
 		}
 		allowMonths, err := parseMonths(format)
@@ -78,7 +86,15 @@ func GetNextDate(now time.Time, dateStr string, repeat string) (string, error) {
 			return "", errors.New("неверный формат повтора")
 		}
 
+		// monthsAhead количество просмотренных месяцев после текущей даты
+		monthsAhead := 0
 		for {
+			if date.After(now) {
+				monthsAhead++
+				if monthsAhead > maxRepeatMonths {
+					return "", errors.New("по правилу повтора нет подходящей даты")
+				}
+			}
 			if !isSliceHas(allowMonths, int(date.Month())) {
 				date = date.AddDate(0, 1, 0)
 				if date.Day() > 1 {
@@ -114,7 +130,7 @@ func parseDays(format []string) ([]int, error) {
 	allowDays := make([]int, 0, len(daysStr))
 	for _, dayS := range daysStr {
 		if day, err := strconv.ParseInt(dayS, 10, 64); err == nil {
-			if day < -2 || day > 31 {
+			if day < -2 || day > 31 || day == 0 {
 				return []int{}, errors.New("неверный формат повтора")
 			}
 			allowDays = append(allowDays, int(day))
@@ -0,0 +1,56 @@
+package dates
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Zelvalna/go_final_project/model"
+)
+
+// maxOccurrences ограничивает количество повторений одной задачи в диапазоне
+const maxOccurrences = 1000
+
+// Occurrences возвращает все даты задачи, попадающие в диапазон [from, to].
+// Для повторяющейся задачи даты вычисляются последовательно с помощью GetNextDate,
+// начиная с даты самой задачи
+func Occurrences(dateStr string, repeat string, from, to time.Time) ([]string, error) {
+	date, err := time.Parse(model.DatePat, dateStr)
+	if err != nil {
+		return nil, errors.New("неверный формат даты")
+	}
+
+	result := []string{}
+	if repeat == "" {
+		if !date.Before(from) && !date.After(to) {
+			result = append(result, dateStr)
+		}
+		return result, nil
+	}
+
+	// Если задача началась раньше диапазона, переходим к первому повторению внутри него
+	current := dateStr
+	if date.Before(from) {
+		current, err = GetNextDate(from.AddDate(0, 0, -1), dateStr, repeat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < maxOccurrences; i++ {
+		date, err = time.Parse(model.DatePat, current)
+		if err != nil {
+			return nil, errors.New("неверный формат даты")
+		}
+		if date.After(to) {
+			break
+		}
+		result = append(result, current)
+
+		current, err = GetNextDate(date, current, repeat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,8 @@
+package model
+
+type RestoreResponse struct {
+	// SchemaVersion версия схемы восстановленной базы до применения миграций
+	SchemaVersion int `json:"schema_version"`
+	// Snapshot путь к копии базы, сделанной перед восстановлением
+	Snapshot string `json:"snapshot"`
+}
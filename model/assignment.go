@@ -0,0 +1,42 @@
+package model
+
+// Состояния назначения задачи исполнителю
+const (
+	// AssignPending исполнитель ещё не ответил на назначение
+	AssignPending = "pending"
+	// AssignAccepted исполнитель принял задачу и может её изменять и выполнять
+	AssignAccepted = "accepted"
+	// AssignDeclined исполнитель отказался от задачи и больше её не видит
+	AssignDeclined = "declined"
+)
+
+// Виды уведомлений об изменении назначения задачи
+const (
+	// NotifyAssigned задача назначена пользователю
+	NotifyAssigned = "assigned"
+	// NotifyUnassigned назначение пользователю снято или задача передана другому исполнителю
+	NotifyUnassigned = "unassigned"
+	// NotifyAccepted исполнитель принял задачу владельца
+	NotifyAccepted = "accepted"
+	// NotifyDeclined исполнитель отказался от задачи владельца
+	NotifyDeclined = "declined"
+)
+
+// AssignedToMe значение параметра assigned, с которым GET /api/tasks возвращает назначенные пользователю задачи
+const AssignedToMe = "me"
+
+// Notification уведомление пользователя об изменении назначения задачи
+type Notification struct {
+	ID     string `json:"id" db:"id"`
+	TaskID string `json:"task_id" db:"task_id"`
+	// Title заголовок задачи на момент уведомления
+	Title string `json:"title" db:"title"`
+	Kind  string `json:"kind" db:"kind"`
+	// Actor имя пользователя, который изменил назначение
+	Actor     string `json:"actor" db:"actor"`
+	CreatedAt string `json:"created_at" db:"created_at"`
+}
+
+type Notifications struct {
+	Notifications []Notification `json:"notifications"`
+}
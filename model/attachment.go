@@ -0,0 +1,19 @@
+package model
+
+// Attachment файл, прикреплённый к задаче
+type Attachment struct {
+	ID     string `json:"id" db:"id"`
+	TaskID string `json:"task_id" db:"task_id"`
+	// Name имя файла, переданное при загрузке
+	Name string `json:"name" db:"name"`
+	// ContentType тип содержимого, определённый по первым байтам файла
+	ContentType string `json:"content_type" db:"content_type"`
+	Size        int64  `json:"size" db:"size"`
+	CreatedAt   string `json:"created_at" db:"created_at"`
+	// File имя файла в каталоге вложений
+	File string `json:"-" db:"file"`
+}
+
+type Attachments struct {
+	Attachments []Attachment `json:"attachments"`
+}
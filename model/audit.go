@@ -0,0 +1,32 @@
+package model
+
+import "encoding/json"
+
+// Действия над задачами, которые попадают в журнал аудита
+const (
+	AuditInsert  = "insert"
+	AuditUpdate  = "update"
+	AuditDone    = "done"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+	AuditAssign  = "assign"
+	AuditAccept  = "accept"
+	AuditDecline = "decline"
+)
+
+type AuditEntry struct {
+	ID        string          `json:"id"`
+	TaskID    string          `json:"task_id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	CreatedAt string          `json:"created_at"`
+	Old       json.RawMessage `json:"old,omitempty"`
+	New       json.RawMessage `json:"new,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	// UserID владелец задачи, журнал которой доступен пользователю
+	UserID string `json:"-"`
+}
+type AuditEntries struct {
+	Entries []AuditEntry `json:"entries"`
+}
@@ -0,0 +1,8 @@
+package model
+
+// CalDAVResource имя ресурса и UID задачи в коллекции CalDAV
+type CalDAVResource struct {
+	TaskID string `db:"task_id"`
+	Name   string `db:"name"`
+	UID    string `db:"uid"`
+}
@@ -0,0 +1,23 @@
+package model
+
+type CalendarDay struct {
+	Date  string `json:"date"`
+	Tasks []Task `json:"tasks"`
+}
+type Calendar struct {
+	Month string        `json:"month"`
+	Days  []CalendarDay `json:"days"`
+}
+
+type AgendaBucket struct {
+	Count int    `json:"count"`
+	Tasks []Task `json:"tasks"`
+}
+type Agenda struct {
+	Date     string       `json:"date"`
+	Overdue  AgendaBucket `json:"overdue"`
+	Today    AgendaBucket `json:"today"`
+	Tomorrow AgendaBucket `json:"tomorrow"`
+	Week     AgendaBucket `json:"week"`
+	Later    AgendaBucket `json:"later"`
+}
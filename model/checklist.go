@@ -0,0 +1,23 @@
+package model
+
+// MaxChecklistItemLength максимальная длина пункта чек-листа в символах
+const MaxChecklistItemLength = 512
+
+// ChecklistItem пункт чек-листа задачи
+type ChecklistItem struct {
+	ID     string `json:"id" db:"id"`
+	TaskID string `json:"task_id" db:"task_id"`
+	Text   string `json:"text" db:"text"`
+	// Position порядковый номер пункта в чек-листе, начиная с 1
+	Position int  `json:"position" db:"position"`
+	Checked  bool `json:"checked" db:"checked"`
+}
+
+type Checklist struct {
+	Items []ChecklistItem `json:"items"`
+}
+
+// ChecklistOrder новый порядок пунктов чек-листа: ID всех пунктов задачи
+type ChecklistOrder struct {
+	Items []string `json:"items"`
+}
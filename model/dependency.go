@@ -0,0 +1,22 @@
+package model
+
+// TaskGraph граф зависимостей задач: узлы - задачи, рёбра ведут от задачи к блокирующей её задаче
+type TaskGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode задача в графе зависимостей
+type GraphNode struct {
+	ID      string `json:"id" db:"id"`
+	Date    string `json:"date" db:"date"`
+	Title   string `json:"title" db:"title"`
+	Done    bool   `json:"done,omitempty" db:"done"`
+	Blocked bool   `json:"blocked,omitempty" db:"blocked"`
+}
+
+// GraphEdge задача TaskID ждёт выполнения задачи BlockedBy
+type GraphEdge struct {
+	TaskID    string `json:"task_id" db:"task_id"`
+	BlockedBy string `json:"blocked_by" db:"blocker_id"`
+}
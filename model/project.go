@@ -0,0 +1,26 @@
+package model
+
+// MaxProjectNameLength максимальная длина названия проекта в символах
+const MaxProjectNameLength = 128
+
+// Способы удаления проекта с задачами
+const (
+	// ProjectDeleteMove переносит задачи проекта в другой проект или оставляет их без проекта
+	ProjectDeleteMove = "move"
+	// ProjectDeleteCascade перемещает задачи проекта в корзину вместе с удалением проекта
+	ProjectDeleteCascade = "cascade"
+)
+
+// Project список, в который объединяются задачи
+type Project struct {
+	ID   string `json:"id,omitempty" db:"id"`
+	Name string `json:"name" db:"name"`
+	// ArchivedAt время архивации проекта, пустое для активных проектов
+	ArchivedAt string `json:"archived_at,omitempty" db:"archived_at"`
+	// Tasks количество активных задач проекта
+	Tasks int `json:"tasks" db:"tasks"`
+}
+
+type Projects struct {
+	Projects []Project `json:"projects"`
+}
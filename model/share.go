@@ -0,0 +1,45 @@
+package model
+
+// Права доступа к задачам и проектам, открытым другим пользователям
+const (
+	// PermissionView разрешает только просмотр
+	PermissionView = "view"
+	// PermissionEdit разрешает также изменять, выполнять и удалять задачи
+	PermissionEdit = "edit"
+)
+
+// Share доступ пользователя к задаче или проекту владельца. Доступ к проекту
+// распространяется на все задачи проекта
+type Share struct {
+	ID        string `json:"id,omitempty" db:"id"`
+	TaskID    string `json:"task_id,omitempty" db:"task_id"`
+	ProjectID string `json:"project_id,omitempty" db:"project_id"`
+	// User имя пользователя, которому открыт доступ
+	User       string `json:"user" db:"user"`
+	Permission string `json:"permission" db:"permission"`
+	CreatedAt  string `json:"created_at,omitempty" db:"created_at"`
+}
+
+type Shares struct {
+	Shares []Share `json:"shares"`
+}
+
+// SharedTask задача другого пользователя, открытая текущему пользователю
+type SharedTask struct {
+	Task
+	Owner      string `json:"owner"`
+	Permission string `json:"permission"`
+}
+
+// SharedProject проект другого пользователя, открытый текущему пользователю
+type SharedProject struct {
+	Project
+	Owner      string `json:"owner" db:"owner"`
+	Permission string `json:"permission" db:"permission"`
+}
+
+// Shared задачи и проекты, открытые пользователю другими пользователями
+type Shared struct {
+	Tasks    []SharedTask    `json:"tasks"`
+	Projects []SharedProject `json:"projects"`
+}
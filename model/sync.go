@@ -0,0 +1,58 @@
+package model
+
+// Операции, которые клиент может передать в POST /api/sync
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+	SyncDone   = "done"
+)
+
+// Результаты применения изменения клиента
+const (
+	SyncStatusOK       = "ok"
+	SyncStatusConflict = "conflict"
+	SyncStatusError    = "error"
+)
+
+// SyncTombstone отметка об удалённой задаче
+type SyncTombstone struct {
+	ID        string `json:"id"`
+	DeletedAt string `json:"deleted_at"`
+}
+
+// SyncResponse изменения задач после токена since
+type SyncResponse struct {
+	Token   string          `json:"token"`
+	HasMore bool            `json:"has_more"`
+	Created []Task          `json:"created"`
+	Updated []Task          `json:"updated"`
+	Deleted []SyncTombstone `json:"deleted"`
+}
+
+// SyncPushItem изменение, сделанное клиентом без связи с сервером. Для update, delete и done
+// в задаче указываются ID и версия, которую видел клиент
+type SyncPushItem struct {
+	ClientID string `json:"client_id,omitempty"`
+	Op       string `json:"op"`
+	Task     Task   `json:"task"`
+	// Force для done выполняет задачу, даже если её блокируют невыполненные задачи
+	Force bool `json:"force,omitempty"`
+}
+type SyncPushRequest struct {
+	Changes []SyncPushItem `json:"changes"`
+}
+
+// SyncPushResult результат применения одного изменения. При конфликте возвращается
+// актуальная копия задачи
+type SyncPushResult struct {
+	ClientID string `json:"client_id,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Task     *Task  `json:"task,omitempty"`
+}
+type SyncPushResponse struct {
+	Token   string           `json:"token"`
+	Results []SyncPushResult `json:"results"`
+}
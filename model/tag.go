@@ -0,0 +1,18 @@
+package model
+
+// MaxTagLength максимальная длина названия метки в символах
+const MaxTagLength = 64
+
+// Tag метка, которой можно отметить несколько задач
+type Tag struct {
+	ID   string `json:"id,omitempty" db:"id"`
+	Name string `json:"name" db:"name"`
+	// Color цвет метки в формате #RRGGBB, пустой - цвет по умолчанию
+	Color string `json:"color" db:"color"`
+	// Tasks количество активных задач с этой меткой
+	Tasks int `json:"tasks" db:"tasks"`
+}
+
+type Tags struct {
+	Tags []Tag `json:"tags"`
+}
@@ -4,6 +4,24 @@ const (
 	DefPort = "7540"
 	WebDir  = "./web"
 	DatePat = "20060102"
+
+	DefTrashRetentionDays = 30
+	DefBackupDir          = "./backups"
+	DefBackupKeepDaily    = 7
+	DefBackupKeepWeekly   = 4
+	DefAttachDir          = "./attachments"
+	// DefAttachMaxSizeMB максимальный размер вложения в мегабайтах
+	DefAttachMaxSizeMB = 10
+	// DefTokenTTLHours срок действия токена в часах
+	DefTokenTTLHours = 8
+
+	// MaxPriority наименьший приоритет задачи. Приоритет 1 - самый высокий, 0 - приоритет не задан
+	MaxPriority = 4
+
+	// OrderDate сортировка списка задач по дате, используется по умолчанию
+	OrderDate = "date"
+	// OrderSmart сортировка списка задач: сначала просроченные, затем по приоритету и по дате
+	OrderSmart = "smart"
 )
 
 type Task struct {
@@ -12,18 +30,59 @@ type Task struct {
 	Title   string `json:"title,omitempty" db:"title"`
 	Comment string `json:"comment,omitempty" db:"comment"`
 	Repeat  string `json:"repeat,omitempty" db:"repeat"`
+	// DeletedAt время перемещения задачи в корзину, пустое для активных задач
+	DeletedAt string `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Done признак выполненной разовой задачи
+	Done bool `json:"done,omitempty" db:"done"`
+	// Version увеличивается при каждом изменении задачи
+	Version string `json:"version,omitempty" db:"version"`
+	// Priority приоритет задачи от 1 (высший) до MaxPriority, 0 - приоритет не задан
+	Priority int `json:"priority,omitempty" db:"priority"`
+	// ProjectID ID проекта задачи, пустой - задача не входит в проект
+	ProjectID string `json:"project_id,omitempty" db:"project_id"`
+	// Tags названия меток задачи. Если при изменении задачи поле не передано, метки не меняются
+	Tags []string `json:"tags,omitempty" db:"-"`
+	// Blocked хотя бы одна из задач BlockedBy ещё не выполнена и не находится в корзине
+	Blocked bool `json:"blocked,omitempty" db:"-"`
+	// BlockedBy ID задач, которые нужно выполнить до этой задачи. Если при изменении
+	// задачи поле не передано, зависимости не меняются
+	BlockedBy []string `json:"blocked_by,omitempty" db:"-"`
+	// Assignee имя исполнителя, которому владелец назначил задачу
+	Assignee string `json:"assignee,omitempty" db:"-"`
+	// AssigneeStatus ответ исполнителя на назначение: AssignPending, AssignAccepted или AssignDeclined
+	AssigneeStatus string `json:"assignee_status,omitempty" db:"assignee_status"`
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// ConflictResponse ответ на запись устаревшей версии задачи с её актуальной копией
+type ConflictResponse struct {
+	Error string `json:"error"`
+	Task  Task   `json:"task"`
+}
+
 type TaskIdResponse struct {
 	Id int `json:"id"`
 }
 type Tasks struct {
 	Tasks []Task `json:"tasks"`
 }
+
+// SignInRequest вход пользователя. Без имени выполняется вход администратора
 type SignInRequest struct {
+	Name     string `json:"name"`
 	Password string `json:"password"`
 }
+
+type Completion struct {
+	ID          string `json:"id"`
+	TaskID      string `json:"task_id"`
+	Date        string `json:"date"`
+	CompletedAt string `json:"completed_at"`
+	Title       string `json:"title,omitempty"`
+}
+type Completions struct {
+	Completions []Completion `json:"completions"`
+}
@@ -0,0 +1,28 @@
+package model
+
+// ExportTask задача вместе со связанными данными для выгрузки
+type ExportTask struct {
+	Task
+	// Project название проекта задачи. При загрузке проект находится по названию, так как
+	// ID проекта в другой базе или у другого пользователя не совпадает
+	Project     string       `json:"project,omitempty"`
+	Completions []Completion `json:"completions,omitempty"`
+}
+type ExportTasks struct {
+	Tasks []ExportTask `json:"tasks"`
+}
+
+type ImportError struct {
+	Row   int    `json:"row"`
+	Ref   string `json:"ref,omitempty"`
+	Error string `json:"error"`
+}
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Total   int           `json:"total"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Skipped int           `json:"skipped"`
+	Failed  int           `json:"failed"`
+	Errors  []ImportError `json:"errors"`
+}
@@ -0,0 +1,33 @@
+package model
+
+const (
+	// AdminUserID ID администратора, который входит с паролем TODO_PASSWORD. Ему принадлежат
+	// задачи, созданные до появления пользователей
+	AdminUserID = "1"
+	// AdminName имя администратора, под которым выполняется вход без указания имени
+	AdminName = "admin"
+
+	// MaxUserNameLength максимальная длина имени пользователя в символах
+	MaxUserNameLength = 64
+	// MinPasswordLength минимальная длина пароля пользователя в символах
+	MinPasswordLength = 8
+)
+
+// User пользователь со своим списком задач, меток и проектов
+type User struct {
+	ID        string `json:"id" db:"id"`
+	Name      string `json:"name" db:"name"`
+	Admin     bool   `json:"admin,omitempty" db:"admin"`
+	CreatedAt string `json:"created_at,omitempty" db:"created_at"`
+	// Password пароль нового пользователя, в ответах не возвращается
+	Password string `json:"password,omitempty" db:"-"`
+}
+type Users struct {
+	Users []User `json:"users"`
+}
+
+// FeedToken токен подписки на календарь пользователя и адрес подписки с ним
+type FeedToken struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
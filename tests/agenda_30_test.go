@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// agendaIDs возвращает количество появлений задачи id в каждой корзине сводки
+func agendaIDs(t *testing.T, ret map[string]any, id string) map[string]int {
+	result := map[string]int{}
+	for _, name := range []string{"overdue", "today", "tomorrow", "week", "later"} {
+		bucket, _ := ret[name].(map[string]any)
+		tasks, _ := bucket["tasks"].([]any)
+		assert.Equal(t, float64(len(tasks)), bucket["count"], name)
+		for _, item := range tasks {
+			if fmt.Sprint(item.(map[string]any)["id"]) == id {
+				result[name]++
+			}
+		}
+	}
+	return result
+}
+
+func TestAgenda(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	status, ret := userRequest(t, Token, "api/agenda", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status, ret)
+	today, err := time.Parse(`20060102`, fmt.Sprint(ret["date"]))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ids := []string{}
+	defer func() {
+		for _, id := range ids {
+			_, err := db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+			assert.NoError(t, err)
+		}
+	}()
+	add := func(date time.Time, repeat string) string {
+		status, ret := userRequest(t, Token, "api/task", map[string]any{
+			"date": date.Format(`20060102`), "title": "Сводка", "repeat": repeat,
+		}, http.MethodPost)
+		assert.Equal(t, http.StatusCreated, status, ret)
+		id := fmt.Sprint(ret["id"])
+		ids = append(ids, id)
+		return id
+	}
+
+	// Просроченная повторяющаяся задача не дублируется в "сегодня" и "на неделе"
+	overdue := add(today.AddDate(0, 0, 1), "d 1")
+	_, err = db.Exec(`UPDATE scheduler SET date = ? WHERE id = ?`, today.AddDate(0, 0, -3).Format(`20060102`), overdue)
+	assert.NoError(t, err)
+	dueToday := add(today, "")
+	dueTomorrow := add(today.AddDate(0, 0, 1), "")
+	dueLater := add(today.AddDate(0, 0, 9), "")
+
+	status, ret = userRequest(t, Token, "api/agenda", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, map[string]int{"overdue": 1}, agendaIDs(t, ret, overdue))
+	assert.Equal(t, map[string]int{"today": 1}, agendaIDs(t, ret, dueToday))
+	assert.Equal(t, map[string]int{"tomorrow": 1}, agendaIDs(t, ret, dueTomorrow))
+	assert.Equal(t, map[string]int{"later": 1}, agendaIDs(t, ret, dueLater))
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// notificationKinds возвращает виды уведомлений пользователя о задаче id с ID больше since
+// и ID последнего уведомления
+func notificationKinds(t *testing.T, token, id, since string) ([]string, string) {
+	status, ret := userRequest(t, token, "api/notifications?since="+since, nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	kinds := []string{}
+	list, _ := ret["notifications"].([]any)
+	for _, item := range list {
+		m := item.(map[string]any)
+		since = fmt.Sprint(m["id"])
+		if fmt.Sprint(m["task_id"]) == id {
+			kinds = append(kinds, fmt.Sprint(m["kind"]))
+		}
+	}
+	return kinds, since
+}
+
+func TestAssignments(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	suffix := time.Now().Format("150405.000000")
+	lead := "assign-lead-" + suffix
+	dev := "assign-dev-" + suffix
+	defer func() {
+		for _, query := range []string{
+			`DELETE FROM notifications WHERE user_id IN (SELECT id FROM users WHERE name IN (?, ?))`,
+			`DELETE FROM scheduler WHERE user_id IN (SELECT id FROM users WHERE name IN (?, ?))`,
+			`DELETE FROM users WHERE name IN (?, ?)`,
+		} {
+			_, err := db.Exec(query, lead, dev)
+			assert.NoError(t, err)
+		}
+	}()
+	for _, name := range []string{lead, dev} {
+		ret, err := postJSON("api/user", map[string]any{"name": name, "password": "secret-" + name}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotNil(t, ret["id"], "регистрация пользователя %s", name)
+	}
+	leadToken := signIn(t, lead, "secret-"+lead)
+	devToken := signIn(t, dev, "secret-"+dev)
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	status, ret := userRequest(t, leadToken, "api/task", map[string]any{"date": date, "title": "Подготовить отчёт"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	id := fmt.Sprint(ret["id"])
+	assign := func(token, user string) (int, map[string]any) {
+		return userRequest(t, token, "api/task/assign?id="+id+"&user="+url.QueryEscape(user), nil, http.MethodPost)
+	}
+
+	status, _ = assign(leadToken, "nobody-"+suffix)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = assign(leadToken, lead)
+	assert.Equal(t, http.StatusBadRequest, status, "назначение владельцу")
+
+	status, ret = assign(leadToken, dev)
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, dev, ret["assignee"])
+	assert.Equal(t, "pending", ret["assignee_status"])
+	kinds, devSince := notificationKinds(t, devToken, id, "0")
+	assert.Equal(t, []string{"assigned"}, kinds)
+
+	// Назначенная задача видна исполнителю только с отбором assigned=me
+	status, ret = userRequest(t, devToken, "api/tasks?assigned=me", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	if tasks, _ := ret["tasks"].([]any); assert.Len(t, tasks, 1) {
+		assert.Equal(t, id, tasks[0].(map[string]any)["id"])
+	}
+	_, ret = userRequest(t, devToken, "api/tasks", nil, http.MethodGet)
+	assert.Empty(t, ret["tasks"])
+	status, _ = userRequest(t, devToken, "api/tasks?assigned=all", nil, http.MethodGet)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// До принятия задачу нельзя изменять, а назначать её может только владелец
+	status, ret = userRequest(t, devToken, "api/task", map[string]any{
+		"id": id, "date": date, "title": "Подготовить отчёт", "comment": "начал", "version": "2",
+	}, http.MethodPut)
+	assert.Equal(t, http.StatusForbidden, status, ret)
+	status, _ = assign(devToken, "")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = userRequest(t, leadToken, "api/task/accept?id="+id, nil, http.MethodPost)
+	assert.Equal(t, http.StatusForbidden, status, "принять задачу может только исполнитель")
+
+	status, ret = userRequest(t, devToken, "api/task/accept?id="+id, nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, "accepted", ret["assignee_status"])
+	status, _ = userRequest(t, devToken, "api/task/accept?id="+id, nil, http.MethodPost)
+	assert.Equal(t, http.StatusConflict, status)
+	status, ret = userRequest(t, devToken, "api/task", map[string]any{
+		"id": id, "date": date, "title": "Подготовить отчёт", "comment": "начал", "version": "3",
+	}, http.MethodPut)
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, dev, ret["assignee"], "изменение задачи не снимает назначение")
+
+	status, ret = userRequest(t, devToken, "api/task/decline?id="+id, nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, "declined", ret["assignee_status"])
+	_, ret = userRequest(t, devToken, "api/task?id="+id, nil, http.MethodGet)
+	assert.NotNil(t, ret["error"], "после отказа задача не видна исполнителю")
+	kinds, _ = notificationKinds(t, leadToken, id, "0")
+	assert.Equal(t, []string{"accepted", "declined"}, kinds)
+
+	// Повторное назначение и снятие назначения
+	status, _ = assign(leadToken, dev)
+	assert.Equal(t, http.StatusOK, status)
+	status, ret = assign(leadToken, "")
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Nil(t, ret["assignee"])
+	kinds, _ = notificationKinds(t, devToken, id, devSince)
+	assert.Equal(t, []string{"assigned", "unassigned"}, kinds)
+
+	status, ret = userRequest(t, leadToken, "api/audit?task_id="+id, nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	actions := map[string]int{}
+	entries, _ := ret["entries"].([]any)
+	for _, entry := range entries {
+		actions[fmt.Sprint(entry.(map[string]any)["action"])]++
+	}
+	assert.Equal(t, 3, actions["assign"])
+	assert.Equal(t, 1, actions["accept"])
+	assert.Equal(t, 1, actions["decline"])
+
+	// Уведомления удаляются вместе с задачей при очистке корзины
+	status, _ = userRequest(t, leadToken, "api/task?id="+id, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = userRequest(t, leadToken, "api/trash?id="+id, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, status)
+	kinds, _ = notificationKinds(t, devToken, id, "0")
+	assert.Empty(t, kinds)
+	var notifications int
+	assert.NoError(t, db.Get(&notifications, `SELECT count(*) FROM notifications WHERE task_id = ?`, id))
+	assert.Equal(t, 0, notifications)
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type attachment struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Error       string `json:"error"`
+}
+
+// uploadAttachment загружает файл к задаче в поле file формы multipart/form-data от имени пользователя с токеном token
+func uploadAttachment(t *testing.T, token, id, name string, data []byte) (int, attachment) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", name)
+	assert.NoError(t, err)
+	_, err = part.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, form.Close())
+
+	resp, respBody := sendRequest(t, token, "api/task/attachment?id="+id, body.Bytes(), http.MethodPost,
+		map[string]string{"Content-Type": form.FormDataContentType()})
+	var ret attachment
+	assert.NoError(t, json.Unmarshal(respBody, &ret), string(respBody))
+	return resp.StatusCode, ret
+}
+
+func TestAttachments(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	id := addTask(t, task{date: date, title: "Задача со сканом"})
+
+	pdf := []byte("%PDF-1.4\n% скан договора\n")
+	status, scan := uploadAttachment(t, Token, id, `C:\scans\договор.pdf`, pdf)
+	assert.Equal(t, http.StatusCreated, status, scan.Error)
+	assert.Equal(t, "договор.pdf", scan.Name)
+	assert.Equal(t, "application/pdf", scan.ContentType)
+	assert.Equal(t, int64(len(pdf)), scan.Size)
+
+	// Тип содержимого определяется по самому файлу, а не по расширению
+	status, page := uploadAttachment(t, Token, id, "page.pdf", []byte("<html><script>alert(1)</script></html>"))
+	assert.Equal(t, http.StatusCreated, status, page.Error)
+	assert.Equal(t, "text/html; charset=utf-8", page.ContentType)
+
+	status, _ = uploadAttachment(t, Token, id, "big.bin", make([]byte, 11<<20))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+	status, _ = uploadAttachment(t, Token, "999999", "a.pdf", pdf)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	resp, body := sendRequest(t, Token, "api/task/attachment?attachment="+scan.ID, nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, pdf, body)
+	assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+
+	body, err := requestJSON("api/task/attachments?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var list struct {
+		Attachments []attachment `json:"attachments"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &list))
+	assert.Len(t, list.Attachments, 2)
+
+	ret, err := postJSON("api/task/attachment?attachment="+page.ID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	resp, _ = sendRequest(t, Token, "api/task/attachment?attachment="+page.ID, nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Вложения остаются у задачи в корзине и удаляются вместе с ней
+	ret, err = postJSON("api/trash?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"], "задача не в корзине")
+	ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	resp, _ = sendRequest(t, Token, "api/task/attachment?attachment="+scan.ID, nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ret, err = postJSON("api/trash?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	resp, _ = sendRequest(t, Token, "api/task/attachment?attachment="+scan.ID, nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	var count int
+	assert.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM attachments WHERE task_id = ?`, id))
+	assert.Equal(t, 0, count)
+}
@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// auditEntries возвращает записи журнала аудита по запросу query, начиная с первых
+func auditEntries(t *testing.T, query string) []map[string]any {
+	status, ret := userRequest(t, Token, "api/audit?"+query, nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status, ret)
+	list, _ := ret["entries"].([]any)
+	entries := make([]map[string]any, len(list))
+	for i, item := range list {
+		entries[len(list)-1-i] = item.(map[string]any)
+	}
+	return entries
+}
+
+// auditSnapshot возвращает поле field снимка задачи snapshot или nil, если снимка нет
+func auditSnapshot(entry map[string]any, snapshot, field string) any {
+	task, ok := entry[snapshot].(map[string]any)
+	if !ok {
+		return nil
+	}
+	return task[field]
+}
+
+func TestAuditLog(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	status, ret := userRequest(t, Token, "api/task", map[string]any{"date": today, "title": "Журнал", "repeat": "d 1"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	id := fmt.Sprint(ret["id"])
+	// Журнал аудита только дополняется, поэтому его записи остаются после теста
+	defer func() {
+		for _, query := range []string{
+			`DELETE FROM completions WHERE task_id = ?`,
+			`DELETE FROM scheduler WHERE id = ?`,
+		} {
+			_, err := db.Exec(query, id)
+			assert.NoError(t, err)
+		}
+	}()
+
+	status, ret = userRequest(t, Token, "api/task", map[string]any{
+		"id": id, "date": today, "title": "Журнал изменён", "repeat": "d 1", "version": "*",
+	}, http.MethodPut)
+	assert.Equal(t, http.StatusOK, status, ret)
+	status, ret = userRequest(t, Token, "api/task/done?id="+id, nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+	status, ret = userRequest(t, Token, "api/task?id="+id, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, status, ret)
+	status, ret = userRequest(t, Token, "api/task/restore?id="+id, nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+
+	entries := auditEntries(t, "task_id="+id)
+	actions := []string{}
+	for _, entry := range entries {
+		actions = append(actions, fmt.Sprint(entry["action"]))
+		assert.Equal(t, id, entry["task_id"])
+		assert.Equal(t, "admin", entry["actor"])
+		assert.NotEmpty(t, entry["created_at"])
+	}
+	if !assert.Equal(t, []string{"insert", "update", "done", "delete", "restore"}, actions) {
+		return
+	}
+
+	// Снимки задачи до и после каждого изменения
+	insert, update, done, del, restore := entries[0], entries[1], entries[2], entries[3], entries[4]
+	assert.Nil(t, insert["old"])
+	assert.Equal(t, "Журнал", auditSnapshot(insert, "new", "title"))
+
+	assert.Equal(t, "Журнал", auditSnapshot(update, "old", "title"))
+	assert.Equal(t, "Журнал изменён", auditSnapshot(update, "new", "title"))
+	assert.Equal(t, auditSnapshot(insert, "new", "version"), auditSnapshot(update, "old", "version"))
+
+	assert.Equal(t, today, auditSnapshot(done, "old", "date"))
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), auditSnapshot(done, "new", "date"))
+	assert.Equal(t, auditSnapshot(update, "new", "version"), auditSnapshot(done, "old", "version"))
+
+	assert.Equal(t, "Журнал изменён", auditSnapshot(del, "old", "title"))
+	assert.Nil(t, del["new"])
+
+	assert.NotEmpty(t, auditSnapshot(restore, "old", "deleted_at"))
+	assert.Empty(t, auditSnapshot(restore, "new", "deleted_at"))
+	assert.Equal(t, "Журнал изменён", auditSnapshot(restore, "new", "title"))
+	assert.NotEqual(t, auditSnapshot(restore, "old", "version"), auditSnapshot(restore, "new", "version"))
+	_, ret = userRequest(t, Token, "api/task?id="+id, nil, http.MethodGet)
+	assert.Equal(t, ret["version"], auditSnapshot(restore, "new", "version"))
+
+	// Фильтры по действию, автору и дате
+	filtered := auditEntries(t, "task_id="+id+"&action=update")
+	if assert.Len(t, filtered, 1) {
+		assert.Equal(t, update["id"], filtered[0]["id"])
+	}
+	assert.Empty(t, auditEntries(t, "task_id="+id+"&actor=caldav"))
+	assert.Len(t, auditEntries(t, "task_id="+id+"&from="+today+"&to="+today), 5)
+	assert.Empty(t, auditEntries(t, "task_id="+id+"&to="+now.AddDate(0, 0, -1).Format(`20060102`)))
+	assert.Len(t, auditEntries(t, "task_id="+id+"&limit=2"), 2)
+
+	// Снимки хранятся в журнале как JSON
+	var stored string
+	assert.NoError(t, db.Get(&stored, `SELECT new_json FROM audit WHERE task_id = ? AND action = 'insert'`, id))
+	var task map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(stored), &task))
+	assert.Equal(t, "Журнал", task["title"])
+
+	for _, query := range []string{"limit=0", "limit=abc", "from=2024-01-01"} {
+		status, ret = userRequest(t, Token, "api/audit?"+query, nil, http.MethodGet)
+		assert.Equal(t, http.StatusBadRequest, status, query)
+		assert.NotNil(t, ret["error"], query)
+	}
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+// restoreDB загружает файл базы data в поле file формы для восстановления
+func restoreDB(t *testing.T, data []byte) (int, map[string]any) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "scheduler.db")
+	assert.NoError(t, err)
+	_, err = part.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, form.Close())
+
+	resp, respBody := sendRequest(t, Token, "api/admin/restore", body.Bytes(), http.MethodPost,
+		map[string]string{"Content-Type": form.FormDataContentType()})
+	var ret map[string]any
+	assert.NoError(t, json.Unmarshal(respBody, &ret), string(respBody))
+	return resp.StatusCode, ret
+}
+
+// oldSchemaDB создаёт базу в исходной схеме без миграций с одной задачей title и возвращает её содержимое.
+// Запрос extra выполняется после создания таблицы
+func oldSchemaDB(t *testing.T, title, extra string) []byte {
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	old, err := sqlx.Connect("sqlite3", path)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	_, err = old.Exec(`CREATE TABLE scheduler (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		date TEXT NOT NULL,
+		title TEXT NOT NULL,
+		comment TEXT,
+		repeat TEXT(128)
+	);` + extra)
+	assert.NoError(t, err)
+	_, err = old.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, '', '')`,
+		time.Now().Format(`20060102`), title)
+	assert.NoError(t, err)
+	assert.NoError(t, old.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return data
+}
+
+// taskTitles возвращает названия задач администратора
+func taskTitles(t *testing.T) []string {
+	status, ret := userRequest(t, Token, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status, "запрос с токеном после восстановления")
+	titles := []string{}
+	tasks, _ := ret["tasks"].([]any)
+	for _, task := range tasks {
+		titles = append(titles, fmt.Sprint(task.(map[string]any)["title"]))
+	}
+	return titles
+}
+
+func TestBackupRestore(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+	var schema int
+	assert.NoError(t, db.Get(&schema, "PRAGMA user_version"))
+
+	resp, backup := sendRequest(t, Token, "api/admin/backup", nil, http.MethodGet, nil)
+	if !assert.Equal(t, http.StatusOK, resp.StatusCode) {
+		return
+	}
+	// После теста возвращаем базу, с которой работают остальные тесты
+	defer func() {
+		status, ret := restoreDB(t, backup)
+		assert.Equal(t, http.StatusOK, status, ret)
+	}()
+
+	// Восстановление отменяет изменения, сделанные после снятия копии
+	ret, err := postJSON("api/task", map[string]any{"date": time.Now().Format(`20060102`), "title": "После копии"},
+		http.MethodPost)
+	assert.NoError(t, err)
+	id := fmt.Sprint(ret["id"])
+	status, ret := restoreDB(t, backup)
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, float64(schema), ret["schema_version"])
+	assert.NotEmpty(t, ret["snapshot"])
+	_, ret = userRequest(t, Token, "api/task?id="+id, nil, http.MethodGet)
+	assert.NotNil(t, ret["error"], "задача, добавленная после копии")
+
+	// Токен, отозванный после снятия копии, остаётся отозванным после восстановления
+	revoked := signIn(t, "", Password)
+	status, ret = userRequest(t, revoked, "api/signout", nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+	status, ret = restoreDB(t, backup)
+	assert.Equal(t, http.StatusOK, status, ret)
+	status, _ = userRequest(t, revoked, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, status, "отозванный токен после восстановления")
+
+	// Копия старой схемы обновляется до текущей
+	status, ret = restoreDB(t, oldSchemaDB(t, "Старая задача", ""))
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, float64(0), ret["schema_version"])
+	var version int
+	assert.NoError(t, db.Get(&version, "PRAGMA user_version"))
+	assert.Equal(t, schema, version)
+	assert.Equal(t, []string{"Старая задача"}, taskTitles(t))
+
+	// Если копию не удалось обновить, текущая база не меняется
+	status, ret = restoreDB(t, oldSchemaDB(t, "Сломанная задача", `ALTER TABLE scheduler ADD COLUMN deleted_at TEXT;`))
+	assert.Equal(t, http.StatusBadRequest, status, ret)
+	assert.NotNil(t, ret["error"])
+	assert.Equal(t, []string{"Старая задача"}, taskTitles(t))
+}
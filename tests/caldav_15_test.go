@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// caldavRequest выполняет запрос клиента CalDAV с паролем в заголовке Authorization
+func caldavRequest(t *testing.T, method, apipath, body string, headers map[string]string) (*http.Response, string) {
+	req, err := http.NewRequest(method, getURL(apipath), strings.NewReader(body))
+	assert.NoError(t, err)
+	req.SetBasicAuth("admin", Password)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp, string(data)
+}
+
+func TestCalDAV(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	todo := func(status string) string {
+		return strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"VERSION:2.0",
+			"BEGIN:VTODO",
+			"UID:caldav-test-uid",
+			"SUMMARY:Задача CalDAV",
+			"DUE;VALUE=DATE:20991010",
+			"RRULE:FREQ=DAILY;INTERVAL=2",
+			"STATUS:" + status,
+			"END:VTODO",
+			"END:VCALENDAR",
+			"",
+		}, "\r\n")
+	}
+	path := "caldav/tasks/caldav-test-uid.ics"
+
+	resp, _ := caldavRequest(t, http.MethodPut, path, todo("NEEDS-ACTION"), map[string]string{"If-None-Match": "*"})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	created := resp.Header.Get("ETag")
+	assert.NotEmpty(t, created)
+
+	var task Task
+	err := db.Get(&task, `SELECT * FROM scheduler WHERE title = ?`, "Задача CalDAV")
+	assert.NoError(t, err)
+	assert.Equal(t, "20991010", task.Date)
+	assert.Equal(t, "d 2", task.Repeat)
+
+	resp, body := caldavRequest(t, "PROPFIND", "caldav/tasks/", "", map[string]string{"Depth": "1"})
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Contains(t, body, "<d:href>/caldav/tasks/caldav-test-uid.ics</d:href>")
+
+	resp, body = caldavRequest(t, http.MethodGet, path, "", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, created, resp.Header.Get("ETag"))
+	assert.Contains(t, body, "UID:caldav-test-uid\r\n")
+	assert.Contains(t, body, "RRULE:FREQ=DAILY;INTERVAL=2\r\n")
+
+	// Выполнение повторяющейся задачи переносит её на следующую дату
+	resp, _ = caldavRequest(t, http.MethodPut, path, todo("COMPLETED"), map[string]string{"If-Match": created})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.NotEqual(t, created, resp.Header.Get("ETag"))
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id = ?`, task.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "20991012", task.Date)
+	assert.False(t, task.Done)
+
+	resp, _ = caldavRequest(t, http.MethodPut, path, todo("COMPLETED"), map[string]string{"If-Match": created})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _ = caldavRequest(t, http.MethodDelete, path, "", nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = caldavRequest(t, http.MethodGet, path, "", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	req, err := http.NewRequest("PROPFIND", getURL("caldav/tasks/"), nil)
+	assert.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, err = db.Exec(`DELETE FROM caldav_resources WHERE task_id = ?`, task.ID)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM completions WHERE task_id = ?`, task.ID)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, task.ID)
+	assert.NoError(t, err)
+}
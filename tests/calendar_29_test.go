@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rangeDates возвращает даты повторений задач ids в ответе со списком задач
+func rangeDates(tasks []any, ids ...string) map[string][]string {
+	result := map[string][]string{}
+	for _, id := range ids {
+		result[id] = []string{}
+	}
+	for _, item := range tasks {
+		task := item.(map[string]any)
+		id := fmt.Sprint(task["id"])
+		if _, ok := result[id]; ok {
+			result[id] = append(result[id], fmt.Sprint(task["date"]))
+		}
+	}
+	return result
+}
+
+func TestTasksRange(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	add := func(date, title, repeat string) string {
+		status, ret := userRequest(t, Token, "api/task", map[string]any{"date": date, "title": title, "repeat": repeat}, http.MethodPost)
+		assert.Equal(t, http.StatusCreated, status, ret)
+		return fmt.Sprint(ret["id"])
+	}
+	// Задачи на 2030 год, чтобы дата добавления не переносила их на сегодня
+	weekly := add("20300107", "По понедельникам и четвергам", "w 1,4")
+	earlier := add("20291225", "Раз в неделю с прошлого года", "d 7")
+	first := add("20300101", "Первый день диапазона", "")
+	last := add("20300120", "Последний день диапазона", "")
+	after := add("20300121", "После диапазона", "")
+	ids := []string{weekly, earlier, first, last, after}
+	defer func() {
+		for _, id := range ids {
+			_, err := db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+			assert.NoError(t, err)
+		}
+	}()
+
+	// Повторения разворачиваются внутри диапазона, границы включаются в диапазон
+	status, ret := userRequest(t, Token, "api/tasks?from=20300101&to=20300120", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status, ret)
+	tasks, _ := ret["tasks"].([]any)
+	assert.Equal(t, map[string][]string{
+		weekly:  {"20300107", "20300110", "20300114", "20300117"},
+		earlier: {"20300101", "20300108", "20300115"},
+		first:   {"20300101"},
+		last:    {"20300120"},
+		after:   {},
+	}, rangeDates(tasks, ids...))
+	for i := 1; i < len(tasks); i++ {
+		prev, cur := tasks[i-1].(map[string]any), tasks[i].(map[string]any)
+		assert.LessOrEqual(t, fmt.Sprint(prev["date"]), fmt.Sprint(cur["date"]), "задачи упорядочены по дате")
+	}
+
+	// Диапазон из одного дня
+	status, ret = userRequest(t, Token, "api/tasks?from=20300110&to=20300110", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status, ret)
+	tasks, _ = ret["tasks"].([]any)
+	assert.Equal(t, map[string][]string{weekly: {"20300110"}, earlier: {}}, rangeDates(tasks, weekly, earlier))
+
+	for _, query := range []string{
+		"from=20300120&to=20300101",
+		"from=20300101&to=20310105",
+		"from=2030-01-01&to=20300120",
+		"from=20300101",
+	} {
+		status, ret = userRequest(t, Token, "api/tasks?"+query, nil, http.MethodGet)
+		assert.Equal(t, http.StatusBadRequest, status, query)
+		assert.NotNil(t, ret["error"], query)
+	}
+}
+
+func TestCalendarMonth(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	status, ret := userRequest(t, Token, "api/task", map[string]any{"date": "20300131", "title": "Последний день месяца", "repeat": "m -1"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	id := fmt.Sprint(ret["id"])
+	defer func() {
+		_, err := db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+		assert.NoError(t, err)
+	}()
+
+	for month, want := range map[string]struct {
+		days int
+		date string
+	}{
+		"2030-01": {31, "20300131"},
+		"2030-02": {28, "20300228"},
+		"2032-02": {29, "20320229"},
+	} {
+		status, ret := userRequest(t, Token, "api/calendar?month="+month, nil, http.MethodGet)
+		assert.Equal(t, http.StatusOK, status, ret)
+		assert.Equal(t, month, ret["month"])
+		days, _ := ret["days"].([]any)
+		if !assert.Len(t, days, want.days, month) {
+			continue
+		}
+		// Задача попадает только в день своего повторения
+		found := []string{}
+		for _, item := range days {
+			day := item.(map[string]any)
+			tasks, _ := day["tasks"].([]any)
+			if dates := rangeDates(tasks, id)[id]; len(dates) > 0 {
+				assert.Equal(t, []string{fmt.Sprint(day["date"])}, dates)
+				found = append(found, fmt.Sprint(day["date"]))
+			}
+		}
+		assert.Equal(t, []string{want.date}, found, month)
+	}
+
+	status, _ = userRequest(t, Token, "api/calendar?month=2030-13", nil, http.MethodGet)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type checklistItem struct {
+	ID       string `json:"id"`
+	Text     string `json:"text"`
+	Position int    `json:"position"`
+	Checked  bool   `json:"checked"`
+}
+
+func getChecklist(t *testing.T, id string) []checklistItem {
+	body, err := requestJSON("api/task/checklist?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m struct {
+		Items []checklistItem `json:"items"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m), string(body))
+	return m.Items
+}
+
+func TestChecklist(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	id := addTask(t, task{date: date, title: "Подготовить релиз", repeat: "d 7"})
+
+	items := map[string]string{}
+	for _, text := range []string{"Собрать", "Проверить", "Выкатить"} {
+		ret, err := postJSON("api/task/checklist?id="+id, map[string]any{"text": text}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, text, ret["text"])
+		items[text] = fmt.Sprint(ret["id"])
+	}
+	ret, err := postJSON("api/task/checklist?id="+id, map[string]any{"text": " "}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"])
+
+	ret, err = postJSON("api/task/checklist/check?item="+items["Собрать"], nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, true, ret["checked"])
+
+	// Новый порядок должен содержать все пункты
+	ret, err = postJSON("api/task/checklist/reorder?id="+id, map[string]any{
+		"items": []string{items["Выкатить"], items["Собрать"]},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"])
+	_, err = postJSON("api/task/checklist/reorder?id="+id, map[string]any{
+		"items": []string{items["Проверить"], items["Собрать"], items["Выкатить"]},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+
+	list := getChecklist(t, id)
+	if assert.Len(t, list, 3) {
+		assert.Equal(t, "Проверить", list[0].Text)
+		assert.Equal(t, "Собрать", list[1].Text)
+		assert.True(t, list[1].Checked)
+		assert.Equal(t, 3, list[2].Position)
+	}
+
+	// Следующее повторение начинается с неотмеченного чек-листа
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	for _, item := range getChecklist(t, id) {
+		assert.False(t, item.Checked, item.Text)
+	}
+
+	ret, err = postJSON("api/task/checklist?item="+items["Проверить"], nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	list = getChecklist(t, id)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "Собрать", list[0].Text)
+		assert.Equal(t, 1, list[0].Position)
+		assert.Equal(t, 2, list[1].Position)
+	}
+
+	_, err = db.Exec(`DELETE FROM checklist WHERE task_id = ?`, id)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// completedIDs возвращает ID задач из ответа /api/completed за период [from, to]
+func completedIDs(t *testing.T, token, from, to string) []string {
+	status, ret := userRequest(t, token, "api/completed?from="+from+"&to="+to, nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status, ret)
+	ids := []string{}
+	list, _ := ret["completions"].([]any)
+	for _, item := range list {
+		ids = append(ids, fmt.Sprint(item.(map[string]any)["task_id"]))
+	}
+	return ids
+}
+
+func TestCompletions(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	// Отдельный пользователь, чтобы в выборку не попали выполнения из других тестов
+	name := "completions-" + time.Now().Format("150405.000000")
+	defer func() {
+		for _, query := range []string{
+			`DELETE FROM completions WHERE task_id IN (SELECT s.id FROM scheduler s JOIN users u ON u.id = s.user_id WHERE u.name = ?)`,
+			`DELETE FROM scheduler WHERE user_id IN (SELECT id FROM users WHERE name = ?)`,
+			`DELETE FROM users WHERE name = ?`,
+		} {
+			_, err := db.Exec(query, name)
+			assert.NoError(t, err)
+		}
+	}()
+	ret, err := postJSON("api/user", map[string]any{"name": name, "password": "secret-" + name}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["id"])
+	token := signIn(t, name, "secret-"+name)
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	yesterday := now.AddDate(0, 0, -1).Format(`20060102`)
+	tomorrow := now.AddDate(0, 0, 1).Format(`20060102`)
+
+	status, ret := userRequest(t, token, "api/task", map[string]any{"date": today, "title": "Зарядка", "repeat": "d 2"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	repeating := fmt.Sprint(ret["id"])
+	status, ret = userRequest(t, token, "api/task", map[string]any{"date": today, "title": "Позвонить"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	once := fmt.Sprint(ret["id"])
+
+	// Каждое выполнение повторяющейся задачи записывается с датой выполненного повторения
+	for i := 0; i < 2; i++ {
+		status, ret = userRequest(t, token, "api/task/done?id="+repeating, nil, http.MethodPost)
+		assert.Equal(t, http.StatusOK, status, ret)
+	}
+	second := now.AddDate(0, 0, 2).Format(`20060102`)
+	assert.Equal(t, []string{second, today}, historyDates(t, token, repeating))
+	_, ret = userRequest(t, token, "api/task?id="+repeating, nil, http.MethodGet)
+	assert.Equal(t, now.AddDate(0, 0, 4).Format(`20060102`), ret["date"])
+
+	// Разовая задача остаётся выполненной, а не удаляется
+	status, ret = userRequest(t, token, "api/task/done?id="+once, nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+	assert.Equal(t, []string{today}, historyDates(t, token, once))
+	var done bool
+	assert.NoError(t, db.Get(&done, `SELECT done FROM scheduler WHERE id = ? AND deleted_at = ''`, once))
+	assert.True(t, done)
+	status, ret = userRequest(t, token, "api/task/done?id="+once, nil, http.MethodPost)
+	assert.NotEqual(t, http.StatusOK, status, ret)
+	assert.Equal(t, []string{today}, historyDates(t, token, once))
+
+	// Выполнения попадают в период по моменту отметки, а не по дате задачи
+	assert.Equal(t, []string{repeating, repeating, once}, completedIDs(t, token, today, today))
+	assert.Equal(t, []string{repeating, repeating, once}, completedIDs(t, token, yesterday, tomorrow))
+	assert.Empty(t, completedIDs(t, token, yesterday, yesterday))
+	assert.Empty(t, completedIDs(t, token, tomorrow, tomorrow))
+
+	// История и выполненные задачи видны только владельцу
+	assert.Empty(t, historyDates(t, Token, repeating))
+	assert.NotContains(t, completedIDs(t, Token, today, today), repeating)
+
+	for _, query := range []string{
+		"api/task/history?id=",
+		"api/task/history?id=abc",
+		"api/completed?from=" + tomorrow + "&to=" + today,
+		"api/completed?from=" + today,
+	} {
+		status, ret = userRequest(t, token, query, nil, http.MethodGet)
+		assert.Equal(t, http.StatusBadRequest, status, query)
+		assert.NotNil(t, ret["error"], query)
+	}
+}
@@ -16,6 +16,16 @@ type Task struct {
 	Title   string `db:"title"`
 	Comment string `db:"comment"`
 	Repeat  string `db:"repeat"`
+
+	DeletedAt string `db:"deleted_at"`
+	Done      bool   `db:"done"`
+	Version   int64  `db:"version"`
+	Priority  int    `db:"priority"`
+	ProjectID int64  `db:"project_id"`
+	UserID    int64  `db:"user_id"`
+
+	AssigneeID     int64  `db:"assignee_id"`
+	AssigneeStatus string `db:"assignee_status"`
 }
 
 func count(db *sqlx.DB) (int, error) {
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type graphResponse struct {
+	Nodes []struct {
+		ID      string `json:"id"`
+		Done    bool   `json:"done"`
+		Blocked bool   `json:"blocked"`
+	} `json:"nodes"`
+	Edges []struct {
+		TaskID    string `json:"task_id"`
+		BlockedBy string `json:"blocked_by"`
+	} `json:"edges"`
+}
+
+func setBlockers(t *testing.T, id, date string, blockers ...string) map[string]any {
+	ret, err := postJSON("api/task", map[string]any{
+		"id": id, "date": date, "title": "Зависимость " + id, "version": "*", "blocked_by": append([]string{}, blockers...),
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	return ret
+}
+
+func TestDependencies(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	design := addTask(t, task{date: date, title: "Макет"})
+	build := addTask(t, task{date: date, title: "Вёрстка"})
+	release := addTask(t, task{date: date, title: "Релиз"})
+
+	ret := setBlockers(t, build, date, design)
+	assert.Nil(t, ret["error"])
+	assert.Equal(t, true, ret["blocked"])
+	assert.Equal(t, []any{design}, ret["blocked_by"])
+	ret = setBlockers(t, release, date, build, build)
+	assert.Nil(t, ret["error"])
+	assert.Equal(t, []any{build}, ret["blocked_by"])
+
+	// Циклы запрещены, в том числе зависимость задачи от самой себя
+	ret = setBlockers(t, design, date, release)
+	assert.Contains(t, ret["error"], "cycle")
+	ret = setBlockers(t, design, date, design)
+	assert.Contains(t, ret["error"], "cycle")
+	ret = setBlockers(t, design, date, "999999")
+	assert.NotNil(t, ret["error"])
+
+	// Повторяющаяся задача не может блокировать другие, а блокирующей задаче нельзя задать повтор
+	daily := addTask(t, task{date: date, title: "Планёрка", repeat: "d 1"})
+	ret = setBlockers(t, release, date, build, daily)
+	assert.Contains(t, ret["error"], "repeating")
+	ret, err := postJSON("api/task", map[string]any{
+		"id": design, "date": date, "title": "Макет", "repeat": "d 1", "version": "*",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Contains(t, ret["error"], "repeating")
+
+	body, err := requestJSON("api/task/graph", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var graph graphResponse
+	assert.NoError(t, json.Unmarshal(body, &graph), string(body))
+	blocked := map[string]bool{}
+	for _, node := range graph.Nodes {
+		blocked[node.ID] = node.Blocked
+	}
+	assert.Equal(t, map[string]bool{design: false, build: true, release: true},
+		map[string]bool{design: blocked[design], build: blocked[build], release: blocked[release]})
+	edges := map[string]string{}
+	for _, edge := range graph.Edges {
+		edges[edge.TaskID] = edge.BlockedBy
+	}
+	assert.Equal(t, design, edges[build])
+	assert.Equal(t, build, edges[release])
+
+	resp, body := sendRequest(t, Token, "api/task/done?id="+build, nil, http.MethodPost, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode, string(body))
+
+	ret, err = postJSON("api/task/done?id="+design, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	body, err = requestJSON("api/task?id="+build, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var task map[string]any
+	assert.NoError(t, json.Unmarshal(body, &task))
+	assert.Nil(t, task["blocked"])
+	assert.Equal(t, "3", task["version"], "выполнение блокирующей задачи меняет версию")
+
+	// С force=true задача выполняется, даже если заблокирована
+	ret, err = postJSON("api/task/done?id="+release+"&force=true", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	// Без поля blocked_by зависимости не меняются, пустой список их снимает
+	ret, err = postJSON("api/task", map[string]any{
+		"id": build, "date": date, "title": "Вёрстка", "version": "*",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{design}, ret["blocked_by"])
+	ret = setBlockers(t, build, date)
+	assert.Nil(t, ret["blocked_by"])
+
+	_, err = db.Exec(`DELETE FROM task_deps WHERE task_id IN (?, ?, ?)`, design, build, release)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id IN (?, ?, ?, ?)`, design, build, release, daily)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// doneConcurrently отправляет n одновременных запросов на выполнение задачи
+// и возвращает количество успешных ответов
+func doneConcurrently(t *testing.T, n int, apipath string, headers map[string]string) int {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		success int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, _ := sendRequest(t, Token, apipath, nil, http.MethodPost, headers)
+			if resp.StatusCode == http.StatusOK {
+				mu.Lock()
+				success++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return success
+}
+
+func countCompletions(t *testing.T, id string) int {
+	db := openDB(t)
+	defer db.Close()
+
+	var count int
+	err := db.Get(&count, `SELECT count(id) FROM completions WHERE task_id = ?`, id)
+	assert.NoError(t, err)
+	return count
+}
+
+func TestDoneConcurrent(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	const requests = 10
+	now := time.Now()
+
+	// Каждый запрос без версии переносит повторяющуюся задачу ровно на один период
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Параллельное выполнение",
+		repeat: "d 2",
+	})
+	success := doneConcurrently(t, requests, "api/task/done?id="+id, nil)
+	assert.Equal(t, requests, success)
+
+	var stored Task
+	err := db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 2*requests).Format(`20060102`), stored.Date)
+	assert.Equal(t, int64(requests+1), stored.Version)
+	assert.Equal(t, requests, countCompletions(t, id))
+
+	// Из запросов с одной и той же версией задачу переносит только один
+	before, err := time.Parse(`20060102`, stored.Date)
+	assert.NoError(t, err)
+	success = doneConcurrently(t, requests, "api/task/done?id="+id,
+		map[string]string{"If-Match": fmt.Sprintf(`"%d"`, stored.Version)})
+	assert.Equal(t, 1, success)
+
+	err = db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, before.AddDate(0, 0, 2).Format(`20060102`), stored.Date)
+	assert.Equal(t, requests+1, countCompletions(t, id))
+
+	// Разовая задача выполняется только один раз
+	oneOff := addTask(t, task{
+		date:  now.Format(`20060102`),
+		title: "Разовое параллельное выполнение",
+	})
+	success = doneConcurrently(t, requests, "api/task/done?id="+oneOff, nil)
+	assert.Equal(t, 1, success)
+	assert.Equal(t, 1, countCompletions(t, oneOff))
+
+	for _, v := range []string{id, oneOff} {
+		_, err = db.Exec(`DELETE FROM completions WHERE task_id = ?`, v)
+		assert.NoError(t, err)
+		_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, v)
+		assert.NoError(t, err)
+	}
+}
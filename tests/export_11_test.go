@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type importReport struct {
+	DryRun  bool `json:"dry_run"`
+	Total   int  `json:"total"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	Skipped int  `json:"skipped"`
+	Failed  int  `json:"failed"`
+}
+
+func importTasks(t *testing.T, query string, tasks []map[string]any) importReport {
+	resp, body := sendRequest(t, Token, "api/import?format=json"+query,
+		jsonBody(t, map[string]any{"tasks": tasks}), http.MethodPost, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	var report importReport
+	assert.NoError(t, json.Unmarshal(body, &report))
+	return report
+}
+
+func TestExportImport(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 3).Format(`20060102`)
+	id := addTask(t, task{
+		date:  date,
+		title: "Задача для выгрузки",
+	})
+
+	resp, body := sendRequest(t, Token, "api/export?format=json", nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var export map[string][]map[string]any
+	err := json.Unmarshal(body, &export)
+	assert.NoError(t, err)
+	found := false
+	for _, v := range export["tasks"] {
+		if v["id"] == id {
+			found = true
+			assert.Equal(t, date, v["date"])
+		}
+	}
+	assert.True(t, found)
+
+	title := "Загруженная задача " + time.Now().Format(time.RFC3339Nano)
+	tasks := []map[string]any{
+		{"date": date, "title": title, "repeat": "d 3"},
+		{"date": date, "title": ""},
+		{"date": date, "title": "Плохой повтор", "repeat": "x 1"},
+	}
+
+	report := importTasks(t, "&dry_run=true", tasks)
+	assert.Equal(t, importReport{DryRun: true, Total: 3, Created: 1, Failed: 2}, report)
+	var cnt int
+	err = db.Get(&cnt, `SELECT count(id) FROM scheduler WHERE title = ?`, title)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+
+	report = importTasks(t, "", tasks)
+	assert.Equal(t, importReport{Total: 3, Created: 1, Failed: 2}, report)
+
+	tasks[0]["comment"] = "Новый комментарий"
+	report = importTasks(t, "", tasks[:1])
+	assert.Equal(t, importReport{Total: 1, Skipped: 1}, report)
+
+	report = importTasks(t, "&mode=overwrite", tasks[:1])
+	assert.Equal(t, importReport{Total: 1, Updated: 1}, report)
+	var comment string
+	err = db.Get(&comment, `SELECT comment FROM scheduler WHERE title = ?`, title)
+	assert.NoError(t, err)
+	assert.Equal(t, "Новый комментарий", comment)
+
+	// Пробная загрузка считает дубликаты внутри файла так же, как настоящая
+	twice := title + " дважды"
+	duplicated := []map[string]any{
+		{"date": date, "title": twice, "repeat": "d 3"},
+		{"date": date, "title": twice, "repeat": "d 3", "comment": "Повтор строки"},
+	}
+	report = importTasks(t, "&dry_run=true", duplicated)
+	assert.Equal(t, importReport{DryRun: true, Total: 2, Created: 1, Skipped: 1}, report)
+	report = importTasks(t, "&dry_run=true&mode=overwrite", duplicated)
+	assert.Equal(t, importReport{DryRun: true, Total: 2, Created: 1, Updated: 1}, report)
+	report = importTasks(t, "", duplicated)
+	assert.Equal(t, importReport{Total: 2, Created: 1, Skipped: 1}, report)
+
+	resp, _ = sendRequest(t, Token, "api/export?format=xml", nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ? OR title IN (?, ?)`, id, title, twice)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarFeed(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+	id := addTask(t, task{
+		date:   date,
+		title:  "Задача; для календаря",
+		repeat: "d 5",
+	})
+
+	resp, body := sendRequest(t, Token, "api/calendar.ics?token="+ICSToken, nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/calendar"))
+
+	feed := strings.ReplaceAll(string(body), "\r\n ", "")
+	assert.Contains(t, feed, "UID:task-"+id+"@go_final_project\r\n")
+	assert.Contains(t, feed, `SUMMARY:Задача\; для календаря`)
+	assert.Contains(t, feed, "DTSTART;VALUE=DATE:"+date)
+	assert.Contains(t, feed, "RRULE:FREQ=DAILY;INTERVAL=5")
+
+	resp, _ = sendRequest(t, Token, "api/calendar.ics?token=wrong", nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, err := db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+}
+
+func TestUserCalendarFeed(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	name := "feed-" + time.Now().Format("150405.000000")
+	defer func() {
+		for _, query := range []string{
+			`DELETE FROM feed_tokens WHERE user_id IN (SELECT id FROM users WHERE name = ?)`,
+			`DELETE FROM scheduler WHERE user_id IN (SELECT id FROM users WHERE name = ?)`,
+			`DELETE FROM users WHERE name = ?`,
+		} {
+			_, err := db.Exec(query, name)
+			assert.NoError(t, err)
+		}
+	}()
+	ret, err := postJSON("api/user", map[string]any{"name": name, "password": "secret-" + name}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["id"])
+	token := signIn(t, name, "secret-"+name)
+
+	date := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+	status, ret := userRequest(t, token, "api/task", map[string]any{"date": date, "title": "Своя подписка"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	own := addTask(t, task{date: date, title: "Задача администратора"})
+	defer db.Exec(`DELETE FROM scheduler WHERE id = ?`, own)
+
+	// feedToken создаёт пользователю новый токен подписки
+	feedToken := func() string {
+		resp, body := sendRequest(t, token, "api/calendar/token", nil, http.MethodPost, nil)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+		var feed struct {
+			Token string `json:"token"`
+			URL   string `json:"url"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &feed))
+		assert.NotEmpty(t, feed.Token)
+		assert.Equal(t, "/api/calendar.ics?token="+feed.Token, feed.URL)
+		return feed.Token
+	}
+
+	// Подписка по токену пользователя показывает только его задачи
+	first := feedToken()
+	resp, body := sendRequest(t, "", "api/calendar.ics?token="+first, nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "SUMMARY:Своя подписка")
+	assert.NotContains(t, string(body), "SUMMARY:Задача администратора")
+
+	// Новый токен отменяет прежний
+	second := feedToken()
+	assert.NotEqual(t, first, second)
+	resp, _ = sendRequest(t, "", "api/calendar.ics?token="+first, nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, _ = sendRequest(t, "", "api/calendar.ics?token="+second, nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = sendRequest(t, "", "api/calendar.ics", nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
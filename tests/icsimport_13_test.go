@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportICS(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTODO",
+		"UID:ics-import-1",
+		`SUMMARY:Импорт\, задача`,
+		`DESCRIPTION:Первая строка\nвторая`,
+		"DUE;VALUE=DATE:20991010",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,FR",
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"UID:ics-import-2",
+		"SUMMARY:Импорт события",
+		"DTSTART;VALUE=DATE:20991011",
+		"RRULE:FREQ=MONTHLY;BYDAY=1MO",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	resp, body := sendRequest(t, Token, "api/import/ics", []byte(calendar), http.MethodPost, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	var report struct {
+		Created int `json:"created"`
+		Failed  int `json:"failed"`
+		Errors  []struct {
+			Row int    `json:"row"`
+			Ref string `json:"ref"`
+		} `json:"errors"`
+	}
+	err := json.Unmarshal(body, &report)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Failed)
+	if assert.Len(t, report.Errors, 1) {
+		assert.Equal(t, 2, report.Errors[0].Row)
+		assert.Equal(t, "ics-import-2", report.Errors[0].Ref)
+	}
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE title = ?`, "Импорт, задача")
+	assert.NoError(t, err)
+	assert.Equal(t, "20991010", task.Date)
+	assert.Equal(t, "w 1,5", task.Repeat)
+	assert.Equal(t, "Первая строка\nвторая", task.Comment)
+
+	resp, _ = sendRequest(t, Token, "api/import/ics", []byte("not a calendar"), http.MethodPost, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, task.ID)
+	assert.NoError(t, err)
+}
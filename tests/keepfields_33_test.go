@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// projectTask создаёт проект name и задачу в нём с приоритетом 2 и возвращает ID проекта и задачи
+func projectTask(t *testing.T, name, title string) (string, string) {
+	status, ret := userRequest(t, Token, "api/project", map[string]any{"name": name}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	project := fmt.Sprint(ret["id"])
+	status, ret = userRequest(t, Token, "api/task", map[string]any{
+		"date": time.Now().AddDate(0, 0, 3).Format(`20060102`), "title": title, "project_id": project, "priority": 2,
+	}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	return project, fmt.Sprint(ret["id"])
+}
+
+// taskFields возвращает название, проект и приоритет задачи из базы
+func taskFields(t *testing.T, id string) (string, string, int) {
+	db := openDB(t)
+	defer db.Close()
+	var row struct {
+		Title     string `db:"title"`
+		ProjectID string `db:"project_id"`
+		Priority  int    `db:"priority"`
+	}
+	assert.NoError(t, db.Get(&row, `SELECT title, project_id, priority FROM scheduler WHERE id = ?`, id))
+	return row.Title, row.ProjectID, row.Priority
+}
+
+func TestUpdateKeepsProjectAndPriority(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	suffix := time.Now().Format("150405.000000")
+	syncProject, syncTask := projectTask(t, "sync-"+suffix, "Изменение через sync")
+	caldavProject, caldavTask := projectTask(t, "caldav-"+suffix, "Изменение через CalDAV")
+	defer func() {
+		for _, query := range []string{
+			`DELETE FROM caldav_resources WHERE task_id IN (?, ?)`,
+			`DELETE FROM scheduler WHERE id IN (?, ?)`,
+		} {
+			_, err := db.Exec(query, syncTask, caldavTask)
+			assert.NoError(t, err)
+		}
+		_, err := db.Exec(`DELETE FROM projects WHERE id IN (?, ?)`, syncProject, caldavProject)
+		assert.NoError(t, err)
+	}()
+
+	// Изменение через sync без project_id и priority не меняет их
+	_, ret := userRequest(t, Token, "api/task?id="+syncTask, nil, http.MethodGet)
+	resp, body := sendRequest(t, Token, "api/sync", []byte(fmt.Sprintf(`{"changes":[{"op":"update","task":
+		{"id":"%s","version":"%v","date":"%v","title":"Изменено через sync"}}]}`, syncTask, ret["version"], ret["date"])),
+		http.MethodPost, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Contains(t, string(body), `"status":"ok"`)
+	title, project, priority := taskFields(t, syncTask)
+	assert.Equal(t, "Изменено через sync", title)
+	assert.Equal(t, syncProject, project)
+	assert.Equal(t, 2, priority)
+
+	// Переданные поля по-прежнему меняются
+	_, ret = userRequest(t, Token, "api/task?id="+syncTask, nil, http.MethodGet)
+	resp, body = sendRequest(t, Token, "api/sync", []byte(fmt.Sprintf(`{"changes":[{"op":"update","task":
+		{"id":"%s","version":"%v","date":"%v","title":"Изменено через sync","priority":0,"project_id":""}}]}`,
+		syncTask, ret["version"], ret["date"])), http.MethodPost, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	_, project, priority = taskFields(t, syncTask)
+	assert.Equal(t, "0", project)
+	assert.Equal(t, 0, priority)
+
+	// PUT CalDAV с новым SUMMARY оставляет задачу в проекте и с прежним приоритетом
+	path := "caldav/tasks/" + caldavTask + ".ics"
+	resp, ics := caldavRequest(t, http.MethodGet, path, "", nil)
+	if !assert.Equal(t, http.StatusOK, resp.StatusCode) {
+		return
+	}
+	ics = strings.Replace(ics, "SUMMARY:Изменение через CalDAV", "SUMMARY:Изменено через CalDAV", 1)
+	resp, body2 := caldavRequest(t, http.MethodPut, path, ics, map[string]string{"If-Match": resp.Header.Get("ETag")})
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode, body2)
+	title, project, priority = taskFields(t, caldavTask)
+	assert.Equal(t, "Изменено через CalDAV", title)
+	assert.Equal(t, caldavProject, project)
+	assert.Equal(t, 2, priority)
+}
@@ -74,6 +74,18 @@ func TestNextDate(t *testing.T) {
 		{"20240126", "w 7", "20240128"},
 		{"20230126", "w 4,5", "20240201"},
 		{"20230226", "w 8,4,5", ""},
+		// Без дней недели или месяца правило неверно
+		{"20240126", "w ", ""},
+		{"20240126", "w a,b", ""},
+		{"20240126", "m ", ""},
+		{"20240126", "m x 1", ""},
+		// День месяца 0 не существует
+		{"20240126", "m 0", ""},
+		{"20240126", "m 0,5 1", ""},
+		// Дня нет ни в одном из месяцев правила
+		{"20240126", "m 30 2", ""},
+		{"20240126", "m 31 2,4,6,9,11", ""},
+		{"20240301", "m 29 2", "20280229"},
 	}
 	check()
 }
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriority(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	add := func(title string, days int, priority int) string {
+		ret, err := postJSON("api/task", map[string]any{
+			"date": now.AddDate(0, 0, days).Format(`20060102`), "title": title, "priority": priority,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Nil(t, ret["error"])
+		return fmt.Sprint(ret["id"])
+	}
+	later := add("Приоритет: потом", 1, 0)
+	low := add("Приоритет: низкий", 2, 4)
+	high := add("Приоритет: высокий", 3, 1)
+	overdue := add("Приоритет: просрочена", 0, 0)
+	// Дата в прошлом при создании переносится на сегодня, поэтому просрочиваем задачу напрямую
+	_, err := db.Exec(`UPDATE scheduler SET date = ? WHERE id = ?`, now.AddDate(0, 0, -1).Format(`20060102`), overdue)
+	assert.NoError(t, err)
+
+	ret, err := postJSON("api/task", map[string]any{"title": "Приоритет: неверный", "priority": 5}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"])
+
+	order := func(query string) []string {
+		body, err := requestJSON("api/tasks?search="+query, nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m struct {
+			Tasks []struct {
+				ID string `json:"id"`
+			} `json:"tasks"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &m))
+		ids := []string{}
+		for _, task := range m.Tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{overdue, later, low, high}, order("Приоритет:"))
+	assert.Equal(t, []string{overdue, high, low, later}, order("Приоритет:&order=smart"))
+
+	body, err := requestJSON("api/tasks?order=priority", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "error")
+
+	// Изменение без поля priority сохраняет приоритет
+	updated, err := postJSON("api/task", map[string]any{
+		"id": high, "date": now.AddDate(0, 0, 3).Format(`20060102`), "title": "Приоритет: высокий", "version": "1",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), updated["priority"])
+	updated, err = postJSON("api/task", map[string]any{
+		"id": high, "date": now.AddDate(0, 0, 3).Format(`20060102`), "title": "Приоритет: высокий", "version": "2",
+		"priority": 0,
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Nil(t, updated["priority"])
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id IN (?, ?, ?, ?)`, later, low, high, overdue)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func projectTaskIDs(t *testing.T, query string) []string {
+	body, err := requestJSON("api/tasks?"+query, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m struct {
+		Tasks []struct {
+			ID string `json:"id"`
+		} `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m), string(body))
+	ids := []string{}
+	for _, task := range m.Tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+func TestProjects(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	addProject := func(name string) string {
+		ret, err := postJSON("api/project", map[string]any{"name": name}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotNil(t, ret["id"])
+		return fmt.Sprint(ret["id"])
+	}
+	home := addProject("Дом")
+	work := addProject("Работа")
+	release := addProject("Релиз 2.3")
+
+	ret, err := postJSON("api/project", map[string]any{"name": "  "}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"])
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	addToProject := func(title, project string) string {
+		ret, err := postJSON("api/task", map[string]any{"date": date, "title": title, "project_id": project}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Nil(t, ret["error"])
+		return fmt.Sprint(ret["id"])
+	}
+	dishes := addToProject("Помыть посуду", home)
+	report := addToProject("Отчёт", work)
+	deploy := addToProject("Выкатить релиз", release)
+
+	ret, err = postJSON("api/task", map[string]any{"date": date, "title": "Без проекта", "project_id": "999999"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"])
+
+	assert.Equal(t, []string{dishes}, projectTaskIDs(t, "project_id="+home))
+	assert.Equal(t, []string{report}, projectTaskIDs(t, "project_id="+work))
+
+	// Изменение задачи без project_id оставляет её в проекте
+	updated, err := postJSON("api/task", map[string]any{
+		"id": report, "date": date, "title": "Квартальный отчёт", "version": "1",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, work, updated["project_id"])
+
+	// Задачи архивного проекта не видны в общем списке, но доступны по отбору
+	ret, err = postJSON("api/project/archive?id="+release, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["archived_at"])
+	assert.NotContains(t, projectTaskIDs(t, ""), deploy)
+	assert.Equal(t, []string{deploy}, projectTaskIDs(t, "project_id="+release))
+	ret, err = postJSON("api/task", map[string]any{"date": date, "title": "В архив", "project_id": release}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"])
+
+	body, err := requestJSON("api/projects", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), "Релиз 2.3")
+	body, err = requestJSON("api/projects?archived=true", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "Релиз 2.3")
+
+	ret, err = postJSON("api/project/archive?id="+release+"&archived=false", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Nil(t, ret["archived_at"])
+	assert.Contains(t, projectTaskIDs(t, ""), deploy)
+
+	// Удаление с переносом задач в другой проект
+	ret, err = postJSON("api/project?id="+home+"&tasks=move&to="+work, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.ElementsMatch(t, []string{dishes, report}, projectTaskIDs(t, "project_id="+work))
+
+	// Каскадное удаление перемещает задачи в корзину
+	ret, err = postJSON("api/project?id="+release+"&tasks=cascade", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.True(t, inTrash(t, deploy))
+
+	ret, err = postJSON("api/project?id="+release, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"])
+
+	ret, err = postJSON("api/project?id="+work+"&tasks=cascade", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id IN (?, ?, ?)`, dishes, report, deploy)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listLen возвращает количество элементов списка field в ответе на GET-запрос apipath
+func listLen(t *testing.T, token, apipath, field string) int {
+	status, ret := userRequest(t, token, apipath, nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status, apipath)
+	list, _ := ret[field].([]any)
+	return len(list)
+}
+
+func TestSharedSubresources(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	suffix := time.Now().Format("150405.000000")
+	alice := "sub-alice-" + suffix
+	bob := "sub-bob-" + suffix
+	defer func() {
+		for _, query := range []string{
+			`DELETE FROM checklist WHERE task_id IN (SELECT s.id FROM scheduler s JOIN users u ON u.id = s.user_id WHERE u.name IN (?, ?))`,
+			`DELETE FROM attachments WHERE task_id IN (SELECT s.id FROM scheduler s JOIN users u ON u.id = s.user_id WHERE u.name IN (?, ?))`,
+			`DELETE FROM completions WHERE task_id IN (SELECT s.id FROM scheduler s JOIN users u ON u.id = s.user_id WHERE u.name IN (?, ?))`,
+			`DELETE FROM shares WHERE user_id IN (SELECT id FROM users WHERE name IN (?, ?))`,
+			`DELETE FROM scheduler WHERE user_id IN (SELECT id FROM users WHERE name IN (?, ?))`,
+			`DELETE FROM users WHERE name IN (?, ?)`,
+		} {
+			_, err := db.Exec(query, alice, bob)
+			assert.NoError(t, err)
+		}
+	}()
+	for _, name := range []string{alice, bob} {
+		ret, err := postJSON("api/user", map[string]any{"name": name, "password": "secret-" + name}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotNil(t, ret["id"], "регистрация пользователя %s", name)
+	}
+	aliceToken := signIn(t, alice, "secret-"+alice)
+	bobToken := signIn(t, bob, "secret-"+bob)
+
+	status, ret := userRequest(t, aliceToken, "api/task", map[string]any{
+		"date": time.Now().Format(`20060102`), "title": "Задача с чек-листом", "repeat": "d 1",
+	}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	task := fmt.Sprint(ret["id"])
+	status, ret = userRequest(t, aliceToken, "api/task/checklist?id="+task, map[string]any{"text": "Пункт Алисы"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	item := fmt.Sprint(ret["id"])
+	status, file := uploadAttachment(t, aliceToken, task, "notes.txt", []byte("заметки"))
+	assert.Equal(t, http.StatusCreated, status, file.Error)
+	status, ret = userRequest(t, aliceToken, "api/task/done?id="+task, nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+
+	// Без доступа вложенные данные задачи не видны
+	status, _ = userRequest(t, bobToken, "api/task/attachments?id="+task, nil, http.MethodGet)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = userRequest(t, bobToken, "api/task/checklist?id="+task, map[string]any{"text": "Чужой пункт"}, http.MethodPost)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, 0, listLen(t, bobToken, "api/task/history?id="+task, "completions"))
+
+	// Доступ для просмотра открывает чек-лист, вложения и историю только для чтения
+	status, ret = userRequest(t, aliceToken, "api/share", map[string]any{"task_id": task, "user": bob}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	assert.Equal(t, 1, listLen(t, bobToken, "api/task/checklist?id="+task, "items"))
+	assert.Equal(t, 1, listLen(t, bobToken, "api/task/attachments?id="+task, "attachments"))
+	assert.Equal(t, 1, listLen(t, bobToken, "api/task/history?id="+task, "completions"))
+	resp, data := sendRequest(t, bobToken, "api/task/attachment?attachment="+file.ID, nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "заметки", string(data))
+
+	for name, request := range map[string]func() int{
+		"добавление пункта": func() int {
+			status, _ := userRequest(t, bobToken, "api/task/checklist?id="+task, map[string]any{"text": "Пункт Боба"}, http.MethodPost)
+			return status
+		},
+		"отметка пункта": func() int {
+			status, _ := userRequest(t, bobToken, "api/task/checklist/check?item="+item, nil, http.MethodPost)
+			return status
+		},
+		"порядок пунктов": func() int {
+			status, _ := userRequest(t, bobToken, "api/task/checklist/reorder?id="+task, map[string]any{"items": []string{item}}, http.MethodPost)
+			return status
+		},
+		"удаление пункта": func() int {
+			status, _ := userRequest(t, bobToken, "api/task/checklist?item="+item, nil, http.MethodDelete)
+			return status
+		},
+		"загрузка вложения": func() int {
+			status, _ := uploadAttachment(t, bobToken, task, "bob.txt", []byte("от Боба"))
+			return status
+		},
+		"удаление вложения": func() int {
+			status, _ := userRequest(t, bobToken, "api/task/attachment?attachment="+file.ID, nil, http.MethodDelete)
+			return status
+		},
+	} {
+		assert.Equal(t, http.StatusForbidden, request(), name)
+	}
+	assert.Equal(t, 1, listLen(t, aliceToken, "api/task/checklist?id="+task, "items"))
+	assert.Equal(t, 1, listLen(t, aliceToken, "api/task/attachments?id="+task, "attachments"))
+
+	// Доступ для изменения разрешает менять чек-лист и вложения
+	status, ret = userRequest(t, aliceToken, "api/share", map[string]any{"task_id": task, "user": bob, "permission": "edit"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	status, ret = userRequest(t, bobToken, "api/task/checklist?id="+task, map[string]any{"text": "Пункт Боба"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	status, ret = userRequest(t, bobToken, "api/task/checklist/check?item="+item, nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+	status, ret = userRequest(t, bobToken, "api/task/checklist?item="+item, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, status, ret)
+	status, added := uploadAttachment(t, bobToken, task, "bob.txt", []byte("от Боба"))
+	assert.Equal(t, http.StatusCreated, status, added.Error)
+	status, ret = userRequest(t, bobToken, "api/task/attachment?attachment="+file.ID, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, status, ret)
+	status, ret = userRequest(t, bobToken, "api/task/done?id="+task, nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+
+	assert.Equal(t, 1, listLen(t, aliceToken, "api/task/checklist?id="+task, "items"))
+	status, ret = userRequest(t, aliceToken, "api/task/attachments?id="+task, nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	if list, _ := ret["attachments"].([]any); assert.Len(t, list, 1) {
+		assert.Equal(t, "bob.txt", list[0].(map[string]any)["name"])
+	}
+	assert.Equal(t, 2, listLen(t, bobToken, "api/task/history?id="+task, "completions"))
+	today := time.Now().Format(`20060102`)
+	assert.Contains(t, completedIDs(t, bobToken, today, today), task)
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sharedItems возвращает ID и права задач и проектов, открытых пользователю
+func sharedItems(t *testing.T, token string) (map[string]string, map[string]string) {
+	status, ret := userRequest(t, token, "api/shared", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	items := func(key string) map[string]string {
+		result := map[string]string{}
+		list, _ := ret[key].([]any)
+		for _, item := range list {
+			m := item.(map[string]any)
+			result[fmt.Sprint(m["id"])] = fmt.Sprint(m["permission"])
+		}
+		return result
+	}
+	return items("tasks"), items("projects")
+}
+
+func TestShares(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	suffix := time.Now().Format("150405.000000")
+	alice := "share-alice-" + suffix
+	bob := "share-bob-" + suffix
+	defer func() {
+		for _, query := range []string{
+			`DELETE FROM shares WHERE user_id IN (SELECT id FROM users WHERE name IN (?, ?))`,
+			`DELETE FROM scheduler WHERE user_id IN (SELECT id FROM users WHERE name IN (?, ?))`,
+			`DELETE FROM projects WHERE user_id IN (SELECT id FROM users WHERE name IN (?, ?))`,
+			`DELETE FROM users WHERE name IN (?, ?)`,
+		} {
+			_, err := db.Exec(query, alice, bob)
+			assert.NoError(t, err)
+		}
+	}()
+	for _, name := range []string{alice, bob} {
+		ret, err := postJSON("api/user", map[string]any{"name": name, "password": "secret-" + name}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotNil(t, ret["id"], "регистрация пользователя %s", name)
+	}
+	aliceToken := signIn(t, alice, "secret-"+alice)
+	bobToken := signIn(t, bob, "secret-"+bob)
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	status, ret := userRequest(t, aliceToken, "api/task", map[string]any{"date": date, "title": "Общая задача"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	task := fmt.Sprint(ret["id"])
+	status, ret = userRequest(t, aliceToken, "api/project", map[string]any{"name": "Общий проект"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	project := fmt.Sprint(ret["id"])
+	status, ret = userRequest(t, aliceToken, "api/task", map[string]any{
+		"date": date, "title": "Задача проекта", "project_id": project,
+	}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	projectTask := fmt.Sprint(ret["id"])
+
+	// Без доступа задача не видна
+	_, ret = userRequest(t, bobToken, "api/task?id="+task, nil, http.MethodGet)
+	assert.NotNil(t, ret["error"])
+
+	status, ret = userRequest(t, aliceToken, "api/share", map[string]any{"task_id": task, "user": bob}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	share := fmt.Sprint(ret["id"])
+	status, _ = userRequest(t, aliceToken, "api/share", map[string]any{"task_id": task, "user": alice}, http.MethodPost)
+	assert.Equal(t, http.StatusBadRequest, status, "доступ самому себе")
+	status, _ = userRequest(t, aliceToken, "api/share", map[string]any{"task_id": task, "user": bob, "permission": "owner"}, http.MethodPost)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Доступ для просмотра разрешает только чтение
+	_, ret = userRequest(t, bobToken, "api/task?id="+task, nil, http.MethodGet)
+	assert.Equal(t, "Общая задача", ret["title"])
+	status, ret = userRequest(t, bobToken, "api/task", map[string]any{
+		"id": task, "date": date, "title": "Изменено", "version": "1",
+	}, http.MethodPut)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.NotNil(t, ret["error"])
+	status, ret = userRequest(t, bobToken, "api/task/done?id="+task, nil, http.MethodPost)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.NotNil(t, ret["error"])
+	status, ret = userRequest(t, bobToken, "api/task?id="+task, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.NotNil(t, ret["error"])
+
+	// Открывать доступ и смотреть список доступов может только владелец
+	status, _ = userRequest(t, bobToken, "api/share", map[string]any{"task_id": task, "user": alice}, http.MethodPost)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = userRequest(t, bobToken, "api/shares?task_id="+task, nil, http.MethodGet)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, ret = userRequest(t, aliceToken, "api/shares?task_id="+task, nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	if shares, _ := ret["shares"].([]any); assert.Len(t, shares, 1) {
+		assert.Equal(t, bob, shares[0].(map[string]any)["user"])
+		assert.Equal(t, "view", shares[0].(map[string]any)["permission"])
+	}
+
+	// Доступ к проекту открывает все его задачи
+	status, ret = userRequest(t, aliceToken, "api/share", map[string]any{
+		"project_id": project, "user": bob, "permission": "edit",
+	}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	tasks, projects := sharedItems(t, bobToken)
+	assert.Equal(t, map[string]string{task: "view", projectTask: "edit"}, tasks)
+	assert.Equal(t, map[string]string{project: "edit"}, projects)
+	tasks, _ = sharedItems(t, aliceToken)
+	assert.Empty(t, tasks)
+	status, ret = userRequest(t, bobToken, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, ret["tasks"], "открытые задачи не попадают в свой список задач")
+
+	status, ret = userRequest(t, bobToken, "api/task", map[string]any{
+		"id": projectTask, "date": date, "title": "Изменено Бобом", "version": "1",
+	}, http.MethodPut)
+	assert.Equal(t, http.StatusOK, status, ret)
+	_, ret = userRequest(t, aliceToken, "api/task?id="+projectTask, nil, http.MethodGet)
+	assert.Equal(t, "Изменено Бобом", ret["title"])
+	status, ret = userRequest(t, bobToken, "api/task/done?id="+projectTask, nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+	status, _ = userRequest(t, bobToken, "api/project", map[string]any{"id": project, "name": "Проект Боба"}, http.MethodPut)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = userRequest(t, bobToken, "api/project?id="+project, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusForbidden, status, "удалить проект может только владелец")
+
+	// Повторный доступ меняет права
+	status, ret = userRequest(t, aliceToken, "api/share", map[string]any{
+		"task_id": task, "user": bob, "permission": "edit",
+	}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	assert.Equal(t, share, fmt.Sprint(ret["id"]))
+	status, ret = userRequest(t, bobToken, "api/task", map[string]any{
+		"id": task, "date": date, "title": "Общая задача", "comment": "от Боба", "version": "1",
+	}, http.MethodPut)
+	assert.Equal(t, http.StatusOK, status, ret)
+
+	status, _ = userRequest(t, aliceToken, "api/share?id="+share, nil, http.MethodDelete)
+	assert.Equal(t, http.StatusOK, status)
+	_, ret = userRequest(t, bobToken, "api/task?id="+task, nil, http.MethodGet)
+	assert.NotNil(t, ret["error"], "доступ закрыт")
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type syncTask struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type syncResponse struct {
+	Token   string     `json:"token"`
+	HasMore bool       `json:"has_more"`
+	Created []syncTask `json:"created"`
+	Updated []syncTask `json:"updated"`
+	Deleted []struct {
+		ID        string `json:"id"`
+		DeletedAt string `json:"deleted_at"`
+	} `json:"deleted"`
+}
+
+func syncSince(t *testing.T, token string) syncResponse {
+	resp, body := sendRequest(t, Token, "api/sync?since="+token, nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var changes syncResponse
+	assert.NoError(t, json.Unmarshal(body, &changes))
+	return changes
+}
+
+func TestSync(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	start := syncSince(t, "")
+	assert.NotEmpty(t, start.Token)
+	empty := syncSince(t, start.Token)
+	assert.Equal(t, start.Token, empty.Token)
+	assert.Empty(t, empty.Created)
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	created := addTask(t, task{date: date, title: "Синхронизация: новая"})
+	updated := addTask(t, task{date: date, title: "Синхронизация: старая"})
+	deleted := addTask(t, task{date: date, title: "Синхронизация: удаляемая"})
+
+	first := syncSince(t, start.Token)
+	assert.Len(t, first.Created, 3)
+	assert.Empty(t, first.Updated)
+
+	_, err := postJSON("api/task", map[string]any{
+		"id": updated, "date": date, "title": "Синхронизация: изменённая", "version": "1",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	_, err = postJSON("api/task?id="+deleted, nil, http.MethodDelete)
+	assert.NoError(t, err)
+
+	second := syncSince(t, first.Token)
+	assert.Empty(t, second.Created)
+	if assert.Len(t, second.Updated, 1) {
+		assert.Equal(t, updated, second.Updated[0].ID)
+		assert.Equal(t, "Синхронизация: изменённая", second.Updated[0].Title)
+	}
+	if assert.Len(t, second.Deleted, 1) {
+		assert.Equal(t, deleted, second.Deleted[0].ID)
+		assert.NotEmpty(t, second.Deleted[0].DeletedAt)
+	}
+
+	// Устаревшая версия даёт конфликт только для своего изменения
+	resp, body := sendRequest(t, Token, "api/sync", jsonBody(t, map[string]any{
+		"changes": []map[string]any{
+			{"client_id": "a", "op": "update", "task": map[string]any{
+				"id": updated, "version": "1", "date": date, "title": "Синхронизация: устаревшая"}},
+			{"client_id": "b", "op": "update", "task": map[string]any{
+				"id": created, "version": "1", "date": date, "title": "Синхронизация: из клиента"}},
+			{"client_id": "c", "op": "create", "task": map[string]any{
+				"date": date, "title": "Синхронизация: офлайн"}},
+		},
+	}), http.MethodPost, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var push struct {
+		Token   string `json:"token"`
+		Results []struct {
+			ClientID string    `json:"client_id"`
+			ID       string    `json:"id"`
+			Status   string    `json:"status"`
+			Task     *syncTask `json:"task"`
+		} `json:"results"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &push))
+	var offline string
+	if assert.Len(t, push.Results, 3) {
+		assert.Equal(t, "conflict", push.Results[0].Status)
+		if assert.NotNil(t, push.Results[0].Task) {
+			assert.Equal(t, "Синхронизация: изменённая", push.Results[0].Task.Title)
+			assert.Equal(t, "2", push.Results[0].Task.Version)
+		}
+		assert.Equal(t, "ok", push.Results[1].Status)
+		assert.Equal(t, "ok", push.Results[2].Status)
+		assert.Equal(t, "c", push.Results[2].ClientID)
+		offline = push.Results[2].ID
+		assert.NotEmpty(t, offline)
+	}
+
+	third := syncSince(t, second.Token)
+	assert.Len(t, third.Created, 1)
+	assert.Len(t, third.Updated, 1)
+	assert.Equal(t, push.Token, third.Token)
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id IN (?, ?, ?, ?)`, created, updated, deleted, offline)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tagTask struct {
+	ID    string   `json:"id"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+}
+
+func getTaggedTasks(t *testing.T, query string) []tagTask {
+	body, err := requestJSON("api/tasks?"+query, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m struct {
+		Tasks []tagTask `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &m), string(body))
+	return m.Tasks
+}
+
+func TestTags(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	ret, err := postJSON("api/tag", map[string]any{"name": "Тест-работа", "color": "#336699"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["id"])
+	tagID := fmt.Sprint(ret["id"])
+
+	ret, err = postJSON("api/tag", map[string]any{"name": "тест-РАБОТА"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"], "метки различаются только регистром")
+	ret, err = postJSON("api/tag", map[string]any{"name": "Тест", "color": "red"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"], "цвет должен быть в формате #RRGGBB")
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	ret, err = postJSON("api/task", map[string]any{
+		"date": date, "title": "Отчёт с метками", "tags": []string{"тест-работа", "Тест-срочно", "тест-срочно"},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	work := fmt.Sprint(ret["id"])
+	ret, err = postJSON("api/task", map[string]any{
+		"date": date, "title": "Отчёт без метки",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	plain := fmt.Sprint(ret["id"])
+
+	tasks := getTaggedTasks(t, "tag="+url.QueryEscape("ТЕСТ-РАБОТА"))
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, work, tasks[0].ID)
+		assert.Equal(t, []string{"Тест-работа", "Тест-срочно"}, tasks[0].Tags)
+	}
+	// Отбор по метке сочетается с поиском
+	assert.Len(t, getTaggedTasks(t, "tag="+url.QueryEscape("Тест-срочно")+"&search="+url.QueryEscape("Отчёт")), 1)
+	assert.Empty(t, getTaggedTasks(t, "tag="+url.QueryEscape("Тест-срочно")+"&search="+url.QueryEscape("без метки")))
+
+	// Без поля tags метки при изменении задачи сохраняются, а пустой массив их снимает
+	_, err = postJSON("api/task", map[string]any{
+		"id": work, "date": date, "title": "Отчёт с метками", "version": "1",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Len(t, getTaggedTasks(t, "tag="+url.QueryEscape("Тест-работа")), 1)
+	_, err = postJSON("api/task", map[string]any{
+		"id": plain, "date": date, "title": "Отчёт без метки", "version": "1", "tags": []string{"Тест-работа"},
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Len(t, getTaggedTasks(t, "tag="+url.QueryEscape("Тест-работа")), 2)
+
+	ret, err = postJSON("api/tag", map[string]any{"id": tagID, "name": "Тест-офис", "color": "#000000"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, "Тест-офис", ret["name"])
+	assert.Equal(t, float64(2), ret["tasks"])
+	assert.Len(t, getTaggedTasks(t, "tag="+url.QueryEscape("Тест-офис")), 2)
+
+	body, err := requestJSON("api/tags", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"name":"Тест-офис","color":"#000000"`)
+
+	ret, err = postJSON("api/tag?id="+tagID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Empty(t, getTaggedTasks(t, "tag="+url.QueryEscape("Тест-офис")))
+
+	_, err = db.Exec(`DELETE FROM task_tags WHERE task_id IN (?, ?)`, work, plain)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM tags WHERE key = ?`, "тест-срочно")
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id IN (?, ?)`, work, plain)
+	assert.NoError(t, err)
+}
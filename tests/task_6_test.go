@@ -96,6 +96,11 @@ func TestEditTask(t *testing.T) {
 	}
 
 	updateTask := func(newVals map[string]any) {
+		var current Task
+		err := db.Get(&current, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		newVals["version"] = strconv.FormatInt(current.Version, 10)
+
 		mupd, err := postJSON("api/task", newVals, http.MethodPut)
 		assert.NoError(t, err)
 
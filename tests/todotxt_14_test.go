@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTodoTxt(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	lines := strings.Join([]string{
+		"(A) 2024-10-01 Позвонить в банк +Ремонт_дома @телефон @срочно due:2099-10-15 rec:+3m",
+		"Отчёт todo.txt @работа due:2099-10-13 rec:2w",
+		"Неподдерживаемый повтор todo.txt due:2099-10-13 rec:2y",
+	}, "\n")
+	resp, body := sendRequest(t, Token, "api/import?format=todotxt", []byte(lines), http.MethodPost, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Contains(t, string(body), `"created":2`)
+	assert.Contains(t, string(body), `"failed":1`)
+
+	// Проект и контексты не остаются в заголовке, а становятся проектом и метками задачи
+	var task Task
+	err := db.Get(&task, `SELECT * FROM scheduler WHERE title = ?`, "Позвонить в банк")
+	assert.NoError(t, err)
+	assert.Equal(t, "20991015", task.Date)
+	assert.Equal(t, "m 15 1,4,7,10", task.Repeat)
+	var project string
+	assert.NoError(t, db.Get(&project, `SELECT name FROM projects WHERE id = ?`, task.ProjectID))
+	assert.Equal(t, "Ремонт дома", project)
+	_, ret := userRequest(t, Token, fmt.Sprint("api/task?id=", task.ID), nil, http.MethodGet)
+	assert.ElementsMatch(t, []any{"телефон", "срочно"}, ret["tags"])
+
+	var weekly Task
+	err = db.Get(&weekly, `SELECT * FROM scheduler WHERE title = ?`, "Отчёт todo.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "d 14", weekly.Repeat)
+	assert.Equal(t, int64(0), weekly.ProjectID)
+
+	resp, body = sendRequest(t, Token, "api/export?format=todotxt", nil, http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Regexp(t, `\(A\) Позвонить в банк \+Ремонт_дома (@телефон @срочно|@срочно @телефон) due:2099-10-15 rec:\+3m\n`, string(body))
+	assert.Contains(t, string(body), "Отчёт todo.txt @работа due:2099-10-13 rec:+2w\n")
+
+	for _, query := range []string{
+		`DELETE FROM task_tags WHERE task_id IN (?, ?)`,
+		`DELETE FROM scheduler WHERE id IN (?, ?)`,
+	} {
+		_, err = db.Exec(query, task.ID, weekly.ID)
+		assert.NoError(t, err)
+	}
+	_, err = db.Exec(`DELETE FROM projects WHERE id = ?`, task.ProjectID)
+	assert.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM tags WHERE key IN (?, ?, ?) AND id NOT IN (SELECT tag_id FROM task_tags)`,
+		"телефон", "срочно", "работа")
+	assert.NoError(t, err)
+}
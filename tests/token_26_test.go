@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// signToken подписывает токен администратора с полями claims ключом secret
+func signToken(t *testing.T, claims jwt.StandardClaims, secret string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	return token
+}
+
+func TestToken(t *testing.T) {
+	if len(Token) == 0 || len(JWTSecret) == 0 {
+		t.Skip("Token or JWTSecret is not set")
+	}
+	now := time.Now()
+
+	// Токен входа содержит sub, iat, exp и уникальный jti
+	var first, second jwt.StandardClaims
+	for _, claims := range []*jwt.StandardClaims{&first, &second} {
+		_, err := jwt.ParseWithClaims(signIn(t, "", Password), claims, func(*jwt.Token) (any, error) {
+			return []byte(JWTSecret), nil
+		})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "1", first.Subject)
+	assert.InDelta(t, now.Unix(), first.IssuedAt, 60)
+	assert.Greater(t, first.ExpiresAt, first.IssuedAt)
+	assert.NotEmpty(t, first.Id)
+	assert.NotEqual(t, first.Id, second.Id, "jti каждого токена уникален")
+
+	valid := jwt.StandardClaims{Subject: "1", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix(), Id: "token-26"}
+	status, _ := userRequest(t, signToken(t, valid, JWTSecret), "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+
+	for name, token := range map[string]string{
+		"истёкший токен": signToken(t, jwt.StandardClaims{
+			Subject: "1", IssuedAt: now.Add(-2 * time.Hour).Unix(), ExpiresAt: now.Add(-time.Hour).Unix(),
+		}, JWTSecret),
+		"токен без срока действия": signToken(t, jwt.StandardClaims{Subject: "1", Id: "token-26"}, JWTSecret),
+		"токен без jti": signToken(t, jwt.StandardClaims{
+			Subject: "1", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix(),
+		}, JWTSecret),
+		"токен, подписанный паролем": signToken(t, valid, Password),
+		"токен без подписи":          Token[:len(Token)-5],
+	} {
+		status, _ := userRequest(t, token, "api/tasks", nil, http.MethodGet)
+		assert.Equal(t, http.StatusUnauthorized, status, name)
+	}
+}
+
+func TestSignOut(t *testing.T) {
+	if len(Token) == 0 || len(JWTSecret) == 0 {
+		t.Skip("Token or JWTSecret is not set")
+	}
+
+	first, second := signIn(t, "", Password), signIn(t, "", Password)
+	status, _ := userRequest(t, first, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Выход отзывает только токен, с которым выполнен запрос
+	status, ret := userRequest(t, first, "api/signout", nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status, ret)
+	status, _ = userRequest(t, first, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, status, "отозванный токен")
+	status, _ = userRequest(t, first, "api/signout", nil, http.MethodPost)
+	assert.Equal(t, http.StatusUnauthorized, status, "повторный выход")
+	status, _ = userRequest(t, second, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status, "другой токен того же пользователя")
+
+	// Токен с тем же jti отклоняется, даже если подписан заново
+	var claims jwt.StandardClaims
+	_, err := jwt.ParseWithClaims(first, &claims, func(*jwt.Token) (any, error) { return []byte(JWTSecret), nil })
+	assert.NoError(t, err)
+	claims.ExpiresAt = time.Now().Add(time.Hour).Unix()
+	status, _ = userRequest(t, signToken(t, claims, JWTSecret), "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusUnauthorized, status, "новый токен с отозванным jti")
+
+	// Записи об истёкших токенах удаляются при следующем выходе
+	db := openDB(t)
+	defer db.Close()
+	_, err = db.Exec(`INSERT INTO revoked_tokens (jti, expires_at) VALUES ('expired-26', ?)`,
+		time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	assert.NoError(t, err)
+	status, _ = userRequest(t, second, "api/signout", nil, http.MethodPost)
+	assert.Equal(t, http.StatusOK, status)
+	var expired int
+	assert.NoError(t, db.Get(&expired, `SELECT count(*) FROM revoked_tokens WHERE jti = 'expired-26'`))
+	assert.Equal(t, 0, expired)
+}
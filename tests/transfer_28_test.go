@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tasksByTitle возвращает задачи пользователя по заголовкам
+func tasksByTitle(t *testing.T, token string) map[string]map[string]any {
+	status, ret := userRequest(t, token, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	tasks := map[string]map[string]any{}
+	list, _ := ret["tasks"].([]any)
+	for _, item := range list {
+		task := item.(map[string]any)
+		tasks[fmt.Sprint(task["title"])] = task
+	}
+	return tasks
+}
+
+// historyDates возвращает даты выполнений задачи id
+func historyDates(t *testing.T, token, id string) []string {
+	status, ret := userRequest(t, token, "api/task/history?id="+id, nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	dates := []string{}
+	list, _ := ret["completions"].([]any)
+	for _, item := range list {
+		dates = append(dates, fmt.Sprint(item.(map[string]any)["date"]))
+	}
+	return dates
+}
+
+func TestTransferRoundTrip(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	// Пользователи без задач и проектов играют роль новой базы: ID из выгрузки им не принадлежат
+	suffix := time.Now().Format("150405.000000")
+	source := "transfer-src-" + suffix
+	targets := map[string]string{"json": "transfer-json-" + suffix, "csv": "transfer-csv-" + suffix}
+	names := []any{source, targets["json"], targets["csv"]}
+	defer func() {
+		for _, query := range []string{
+			`DELETE FROM completions WHERE task_id IN (SELECT s.id FROM scheduler s JOIN users u ON u.id = s.user_id WHERE u.name IN (?, ?, ?))`,
+			`DELETE FROM task_deps WHERE task_id IN (SELECT s.id FROM scheduler s JOIN users u ON u.id = s.user_id WHERE u.name IN (?, ?, ?))`,
+			`DELETE FROM scheduler WHERE user_id IN (SELECT id FROM users WHERE name IN (?, ?, ?))`,
+			`DELETE FROM projects WHERE user_id IN (SELECT id FROM users WHERE name IN (?, ?, ?))`,
+			`DELETE FROM users WHERE name IN (?, ?, ?)`,
+		} {
+			_, err := db.Exec(query, names...)
+			assert.NoError(t, err)
+		}
+	}()
+	for _, name := range names {
+		ret, err := postJSON("api/user", map[string]any{"name": name, "password": fmt.Sprint("secret-", name)}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotNil(t, ret["id"], "регистрация пользователя %s", name)
+	}
+	sourceToken := signIn(t, source, "secret-"+source)
+
+	date := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+	status, ret := userRequest(t, sourceToken, "api/project", map[string]any{"name": "Ремонт"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	project := fmt.Sprint(ret["id"])
+	status, ret = userRequest(t, sourceToken, "api/task", map[string]any{
+		"date": date, "title": "Купить краску", "project_id": project,
+	}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	blocker := fmt.Sprint(ret["id"])
+	status, ret = userRequest(t, sourceToken, "api/task", map[string]any{
+		"date": date, "title": "Покрасить стены", "blocked_by": []string{blocker},
+	}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	status, ret = userRequest(t, sourceToken, "api/task", map[string]any{
+		"date": date, "title": "Полить цветы", "repeat": "d 1",
+	}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	repeating := fmt.Sprint(ret["id"])
+	for i := 0; i < 2; i++ {
+		status, ret = userRequest(t, sourceToken, "api/task/done?id="+repeating, nil, http.MethodPost)
+		assert.Equal(t, http.StatusOK, status, ret)
+	}
+	completed := historyDates(t, sourceToken, repeating)
+	assert.Len(t, completed, 2)
+
+	for format, target := range targets {
+		resp, export := sendRequest(t, sourceToken, "api/export?format="+format, nil, http.MethodGet, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		targetToken := signIn(t, target, "secret-"+target)
+		var report map[string]any
+		resp, body := sendRequest(t, targetToken, "api/import?format="+format, export, http.MethodPost, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		assert.NoError(t, json.Unmarshal(body, &report))
+		assert.Equal(t, float64(3), report["created"], format)
+		assert.Equal(t, float64(0), report["failed"], format)
+		assert.Empty(t, report["errors"], format)
+
+		tasks := tasksByTitle(t, targetToken)
+		if !assert.Len(t, tasks, 3, format) {
+			continue
+		}
+		// Проект находится по названию, а не по ID из выгрузки
+		paint := tasks["Купить краску"]
+		assert.NotEqual(t, project, paint["project_id"], format)
+		_, ret = userRequest(t, targetToken, fmt.Sprint("api/project?id=", paint["project_id"]), nil, http.MethodGet)
+		assert.Equal(t, "Ремонт", ret["name"], format)
+		// Зависимость указывает на загруженную задачу
+		assert.Equal(t, []any{paint["id"]}, tasks["Покрасить стены"]["blocked_by"], format)
+		assert.Equal(t, completed, historyDates(t, targetToken, fmt.Sprint(tasks["Полить цветы"]["id"])), format)
+
+		// Повторная загрузка пропускает задачи и не дублирует историю выполнения
+		resp, body = sendRequest(t, targetToken, "api/import?format="+format, export, http.MethodPost, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		assert.NoError(t, json.Unmarshal(body, &report))
+		assert.Equal(t, float64(3), report["skipped"], format)
+		assert.Len(t, historyDates(t, targetToken, fmt.Sprint(tasks["Полить цветы"]["id"])), 2, format)
+		var projects int
+		assert.NoError(t, db.Get(&projects, `SELECT count(*) FROM projects WHERE user_id IN (SELECT id FROM users WHERE name = ?)`, target))
+		assert.Equal(t, 1, projects, format)
+	}
+
+	// Блокирующей задачи нет в файле: задача загружается, а в отчёте остаётся ошибка
+	targetToken := signIn(t, targets["json"], "secret-"+targets["json"])
+	resp, body := sendRequest(t, targetToken, "api/import?format=json", []byte(`{"tasks":[
+		{"id":"900001","date":"`+date+`","title":"Без блокирующей","blocked_by":["900002"]}]}`), http.MethodPost, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var report map[string]any
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, float64(1), report["created"])
+	assert.Len(t, report["errors"], 1)
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sendRequest отправляет запрос method с телом body и заголовками headers от имени пользователя
+// с токеном token, пустой токен не передаётся. Возвращает ответ и прочитанное тело ответа
+func sendRequest(t *testing.T, token, apipath string, body []byte, method string, headers map[string]string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewReader(body))
+	if !assert.NoError(t, err) {
+		return &http.Response{}, nil
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if len(token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return &http.Response{}, nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp, data
+}
+
+// jsonBody кодирует values в JSON для тела запроса, пустые values - пустое тело
+func jsonBody(t *testing.T, values map[string]any) []byte {
+	if len(values) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(values)
+	assert.NoError(t, err)
+	return data
+}
+
+// userRequest выполняет запрос с токеном пользователя и возвращает код ответа и тело
+func userRequest(t *testing.T, token, apipath string, values map[string]any, method string) (int, map[string]any) {
+	resp, body := sendRequest(t, token, apipath, jsonBody(t, values), method,
+		map[string]string{"Content-Type": "application/json"})
+	var m map[string]any
+	_ = json.Unmarshal(body, &m)
+	return resp.StatusCode, m
+}
+
+// signIn входит под именем пользователя и возвращает его токен
+func signIn(t *testing.T, name, password string) string {
+	ret, err := postJSON("api/signin", map[string]any{"name": name, "password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	token, _ := ret["token"].(string)
+	assert.NotEmpty(t, token, "вход пользователя %s", name)
+	return token
+}
+
+func TestUsers(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("Token is not set")
+	}
+	db := openDB(t)
+	defer db.Close()
+
+	suffix := time.Now().Format("150405.000000")
+	alice := "alice-" + suffix
+	bob := "bob-" + suffix
+	defer func() {
+		_, err := db.Exec(`DELETE FROM scheduler WHERE user_id IN (SELECT id FROM users WHERE name IN (?, ?))`, alice, bob)
+		assert.NoError(t, err)
+		_, err = db.Exec(`DELETE FROM users WHERE name IN (?, ?)`, alice, bob)
+		assert.NoError(t, err)
+	}()
+
+	for _, name := range []string{alice, bob} {
+		ret, err := postJSON("api/user", map[string]any{"name": name, "password": "secret-" + name}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotNil(t, ret["id"], "регистрация пользователя %s", name)
+	}
+	ret, err := postJSON("api/user", map[string]any{"name": alice, "password": "another-password"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"], "имя пользователя уже занято")
+	ret, err = postJSON("api/user", map[string]any{"name": "carol-" + suffix, "password": "short"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotNil(t, ret["error"], "слишком короткий пароль")
+
+	ret, err = postJSON("api/signin", map[string]any{"name": alice, "password": "wrong-password"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["token"])
+
+	aliceToken := signIn(t, alice, "secret-"+alice)
+	bobToken := signIn(t, bob, "secret-"+bob)
+
+	date := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	status, ret := userRequest(t, aliceToken, "api/task", map[string]any{"date": date, "title": "Задача Алисы"}, http.MethodPost)
+	assert.Equal(t, http.StatusCreated, status, ret)
+	id := fmt.Sprint(ret["id"])
+
+	_, ret = userRequest(t, aliceToken, "api/task?id="+id, nil, http.MethodGet)
+	assert.Equal(t, "Задача Алисы", ret["title"])
+
+	// Задачи одного пользователя не видны другому и администратору
+	_, ret = userRequest(t, bobToken, "api/task?id="+id, nil, http.MethodGet)
+	assert.NotNil(t, ret["error"])
+	status, ret = userRequest(t, bobToken, "api/tasks", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, ret["tasks"])
+	_, ret = userRequest(t, Token, "api/task?id="+id, nil, http.MethodGet)
+	assert.NotNil(t, ret["error"])
+
+	status, ret = userRequest(t, bobToken, "api/task?id="+id, nil, http.MethodDelete)
+	assert.NotEqual(t, http.StatusOK, status)
+	assert.NotNil(t, ret["error"])
+	status, ret = userRequest(t, bobToken, "api/task/done?id="+id, nil, http.MethodPost)
+	assert.NotEqual(t, http.StatusOK, status)
+	assert.NotNil(t, ret["error"])
+	_, ret = userRequest(t, aliceToken, "api/task?id="+id, nil, http.MethodGet)
+	assert.Nil(t, ret["error"])
+
+	// Регистрировать пользователей может только администратор
+	status, _ = userRequest(t, aliceToken, "api/user", map[string]any{"name": "eve-" + suffix, "password": "secret-password"}, http.MethodPost)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = userRequest(t, aliceToken, "api/users", nil, http.MethodGet)
+	assert.Equal(t, http.StatusForbidden, status)
+	var users int
+	assert.NoError(t, db.Get(&users, `SELECT COUNT(*) FROM users`))
+	status, ret = userRequest(t, Token, "api/users", nil, http.MethodGet)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, ret["users"], users)
+}
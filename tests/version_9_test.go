@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskVersion(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().Format(`20060102`)
+	id := addTask(t, task{
+		date:  date,
+		title: "Проверить версии",
+	})
+
+	resp, body := sendRequest(t, Token, "api/task?id="+id, nil, http.MethodGet, nil)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	var m map[string]string
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "1", m["version"])
+
+	update := map[string]any{
+		"id":    id,
+		"date":  date,
+		"title": "Проверить версии задачи",
+	}
+
+	resp, _ = sendRequest(t, Token, "api/task", jsonBody(t, update), http.MethodPut, nil)
+	assert.Equal(t, http.StatusPreconditionRequired, resp.StatusCode)
+
+	resp, body = sendRequest(t, Token, "api/task", jsonBody(t, update), http.MethodPut, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+	// Запись со старой версией отклоняется и возвращает актуальную копию задачи
+	resp, body = sendRequest(t, Token, "api/task", jsonBody(t, update), http.MethodPut, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	var conflict struct {
+		Error string            `json:"error"`
+		Task  map[string]string `json:"task"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &conflict))
+	assert.NotEmpty(t, conflict.Error)
+	assert.Equal(t, "2", conflict.Task["version"])
+	assert.Equal(t, "Проверить версии задачи", conflict.Task["title"])
+
+	update["version"] = "1"
+	resp, _ = sendRequest(t, Token, "api/task", jsonBody(t, update), http.MethodPut, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp, _ = sendRequest(t, Token, "api/task/done?id="+id+"&version=1", nil, http.MethodPost, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp, _ = sendRequest(t, Token, "api/task/done?id="+id, nil, http.MethodPost, map[string]string{"If-Match": `"2"`})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err := db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+}